@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
 	"github.com/openconfig/ondatra/telemetry"
 
 	opb "github.com/openconfig/ondatra/proto"
@@ -81,12 +83,50 @@ func (d *Device) Version() string {
 	return d.res.Dimensions().SoftwareVersion
 }
 
+// ManagementAddress returns the address of the device management interface,
+// or "" if the binding does not know it.
+func (d *Device) ManagementAddress() string {
+	return d.res.Dimensions().Metadata.ManagementAddress
+}
+
+// CredentialsHandle returns an opaque reference to the device credentials,
+// or "" if the binding does not provide one.
+func (d *Device) CredentialsHandle() string {
+	return d.res.Dimensions().Metadata.CredentialsHandle
+}
+
+// SerialNumber returns the device serial number, or "" if the binding does
+// not know it.
+func (d *Device) SerialNumber() string {
+	return d.res.Dimensions().Metadata.SerialNumber
+}
+
+// ChassisType returns the device chassis type, or "" if the binding does not
+// know it.
+func (d *Device) ChassisType() string {
+	return d.res.Dimensions().Metadata.ChassisType
+}
+
+// LineCards returns the names of the line cards installed in the device.
+func (d *Device) LineCards() []string {
+	return append([]string(nil), d.res.Dimensions().Metadata.LineCards...)
+}
+
+// Labels returns the key-value labels of the device.
+func (d *Device) Labels() map[string]string {
+	labels := make(map[string]string)
+	for k, v := range d.res.Dimensions().Metadata.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
 // Port returns a port with a given id.
 func (d *Device) Port(t testing.TB, ID string) *Port {
 	t.Helper()
 	p, err := d.port(ID)
 	if err != nil {
-		t.Fatalf("Port(t, %s) on %s: %v", ID, d, err)
+		report.Fatalf(t, usererr.Wrap(err), "Port(t, %s) on %s", ID, d)
 	}
 	return p
 }
@@ -156,10 +196,17 @@ const (
 	Speed10Gb = Speed(opb.Port_S_10GB)
 	// Speed100Gb is a port speed of 100Gbps.
 	Speed100Gb = Speed(opb.Port_S_100GB)
+	// Speed400Gb is a port speed of 400Gbps.
+	Speed400Gb = Speed(opb.Port_S_400GB)
 )
 
-// TODO: Restore when speed is consistently propagated back.
-// Speed returns the port speed.
-// func (p *Port) Speed() Speed {
-//	return Speed(p.pb.GetSpeed())
-//}
+// Speed returns the port speed, or zero if the speed is not known.
+func (p *Port) Speed() Speed {
+	return Speed(p.res.Speed)
+}
+
+// PMD returns the physical medium dependent (transceiver) type of the port,
+// or an empty string if it is not known.
+func (p *Port) PMD() string {
+	return p.res.PMD
+}
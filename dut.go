@@ -18,15 +18,19 @@ import (
 	"golang.org/x/net/context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/openconfig/ygot/ygot"
 	"github.com/openconfig/ondatra/config"
+	"github.com/openconfig/ondatra/internal/baseline"
 	"github.com/openconfig/ondatra/internal/binding"
 	"github.com/openconfig/ondatra/internal/cli"
 	"github.com/openconfig/ondatra/internal/console"
 	"github.com/openconfig/ondatra/internal/dut"
-	"github.com/openconfig/ondatra/internal/gnmigen/genutil"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
 	"github.com/openconfig/ondatra/internal/operations"
 	"github.com/openconfig/ondatra/internal/p4rt"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
@@ -64,7 +68,59 @@ func (a *Config) New() *DUTConfig {
 	}
 }
 
+// FetchOpenConfig returns the running config of the DUT as openconfig JSON.
+func (a *Config) FetchOpenConfig(t testing.TB) string {
+	t.Helper()
+	logAction(t, "Fetching openconfig from %s", a.dut)
+	config, err := dut.FetchConfig(context.Background(), a.dut, true)
+	if err != nil {
+		report.Fatalf(t, err, "FetchOpenConfig(t) on %s", a.dut.Name)
+	}
+	return config
+}
+
+// FetchVendorConfig returns the running config of the DUT in the vendor's
+// syntax, like the output of a "show running-config" command. It fails the
+// test if the binding does not support fetching vendor config.
+func (a *Config) FetchVendorConfig(t testing.TB) string {
+	t.Helper()
+	logAction(t, "Fetching vendor config from %s", a.dut)
+	config, err := dut.FetchConfig(context.Background(), a.dut, false)
+	if err != nil {
+		report.Fatalf(t, err, "FetchVendorConfig(t) on %s", a.dut.Name)
+	}
+	return config
+}
+
+// Save saves the running config of the DUT as a named checkpoint, replacing any
+// checkpoint of the same name, so that a test can later Restore it, e.g. to
+// clean up after a disruptive test.
+func (a *Config) Save(t testing.TB, checkpoint string) {
+	t.Helper()
+	logAction(t, "Saving a config checkpoint of %s", a.dut)
+	if err := baseline.SaveCheckpoint(context.Background(), a.dut, checkpoint); err != nil {
+		report.Fatalf(t, err, "Save(t, %q) on %s", checkpoint, a.dut.Name)
+	}
+}
+
+// Restore replaces the running config of the DUT with the config saved as the
+// named checkpoint. It fails the test if no such checkpoint was saved.
+func (a *Config) Restore(t testing.TB, checkpoint string) {
+	t.Helper()
+	logAction(t, "Restoring a config checkpoint of %s", a.dut)
+	if err := baseline.RestoreCheckpoint(context.Background(), a.dut, checkpoint); err != nil {
+		report.Fatalf(t, err, "Restore(t, %q) on %s", checkpoint, a.dut.Name)
+	}
+}
+
 // DUTConfig is a configuration of a device under test.
+//
+// The config text is a Go template, which is executed with facts about the
+// device, such as its {{ .Name }} and its {{ .Ports }}, with their peers in
+// the testbed. Besides {{ port "<portID>" }} and {{ var "<key>" }}, templates
+// can include shared fragments from files with {{ include "<path>" . }}, do IP
+// arithmetic with cidrhost, ipadd and cidrnetmask, and use a few sprig-style
+// helpers such as add, upper and join.
 type DUTConfig struct {
 	dut *reservation.DUT
 	cfg *dut.Config
@@ -92,6 +148,27 @@ func (c *DUTConfig) WithJuniperText(text string) *DUTConfig {
 	return c
 }
 
+// WithVendorText sets the config to be pushed to a device of the vendor.
+func (c *DUTConfig) WithVendorText(vendor Vendor, text string) *DUTConfig {
+	c.cfg.VC[opb.Device_Vendor(vendor)] = dut.ConfigText(text)
+	return c
+}
+
+// WithVendorVariantText sets the config to be pushed to a device of the vendor
+// whose hardware model and software version match the specified regular
+// expressions, which must match the whole value; an empty expression matches
+// any value. Of the configs that apply to a device, the variant with the most
+// criteria is pushed, then the vendor config, then the openconfig.
+func (c *DUTConfig) WithVendorVariantText(vendor Vendor, hardwareModel, softwareVersion, text string) *DUTConfig {
+	c.cfg.Variants = append(c.cfg.Variants, &dut.Variant{
+		Vendor:          opb.Device_Vendor(vendor),
+		HardwareModel:   hardwareModel,
+		SoftwareVersion: softwareVersion,
+		Provider:        dut.ConfigText(text),
+	})
+	return c
+}
+
 // WithOpenConfigText sets the openconfig to be pushed to a device
 // if no vendor-specific configuration has been set.
 func (c *DUTConfig) WithOpenConfigText(text string) *DUTConfig {
@@ -99,6 +176,28 @@ func (c *DUTConfig) WithOpenConfigText(text string) *DUTConfig {
 	return c
 }
 
+// WithOpenConfigStruct sets the openconfig to be pushed to a device, if no
+// vendor-specific configuration has been set, to a ygot struct of the device
+// root. The struct is validated against the schema and marshalled to RFC7951
+// JSON when the config is pushed; unlike text config, it is not a template.
+func (c *DUTConfig) WithOpenConfigStruct(root ygot.ValidatedGoStruct) *DUTConfig {
+	c.cfg.Open = dut.ConfigStruct{Struct: root}
+	return c
+}
+
+// WithOpenConfigStructAt sets the openconfig to be pushed to a device, if no
+// vendor-specific configuration has been set, to a ygot struct of the subtree
+// at the specified config path, e.g. dut.Config().Interface("Ethernet1").
+// The struct is validated and marshalled like in WithOpenConfigStruct, but it
+// is pushed with a gNMI Set at the path rather than through the binding:
+// Push replaces only the subtree, and Append updates it. A subtree cannot be
+// dry-run, nor pushed pending confirmation if the binding commits config
+// natively.
+func (c *DUTConfig) WithOpenConfigStructAt(path ygot.PathStruct, subtree ygot.ValidatedGoStruct) *DUTConfig {
+	c.cfg.Open = dut.ConfigStruct{Path: path, Struct: subtree}
+	return c
+}
+
 // WithAristaFile sets the config to be pushed to an Arista device.
 func (c *DUTConfig) WithAristaFile(path string) *DUTConfig {
 	c.cfg.VC[opb.Device_ARISTA] = dut.ConfigFile(path)
@@ -117,6 +216,25 @@ func (c *DUTConfig) WithJuniperFile(path string) *DUTConfig {
 	return c
 }
 
+// WithVendorFile sets the config to be pushed to a device of the vendor.
+func (c *DUTConfig) WithVendorFile(vendor Vendor, path string) *DUTConfig {
+	c.cfg.VC[opb.Device_Vendor(vendor)] = dut.ConfigFile(path)
+	return c
+}
+
+// WithVendorVariantFile sets the config to be pushed to a device of the vendor
+// whose hardware model and software version match the specified regular
+// expressions, like WithVendorVariantText.
+func (c *DUTConfig) WithVendorVariantFile(vendor Vendor, hardwareModel, softwareVersion, path string) *DUTConfig {
+	c.cfg.Variants = append(c.cfg.Variants, &dut.Variant{
+		Vendor:          opb.Device_Vendor(vendor),
+		HardwareModel:   hardwareModel,
+		SoftwareVersion: softwareVersion,
+		Provider:        dut.ConfigFile(path),
+	})
+	return c
+}
+
 // WithOpenConfigFile sets the openconfig to be pushed to a device
 // if no vendor-specific configuration has been set.
 func (c *DUTConfig) WithOpenConfigFile(path string) *DUTConfig {
@@ -140,12 +258,15 @@ func (c *DUTConfig) WithVarMap(m map[string]string) *DUTConfig {
 // Push replaces the config on the device with the specified config, prepended with the device's base config.
 // It pushes vendor config to the device if it has been set; otherwise, it attempts to push openconfig.
 // If neither vendor config nor openconfig has been specified, it fails the test.
+// The test log reports which config was pushed.
 func (c *DUTConfig) Push(t testing.TB) {
 	t.Helper()
 	logAction(t, "Pushing config to %s", c.dut)
-	if err := dut.PushConfig(context.Background(), c.dut, c.cfg, false); err != nil {
-		t.Fatalf("Push(t) on %s: %v", c, err)
+	variant, err := dut.PushConfig(context.Background(), c.dut, c.cfg, false)
+	if err != nil {
+		report.Fatalf(t, err, "Push(t) on %s", c)
 	}
+	t.Logf("Pushed the %s to %s", variant, c.dut.Name)
 }
 
 // Append appends the specific config to the device's current config.
@@ -154,9 +275,193 @@ func (c *DUTConfig) Push(t testing.TB) {
 func (c *DUTConfig) Append(t testing.TB) {
 	t.Helper()
 	logAction(t, "Appending config to %s", c.dut)
-	if err := dut.PushConfig(context.Background(), c.dut, c.cfg, true); err != nil {
-		t.Fatalf("Append(t) on %s: %v", c, err)
+	variant, err := dut.PushConfig(context.Background(), c.dut, c.cfg, true)
+	if err != nil {
+		report.Fatalf(t, err, "Append(t) on %s", c)
+	}
+	t.Logf("Appended the %s to %s", variant, c.dut.Name)
+}
+
+// ConfigDryRun is the result of a dry run of a config push.
+type ConfigDryRun struct {
+	// Config is the config that would be pushed, with its templates
+	// interpolated.
+	Config string
+	// Variant describes which of the configs would be pushed, e.g.
+	// "ARISTA config" or "openconfig".
+	Variant string
+	// Diff is a line diff of the running config of the device against the
+	// config that the push would result in, or empty if the push would not
+	// change it.
+	Diff string
+}
+
+// DryRunPush returns the config that Push would push to the device and a diff
+// of the device's running config against it, without changing the config on
+// the device. Unless the binding supports dry runs natively, only openconfig
+// can be dry-run, and it is not validated by the device.
+func (c *DUTConfig) DryRunPush(t testing.TB) *ConfigDryRun {
+	t.Helper()
+	logAction(t, "Dry-running config push to %s", c.dut)
+	res, err := dut.DryRun(context.Background(), c.dut, c.cfg, false)
+	if err != nil {
+		report.Fatalf(t, err, "DryRunPush(t) on %s", c)
 	}
+	return &ConfigDryRun{Config: res.Config, Variant: res.Variant, Diff: res.Diff}
+}
+
+// DryRunAppend returns the config that Append would append to the device's
+// config and a diff of the device's running config against the result, without
+// changing the config on the device.
+func (c *DUTConfig) DryRunAppend(t testing.TB) *ConfigDryRun {
+	t.Helper()
+	logAction(t, "Dry-running config append to %s", c.dut)
+	res, err := dut.DryRun(context.Background(), c.dut, c.cfg, true)
+	if err != nil {
+		report.Fatalf(t, err, "DryRunAppend(t) on %s", c)
+	}
+	return &ConfigDryRun{Config: res.Config, Variant: res.Variant, Diff: res.Diff}
+}
+
+// PushConfirmed is like Push, but the device rolls back the config unless the
+// returned commit is confirmed within the timeout. This guards against a bad
+// config that cuts off access to the device. Unless the binding commits config
+// natively, only openconfig can be pushed, and a push that is still
+// unconfirmed when the test ends is rolled back then.
+func (c *DUTConfig) PushConfirmed(t testing.TB, timeout time.Duration) *ConfigCommit {
+	t.Helper()
+	logAction(t, "Pushing config to %s, pending confirmation", c.dut)
+	commit, err := dut.PushConfirmed(context.Background(), c.dut, c.cfg, false, timeout)
+	if err != nil {
+		report.Fatalf(t, err, "PushConfirmed(t, %v) on %s", timeout, c)
+	}
+	t.Logf("Pushed the %s to %s", commit.Variant, c.dut.Name)
+	return newConfigCommit(t, c.dut, commit)
+}
+
+// AppendConfirmed is like Append, but the device rolls back the config unless
+// the returned commit is confirmed within the timeout.
+func (c *DUTConfig) AppendConfirmed(t testing.TB, timeout time.Duration) *ConfigCommit {
+	t.Helper()
+	logAction(t, "Appending config to %s, pending confirmation", c.dut)
+	commit, err := dut.PushConfirmed(context.Background(), c.dut, c.cfg, true, timeout)
+	if err != nil {
+		report.Fatalf(t, err, "AppendConfirmed(t, %v) on %s", timeout, c)
+	}
+	t.Logf("Appended the %s to %s", commit.Variant, c.dut.Name)
+	return newConfigCommit(t, c.dut, commit)
+}
+
+// ConfigCommit is a config push that is rolled back unless it is confirmed in
+// time.
+type ConfigCommit struct {
+	dut    *reservation.DUT
+	commit *dut.Commit
+}
+
+// newConfigCommit returns a ConfigCommit of the push that is rolled back at the
+// end of the test unless it is confirmed, so that the rollback cannot outlive
+// the test and clobber the config of a later one.
+func newConfigCommit(t testing.TB, d *reservation.DUT, commit *dut.Commit) *ConfigCommit {
+	t.Cleanup(func() {
+		if err := commit.Cancel(context.Background()); err != nil {
+			t.Errorf("Failed to roll back the unconfirmed config push to %s: %v", d.Name, err)
+		}
+	})
+	return &ConfigCommit{dut: d, commit: commit}
+}
+
+// Confirm confirms the config push, so that it is not rolled back.
+// It fails the test if the push was already rolled back.
+func (c *ConfigCommit) Confirm(t testing.TB) {
+	t.Helper()
+	logAction(t, "Confirming config push to %s", c.dut)
+	if err := c.commit.Confirm(context.Background()); err != nil {
+		report.Fatalf(t, err, "Confirm(t) on %s", c.dut.Name)
+	}
+}
+
+// Component types reported in the OpenConfig /components tree.
+const (
+	ComponentChassis           = "CHASSIS"
+	ComponentLineCard          = "LINECARD"
+	ComponentControllerCard    = "CONTROLLER_CARD"
+	ComponentFabric            = "FABRIC"
+	ComponentPowerSupply       = "POWER_SUPPLY"
+	ComponentFan               = "FAN"
+	ComponentPort              = "PORT"
+	ComponentTransceiver       = "TRANSCEIVER"
+	ComponentIntegratedCircuit = "INTEGRATED_CIRCUIT"
+)
+
+// Components returns the hardware component inventory of the DUT, as reported
+// by the state of the OpenConfig /components tree, sorted by name.
+func (d *DUTDevice) Components(t testing.TB) []*Component {
+	t.Helper()
+	logAction(t, "Getting components of %s", d.res)
+	comps, err := operations.Components(context.Background(), d.res)
+	if err != nil {
+		report.Fatalf(t, err, "Components(t) on %s", d)
+	}
+	var cs []*Component
+	for _, c := range comps {
+		cs = append(cs, &Component{c})
+	}
+	return cs
+}
+
+// ComponentsOfType returns the hardware components of the DUT with the
+// specified type, such as ComponentLineCard, sorted by name.
+func (d *DUTDevice) ComponentsOfType(t testing.TB, typ string) []*Component {
+	t.Helper()
+	var cs []*Component
+	for _, c := range d.Components(t) {
+		if c.Type() == typ {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// Component is a hardware component of a DUT, such as a line card or a
+// supervisor. Its values are a snapshot from when the inventory was fetched.
+type Component struct {
+	c *operations.Component
+}
+
+func (c *Component) String() string {
+	return fmt.Sprintf("Component%+v", *c.c)
+}
+
+// Name returns the component name.
+func (c *Component) Name() string {
+	return c.c.Name
+}
+
+// Type returns the component type, e.g. ComponentLineCard, or "" if unknown.
+func (c *Component) Type() string {
+	return c.c.Type
+}
+
+// Parent returns the name of the component that contains this component.
+func (c *Component) Parent() string {
+	return c.c.Parent
+}
+
+// Description returns the component description.
+func (c *Component) Description() string {
+	return c.c.Description
+}
+
+// OperStatus returns the operational status, e.g. "ACTIVE" or "INACTIVE".
+func (c *Component) OperStatus() string {
+	return c.c.OperStatus
+}
+
+// RedundantRole returns the role of a redundant component such as a
+// supervisor, i.e. "PRIMARY" or "SECONDARY", or "" if it is not redundant.
+func (c *Component) RedundantRole() string {
+	return c.c.RedundantRole
 }
 
 // RawAPIs returns a handle to raw protocol APIs on the DUT.
@@ -169,24 +474,26 @@ type RawAPIs struct {
 	dut *reservation.DUT
 }
 
-// GNMI returns a new gNMI client on the DUT. This client will not be cached.
+// GNMI returns a new gNMI client on the DUT. This client will not be cached,
+// and its calls will not be retried on transient errors.
 func (r *RawAPIs) GNMI(t testing.TB) gpb.GNMIClient {
 	t.Helper()
 	logAction(t, "Creating gNMI client for %s", r.dut)
-	gnmi, err := genutil.NewGNMI(context.Background(), r.dut)
+	gnmi, err := gnmiclient.New(context.Background(), r.dut)
 	if err != nil {
-		t.Fatalf("GNMI(t) on %v: %v", r.dut, err)
+		report.Fatalf(t, err, "GNMI(t) on %v", r.dut)
 	}
 	return gnmi
 }
 
-// GNOI returns a gNOI client on the DUT.
+// GNOI returns a new gNOI client on the DUT. This client will not be cached,
+// and its calls will not be retried on transient errors.
 func (r *RawAPIs) GNOI(t testing.TB) GNOI {
 	t.Helper()
 	logAction(t, "Creating gNOI client for %s", r.dut)
 	bgnoi, err := operations.NewGNOI(context.Background(), r.dut)
 	if err != nil {
-		t.Fatalf("GNOI(t) on %v: %v", r.dut, err)
+		report.Fatalf(t, err, "GNOI(t) on %v", r.dut)
 	}
 	return bgnoi
 }
@@ -208,7 +515,7 @@ func (r *RawAPIs) P4RT(t testing.TB) p4pb.P4RuntimeClient {
 	logAction(t, "Fetching P4RT client for %s", r.dut)
 	p4rtClient, err := p4rt.FetchP4RT(context.Background(), r.dut)
 	if err != nil {
-		t.Fatalf("Failed to fetch P4RT client on %v: %v", r.dut, err)
+		report.Fatalf(t, err, "Failed to fetch P4RT client on %v", r.dut)
 	}
 	return p4rtClient
 }
@@ -230,7 +537,7 @@ func (r *RawAPIs) CLI(t testing.TB) StreamClient {
 	logAction(t, "Fetching CLI client for %s", r.dut)
 	c, err := cli.FetchCLI(context.Background(), r.dut)
 	if err != nil {
-		t.Fatalf("Failed to fetch CLI client on %v: %v", r.dut, err)
+		report.Fatalf(t, err, "Failed to fetch CLI client on %v", r.dut)
 	}
 	return c
 }
@@ -241,7 +548,7 @@ func (r *RawAPIs) Console(t testing.TB) StreamClient {
 	logAction(t, "Fetching CLI client for %s", r.dut)
 	c, err := console.FetchConsole(context.Background(), r.dut)
 	if err != nil {
-		t.Fatalf("Failed to fetch console client on %v: %v", r.dut, err)
+		report.Fatalf(t, err, "Failed to fetch console client on %v", r.dut)
 	}
 	return c
 }
@@ -17,14 +17,20 @@ package ondatra
 import (
 	"bufio"
 	"golang.org/x/net/context"
+	"encoding/json"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/testing/protocmp"
 	"github.com/openconfig/gnmi/errdiff"
+	"github.com/openconfig/ygot/exampleoc"
+	"github.com/openconfig/ygot/ygot"
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
 	bpb "github.com/openconfig/gnoi/bgp"
 	cpb "github.com/openconfig/gnoi/cert"
@@ -41,7 +47,9 @@ import (
 	wpb "github.com/openconfig/gnoi/wavelength_router"
 	"github.com/openconfig/ondatra/fakes/fakestreamclient"
 	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/gnmigen/genutil"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/negtest"
 
 )
@@ -135,6 +143,83 @@ func TestPushConfig(t *testing.T) {
 			WithVarMap(map[string]string{"x": "apple", "y": "orange"}),
 		wantConfig: `hello apple and orange`,
 		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc:       "vendor text",
+		config:     dutArista.Config().New().WithVendorText(ARISTA, "Arista config").WithVendorText(CIENA, "Ciena config"),
+		wantConfig: "Arista config",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "vendor file",
+		config: dutArista.Config().New().
+			WithVendorFile(ARISTA, filepath.Join("testdata", "example_config_1.txt")).
+			WithOpenConfigText("Openconfig"),
+		wantConfig: "example_config_1",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "most specific variant",
+		config: dutArista.Config().New().
+			WithAristaText("Arista config").
+			WithVendorVariantText(ARISTA, "arista.*", "", "model config").
+			WithVendorVariantText(ARISTA, "arista.*", "aristaVersion", "model and version config").
+			WithVendorVariantText(ARISTA, "otherModel", "", "other model config").
+			WithVendorVariantText(CISCO, "", "", "Cisco config"),
+		wantConfig: "model and version config",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "variant must match whole value",
+		config: dutArista.Config().New().
+			WithOpenConfigText("Openconfig").
+			WithVendorVariantText(ARISTA, "arista", "", "partial match config"),
+		wantConfig: "Openconfig",
+		wantOpts:   &binding.ConfigOptions{OpenConfig: true},
+	}, {
+		desc: "variant file",
+		config: dutArista.Config().New().
+			WithAristaText("Arista config").
+			WithVendorVariantFile(ARISTA, "", "arista.*", filepath.Join("testdata", "example_config_2.txt")),
+		wantConfig: "example_config_2",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "openconfig struct",
+		config: dutArista.Config().New().
+			WithOpenConfigStruct(&exampleoc.Device{Interface: map[string]*exampleoc.Interface{
+				"Et1": {Name: ygot.String("Et1"), Description: ygot.String(`{{ not a template }}`)},
+			}}),
+		wantConfig: `{
+  "openconfig-interfaces:interfaces": {
+    "interface": [
+      {
+        "config": {
+          "description": "{{ not a template }}",
+          "name": "Et1"
+        },
+        "name": "Et1"
+      }
+    ]
+  }
+}`,
+		wantOpts: &binding.ConfigOptions{OpenConfig: true},
+	}, {
+		desc: "device facts template",
+		config: dutArista.Config().New().
+			WithAristaText(`hostname {{ .Name }} ({{ .ID }}, {{ .Vendor }})
+{{- range .Ports }}
+{{ .ID }} {{ .Name }} {{ .Speed }} -> {{ .PeerDevice }}:{{ .PeerPort }} {{ .PeerPortName }}
+{{- end }}`),
+		wantConfig: "hostname pf01.xxx01 (dut, ARISTA)\nport1 Et1/2/3 10GB -> ate:port1 1/1\nport2 Et4/5/6  -> : ",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "helpers template",
+		config: dutArista.Config().New().
+			WithAristaText(`{{ range $i, $p := .Ports }}{{ upper $p.ID }} {{ cidrhost "192.0.2.0/24" (add $i 1) }} {{ ipadd "192.0.2.255" $i }}; {{ end }}`),
+		wantConfig: "PORT1 192.0.2.1 192.0.2.255; PORT2 192.0.2.2 192.0.3.0; ",
+		wantOpts:   &binding.ConfigOptions{},
+	}, {
+		desc: "include template",
+		config: dutArista.Config().New().
+			WithAristaText(`{{ range .Ports }}{{ include "testdata/interface.tmpl" . }}{{ end }}`),
+		wantConfig: "interface Et1/2/3\n description to ate 1/1\ninterface Et4/5/6\n",
+		wantOpts:   &binding.ConfigOptions{},
 	}}
 
 	for _, tt := range testsPass {
@@ -179,6 +264,18 @@ func TestPushConfigErrors(t *testing.T) {
 		desc:         "var has no value",
 		config:       dutArista.Config().New().WithAristaText(`{{ var "key1" }}`),
 		wantFatalMsg: "No value for key",
+	}, {
+		desc:         "invalid variant regular expression",
+		config:       dutArista.Config().New().WithVendorVariantText(ARISTA, "(", "", "config"),
+		wantFatalMsg: "invalid regular expression",
+	}, {
+		desc:         "address outside of CIDR",
+		config:       dutArista.Config().New().WithAristaText(`{{ cidrhost "192.0.2.0/30" 4 }}`),
+		wantFatalMsg: "outside of CIDR",
+	}, {
+		desc:         "included file does not exist",
+		config:       dutArista.Config().New().WithAristaText(`{{ include "testdata/nonexistent.tmpl" . }}`),
+		wantFatalMsg: "error reading included template",
 	}}
 
 	for _, tt := range testsFail {
@@ -189,10 +286,123 @@ func TestPushConfigErrors(t *testing.T) {
 			if !strings.Contains(got, tt.wantFatalMsg) {
 				t.Errorf("Push(t) failed with message %q, want %q", got, tt.wantFatalMsg)
 			}
+			if strings.Contains(got, report.InfraFailPrefix) {
+				t.Errorf("Push(t) failed with message %q, want no infrastructure failure", got)
+			}
 		})
 	}
 }
 
+func TestPushConfigInfraFail(t *testing.T) {
+	initDUTFakes(t)
+	fakeBind.ConfigPusher = func(context.Context, *reservation.DUT, string, *binding.ConfigOptions) error {
+		return errors.New("lab is down")
+	}
+	var handled error
+	fakeBind.InfraFailHandler = func(err error) error {
+		handled = err
+		return errors.Wrap(err, "see lab dashboard")
+	}
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		DUT(t, "dut").Config().New().WithAristaText("config").Push(t)
+	})
+	if handled == nil || handled.Error() != "lab is down" {
+		t.Errorf("Push(t) passed %v to HandleInfraFail, want the binding error", handled)
+	}
+	want := report.InfraFailPrefix + "Push(t) on"
+	if !strings.HasPrefix(got, want) || !strings.Contains(got, "see lab dashboard: lab is down") {
+		t.Errorf("Push(t) failed with message %q, want prefix %q and the decorated error", got, want)
+	}
+}
+
+func TestGetInfraFail(t *testing.T) {
+	initDUTFakes(t)
+	fakeBind.GNMIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (gpb.GNMIClient, error) {
+		return nil, errors.New("lab is down")
+	}
+	var handled error
+	fakeBind.InfraFailHandler = func(err error) error {
+		handled = err
+		return err
+	}
+	path := ygot.NewNodePath([]string{"interfaces"}, nil, ygot.NewDeviceRootBase("dut"))
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		genutil.Get(t, path, false)
+	})
+	if handled == nil || !strings.Contains(handled.Error(), "lab is down") {
+		t.Errorf("Get(t) passed %v to HandleInfraFail, want the binding error", handled)
+	}
+	want := report.InfraFailPrefix + "Get(t) at path"
+	if !strings.HasPrefix(got, want) || !strings.Contains(got, "lab is down") {
+		t.Errorf("Get(t) failed with message %q, want prefix %q and the binding error", got, want)
+	}
+}
+
+func TestPushConfigSubtree(t *testing.T) {
+	fg := initConfigGNMI(t, "{}")
+	// The name of a subinterface may contain characters that are special in
+	// path strings.
+	const name = "Et1/1[unit=2]"
+	path := ygot.NewNodePath([]string{"interfaces", "interface"}, map[string]interface{}{"name": name}, ygot.NewDeviceRootBase("dut"))
+	intf := &exampleoc.Interface{Name: ygot.String(name), Enabled: ygot.Bool(false)}
+	config := DUT(t, "dut").Config().New().WithOpenConfigStructAt(path, intf)
+	wantPath := &gpb.Path{Origin: "openconfig", Elem: []*gpb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": name}},
+	}}
+	const wantVal = `{"openconfig-interfaces:name": "Et1/1[unit=2]", "openconfig-interfaces:config": {"name": "Et1/1[unit=2]", "enabled": false}}`
+
+	tests := []struct {
+		desc       string
+		push       func(t testing.TB)
+		wantUpdate bool
+	}{{
+		desc: "push",
+		push: func(t testing.TB) { config.Push(t) },
+	}, {
+		desc:       "append",
+		push:       func(t testing.TB) { config.Append(t) },
+		wantUpdate: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			gotConfig = ""
+			tt.push(t)
+			if gotConfig != "" {
+				t.Errorf("%s(t) pushed config %q through the binding, want a gNMI set", tt.desc, gotConfig)
+			}
+			req := fg.replaced()
+			upds := req.GetReplace()
+			if tt.wantUpdate {
+				upds = req.GetUpdate()
+			}
+			if len(upds) != 1 || len(req.GetReplace())+len(req.GetUpdate()) != 1 {
+				t.Fatalf("%s(t) got set request %v, want a single set of the subtree", tt.desc, req)
+			}
+			if diff := cmp.Diff(wantPath, upds[0].GetPath(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s(t) got set path diff (-want +got):\n%s", tt.desc, diff)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(upds[0].GetVal().GetJsonIetfVal(), &got); err != nil {
+				t.Fatalf("%s(t) set invalid JSON: %v", tt.desc, err)
+			}
+			if err := json.Unmarshal([]byte(wantVal), &want); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("%s(t) got set value diff (-want +got):\n%s", tt.desc, diff)
+			}
+		})
+	}
+
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		config.DryRunPush(t)
+	})
+	if want := "cannot dry-run the openconfig subtree"; !strings.Contains(got, want) {
+		t.Errorf("DryRunPush(t) of a subtree failed with message %q, want %q", got, want)
+	}
+}
+
 func TestAppendConfig(t *testing.T) {
 	initDUTFakes(t)
 	gotConfig = ""
@@ -208,6 +418,210 @@ func TestAppendConfig(t *testing.T) {
 	}
 }
 
+// fakeConfigGNMI serves the running config of a DUT and records a config
+// replace.
+type fakeConfigGNMI struct {
+	gpb.GNMIClient
+	running string
+	mu      sync.Mutex
+	setReq  *gpb.SetRequest
+}
+
+func (f *fakeConfigGNMI) Get(context.Context, *gpb.GetRequest, ...grpc.CallOption) (*gpb.GetResponse, error) {
+	return &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(f.running)}},
+		}},
+	}}}, nil
+}
+
+func (f *fakeConfigGNMI) Set(_ context.Context, req *gpb.SetRequest, _ ...grpc.CallOption) (*gpb.SetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setReq = req
+	return &gpb.SetResponse{}, nil
+}
+
+func (f *fakeConfigGNMI) replaced() *gpb.SetRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.setReq
+}
+
+func initConfigGNMI(t *testing.T, running string) *fakeConfigGNMI {
+	t.Helper()
+	initDUTFakes(t)
+	fg := &fakeConfigGNMI{running: running}
+	fakeBind.GNMIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (gpb.GNMIClient, error) {
+		return fg, nil
+	}
+	return fg
+}
+
+func TestDryRunConfig(t *testing.T) {
+	initConfigGNMI(t, `{"system":{"config":{"hostname":"a","domain-name":"x"}}}`)
+	gotConfig = ""
+	config := DUT(t, "dut").Config().New().WithOpenConfigText(`{"system":{"config":{"hostname":"b"}}}`)
+
+	res := config.DryRunPush(t)
+	if got, want := res.Config, `{"system":{"config":{"hostname":"b"}}}`; got != want {
+		t.Errorf("DryRunPush(t) got config %q, want %q", got, want)
+	}
+	wantDiff := ` {
+   "system": {
+     "config": {
+-      "domain-name": "x",
+-      "hostname": "a"
++      "hostname": "b"
+     }
+   }
+ }
+`
+	if diff := cmp.Diff(wantDiff, res.Diff); diff != "" {
+		t.Errorf("DryRunPush(t) got unexpected diff (-want,+got):\n%s", diff)
+	}
+
+	res = config.DryRunAppend(t)
+	wantDiff = ` {
+   "system": {
+     "config": {
+       "domain-name": "x",
+-      "hostname": "a"
++      "hostname": "b"
+     }
+   }
+ }
+`
+	if diff := cmp.Diff(wantDiff, res.Diff); diff != "" {
+		t.Errorf("DryRunAppend(t) got unexpected diff (-want,+got):\n%s", diff)
+	}
+
+	if res := DUT(t, "dut").Config().New().WithOpenConfigText(`{"system":{"config":{"domain-name":"x"}}}`).DryRunAppend(t); res.Diff != "" {
+		t.Errorf("DryRunAppend(t) of the running config got diff %q, want none", res.Diff)
+	}
+	if gotConfig != "" {
+		t.Errorf("dry runs pushed config %q, want no push", gotConfig)
+	}
+
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		DUT(t, "dut").Config().New().WithAristaText("hostname b").DryRunPush(t)
+	})
+	if want := "cannot dry-run vendor config"; !strings.Contains(got, want) {
+		t.Errorf("DryRunPush(t) of vendor config failed with message %q, want %q", got, want)
+	}
+}
+
+func TestFetchConfig(t *testing.T) {
+	const running = `{"system":{"config":{"hostname":"a"}}}`
+	initConfigGNMI(t, running)
+	if got := DUT(t, "dut").Config().FetchOpenConfig(t); got != running {
+		t.Errorf("FetchOpenConfig(t) got %s, want %s", got, running)
+	}
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		DUT(t, "dut").Config().FetchVendorConfig(t)
+	})
+	if want := "cannot fetch the vendor config"; !strings.Contains(got, want) {
+		t.Errorf("FetchVendorConfig(t) failed with message %q, want %q", got, want)
+	}
+}
+
+func TestSaveAndRestoreConfig(t *testing.T) {
+	const saved = `{"system":{"config":{"hostname":"a"}}}`
+	fg := initConfigGNMI(t, saved)
+	config := DUT(t, "dut").Config()
+	config.Save(t, "clean")
+	fg.running = `{"system":{"config":{"hostname":"b"}}}`
+	config.Restore(t, "clean")
+	req := fg.replaced()
+	if len(req.GetReplace()) != 1 {
+		t.Fatalf("Restore(t) did not replace the config, got set request %v", req)
+	}
+	if got := string(req.GetReplace()[0].GetVal().GetJsonIetfVal()); got != saved {
+		t.Errorf("Restore(t) replaced the config with %s, want %s", got, saved)
+	}
+
+	got := negtest.ExpectFatal(t, func(t testing.TB) {
+		config.Restore(t, "unsaved")
+	})
+	if want := `no checkpoint "unsaved"`; !strings.Contains(got, want) {
+		t.Errorf("Restore(t) of an unsaved checkpoint failed with message %q, want %q", got, want)
+	}
+}
+
+func TestPushConfirmed(t *testing.T) {
+	const running = `{"system":{"config":{"hostname":"a"}}}`
+	const pushed = `{"system":{"config":{"hostname":"b"}}}`
+	fg := initConfigGNMI(t, running)
+	config := DUT(t, "dut").Config().New().WithOpenConfigText(pushed)
+
+	t.Run("confirmed", func(t *testing.T) {
+		gotConfig = ""
+		config.PushConfirmed(t, time.Hour).Confirm(t)
+		if gotConfig != pushed {
+			t.Errorf("PushConfirmed(t) pushed config %q, want %q", gotConfig, pushed)
+		}
+		if req := fg.replaced(); req != nil {
+			t.Errorf("PushConfirmed(t) rolled back a confirmed push: %v", req)
+		}
+	})
+
+	t.Run("unconfirmed at test end", func(t *testing.T) {
+		t.Run("push", func(t *testing.T) {
+			config.PushConfirmed(t, time.Hour)
+		})
+		req := fg.replaced()
+		if len(req.GetReplace()) != 1 {
+			t.Fatalf("PushConfirmed(t) did not roll back the config at the end of the test, got set request %v", req)
+		}
+		if got := string(req.GetReplace()[0].GetVal().GetJsonIetfVal()); got != running {
+			t.Errorf("PushConfirmed(t) rolled back to config %s, want %s", got, running)
+		}
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		commit := config.AppendConfirmed(t, time.Millisecond)
+		if diff := cmp.Diff(&binding.ConfigOptions{OpenConfig: true, Append: true}, gotOpts); diff != "" {
+			t.Errorf("AppendConfirmed(t) got unexpected options diff(-want,+got):\n %s", diff)
+		}
+		time.Sleep(10 * time.Millisecond)
+		got := negtest.ExpectFatal(t, func(t testing.TB) {
+			commit.Confirm(t)
+		})
+		if want := "rolled back"; !strings.Contains(got, want) {
+			t.Errorf("Confirm(t) after the timeout failed with message %q, want %q", got, want)
+		}
+		req := fg.replaced()
+		if len(req.GetReplace()) != 1 {
+			t.Fatalf("AppendConfirmed(t) did not roll back the config, got set request %v", req)
+		}
+		if got := string(req.GetReplace()[0].GetVal().GetJsonIetfVal()); got != running {
+			t.Errorf("AppendConfirmed(t) rolled back to config %s, want %s", got, running)
+		}
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		got := negtest.ExpectFatal(t, func(t testing.TB) {
+			config.PushConfirmed(t, 0)
+		})
+		if want := "positive duration"; !strings.Contains(got, want) {
+			t.Errorf("PushConfirmed(t, 0) failed with message %q, want %q", got, want)
+		}
+	})
+
+	t.Run("vendor config", func(t *testing.T) {
+		got := negtest.ExpectFatal(t, func(t testing.TB) {
+			DUT(t, "dut").Config().New().WithAristaText("hostname b").PushConfirmed(t, time.Hour)
+		})
+		if want := "does not support"; !strings.Contains(got, want) {
+			t.Errorf("PushConfirmed(t) of vendor config failed with message %q, want %q", got, want)
+		}
+		if strings.Contains(got, report.InfraFailPrefix) {
+			t.Errorf("PushConfirmed(t) of vendor config failed with message %q, want no infrastructure failure", got)
+		}
+	})
+}
+
 func TestGNMI(t *testing.T) {
 	initDUTFakes(t)
 	want := struct{ gpb.GNMIClient }{}
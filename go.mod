@@ -7,6 +7,7 @@ require (
 	github.com/golang/protobuf v1.5.2
 	github.com/google/go-cmp v0.5.6
 	github.com/google/kne v0.0.0-20210909173245-efe949af4d64
+	github.com/kylelemons/godebug v1.1.0
 	github.com/openconfig/gnmi v0.0.0-20210707145734-c69a5df04b53
 	github.com/openconfig/gnoi v0.0.0-20210902152759-d6d0463a58fe
 	github.com/openconfig/goyang v0.2.9
@@ -24,7 +25,6 @@ require (
 require (
 	github.com/cenkalti/backoff/v4 v4.1.0 // indirect
 	github.com/google/uuid v1.2.0 // indirect
-	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/openconfig/grpctunnel v0.0.0-20210610163803-fde4a9dc048d // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e // indirect
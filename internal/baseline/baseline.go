@@ -0,0 +1,217 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package baseline captures the baseline config of the reserved DUTs and
+// restores the DUTs to it, and saves and restores named config checkpoints.
+package baseline
+
+import (
+	"golang.org/x/net/context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var (
+	mu          sync.Mutex
+	snapshots   = make(map[*reservation.DUT][]byte)
+	checkpoints = make(map[*reservation.DUT]map[string][]byte)
+)
+
+// Capture snapshots the running config of every DUT in the reservation.
+func Capture(ctx context.Context, res *reservation.Reservation) error {
+	return captureAll(ctx, binding.Get(), res)
+}
+
+// Restore restores the DUT to the config snapshotted by Capture.
+func Restore(ctx context.Context, dut *reservation.DUT) error {
+	return restore(ctx, binding.Get(), dut)
+}
+
+// Snapshot returns the running config of the DUT, fetched via gNMI, as JSON.
+// Unlike Capture, it ignores any binding.Baseliner and does not record the
+// config as the baseline.
+func Snapshot(ctx context.Context, dut *reservation.DUT) ([]byte, error) {
+	return snapshot(ctx, dut)
+}
+
+// Replace replaces the running config of the DUT via gNMI with a config
+// returned by Snapshot.
+func Replace(ctx context.Context, dut *reservation.DUT, config []byte) error {
+	return replace(ctx, dut, config)
+}
+
+// SaveCheckpoint saves the running config of the DUT as the named checkpoint,
+// replacing any checkpoint of the same name.
+func SaveCheckpoint(ctx context.Context, dut *reservation.DUT, name string) error {
+	return saveCheckpoint(ctx, binding.Get(), dut, name)
+}
+
+// RestoreCheckpoint restores the DUT to the config saved by SaveCheckpoint.
+func RestoreCheckpoint(ctx context.Context, dut *reservation.DUT, name string) error {
+	return restoreCheckpoint(ctx, binding.Get(), dut, name)
+}
+
+// Reset discards all captured snapshots and checkpoints.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	snapshots = make(map[*reservation.DUT][]byte)
+	checkpoints = make(map[*reservation.DUT]map[string][]byte)
+}
+
+func captureAll(ctx context.Context, b binding.Binding, res *reservation.Reservation) error {
+	var ids []string
+	for id := range res.DUTs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := capture(ctx, b, res.DUTs[id]); err != nil {
+			return errors.Wrapf(err, "failed to capture baseline config of DUT %q", id)
+		}
+	}
+	return nil
+}
+
+func restore(ctx context.Context, b binding.Binding, dut *reservation.DUT) error {
+	if bl, ok := b.(binding.Baseliner); ok {
+		return bl.RestoreBaseline(ctx, dut)
+	}
+	mu.Lock()
+	config, ok := snapshots[dut]
+	mu.Unlock()
+	if !ok {
+		return errors.Errorf("no baseline config captured for DUT %s", dut.Name)
+	}
+	return replace(ctx, dut, config)
+}
+
+func saveCheckpoint(ctx context.Context, b binding.Binding, dut *reservation.DUT, name string) error {
+	if cp, ok := b.(binding.Checkpointer); ok {
+		return cp.SaveCheckpoint(ctx, dut, name)
+	}
+	config, err := snapshot(ctx, dut)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if checkpoints[dut] == nil {
+		checkpoints[dut] = make(map[string][]byte)
+	}
+	checkpoints[dut][name] = config
+	return nil
+}
+
+func restoreCheckpoint(ctx context.Context, b binding.Binding, dut *reservation.DUT, name string) error {
+	if cp, ok := b.(binding.Checkpointer); ok {
+		return cp.RestoreCheckpoint(ctx, dut, name)
+	}
+	mu.Lock()
+	config, ok := checkpoints[dut][name]
+	mu.Unlock()
+	if !ok {
+		return usererr.New("no checkpoint %q saved for DUT %s", name, dut.Name)
+	}
+	return replace(ctx, dut, config)
+}
+
+func replace(ctx context.Context, dut *reservation.DUT, config []byte) error {
+	req := &gpb.SetRequest{
+		Replace: []*gpb.Update{{
+			Path: &gpb.Path{},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: config}},
+		}},
+	}
+	if _, err := gnmiclient.Set(ctx, dut, req); err != nil {
+		return errors.Wrapf(err, "failed to replace config of DUT %s", dut.Name)
+	}
+	return nil
+}
+
+func capture(ctx context.Context, b binding.Binding, dut *reservation.DUT) error {
+	if bl, ok := b.(binding.Baseliner); ok {
+		return bl.CaptureBaseline(ctx, dut)
+	}
+	config, err := snapshot(ctx, dut)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	snapshots[dut] = config
+	return nil
+}
+
+func snapshot(ctx context.Context, dut *reservation.DUT) ([]byte, error) {
+	var resp *gpb.GetResponse
+	if err := retry.Do(ctx, func() error {
+		c, err := gnmiclient.Fetch(ctx, dut)
+		if err != nil {
+			return err
+		}
+		resp, err = c.Get(ctx, &gpb.GetRequest{
+			Path:     []*gpb.Path{{}},
+			Type:     gpb.GetRequest_CONFIG,
+			Encoding: gpb.Encoding_JSON_IETF,
+		})
+		return err
+	}, func() { gnmiclient.Evict(dut) }); err != nil {
+		return nil, errors.Wrapf(err, "failed to get config of DUT %s", dut.Name)
+	}
+	return rootJSON(resp)
+}
+
+// rootJSON merges the updates in a Get response into a single JSON object
+// for the root path. Devices may return the whole tree in a single update of
+// the root, or one update for each top-level container.
+func rootJSON(resp *gpb.GetResponse) ([]byte, error) {
+	root := make(map[string]json.RawMessage)
+	for _, n := range resp.GetNotification() {
+		if len(n.GetPrefix().GetElem()) > 0 {
+			return nil, errors.Errorf("unexpected prefix in Get response: %v", n.GetPrefix())
+		}
+		for _, u := range n.GetUpdate() {
+			data := u.GetVal().GetJsonIetfVal()
+			if data == nil {
+				return nil, errors.Errorf("update at %v has no JSON_IETF value: %v", u.GetPath(), u.GetVal())
+			}
+			switch elems := u.GetPath().GetElem(); len(elems) {
+			case 0:
+				m := make(map[string]json.RawMessage)
+				if err := json.Unmarshal(data, &m); err != nil {
+					return nil, errors.Wrap(err, "root config is not a JSON object")
+				}
+				for k, v := range m {
+					root[k] = v
+				}
+			case 1:
+				root[elems[0].GetName()] = data
+			default:
+				return nil, errors.Errorf("unexpected non-top-level update in Get response: %v", u.GetPath())
+			}
+		}
+	}
+	return json.Marshal(root)
+}
@@ -0,0 +1,296 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline
+
+import (
+	"golang.org/x/net/context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/reservemain"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+type fakeGNMI struct {
+	gpb.GNMIClient
+	// getErr is returned by the next Get, if set.
+	getErr  error
+	getResp *gpb.GetResponse
+	getReq  *gpb.GetRequest
+	setReq  *gpb.SetRequest
+}
+
+func (f *fakeGNMI) Get(_ context.Context, req *gpb.GetRequest, _ ...grpc.CallOption) (*gpb.GetResponse, error) {
+	f.getReq = req
+	if err := f.getErr; err != nil {
+		f.getErr = nil
+		return nil, err
+	}
+	return f.getResp, nil
+}
+
+func (f *fakeGNMI) Set(_ context.Context, req *gpb.SetRequest, _ ...grpc.CallOption) (*gpb.SetResponse, error) {
+	f.setReq = req
+	return &gpb.SetResponse{}, nil
+}
+
+func jsonUpdate(path *gpb.Path, js string) *gpb.Update {
+	return &gpb.Update{Path: path, Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(js)}}}
+}
+
+var fakeBind = &fakebind.Binding{}
+
+func init() {
+	binding.Init(fakeBind)
+}
+
+func initFakeGNMI(t *testing.T, resp *gpb.GetResponse) (*fakebind.Binding, *fakeGNMI, *reservation.Reservation) {
+	t.Helper()
+	Reset()
+	dut := &reservation.DUT{&reservation.Dims{Name: "dut1"}}
+	res := &reservation.Reservation{DUTs: map[string]*reservation.DUT{"dut": dut}}
+	fg := &fakeGNMI{getResp: resp}
+	fakeBind.Reservation = res
+	fakeBind.GNMIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (gpb.GNMIClient, error) {
+		return fg, nil
+	}
+	return fakeBind, fg, res
+}
+
+func TestCaptureAndRestore(t *testing.T) {
+	tests := []struct {
+		desc string
+		resp *gpb.GetResponse
+		want string
+	}{{
+		desc: "root update",
+		resp: &gpb.GetResponse{Notification: []*gpb.Notification{{
+			Update: []*gpb.Update{jsonUpdate(&gpb.Path{}, `{"a":{"b":1},"c":"d"}`)},
+		}}},
+		want: `{"a":{"b":1},"c":"d"}`,
+	}, {
+		desc: "top-level updates",
+		resp: &gpb.GetResponse{Notification: []*gpb.Notification{{
+			Update: []*gpb.Update{
+				jsonUpdate(&gpb.Path{Elem: []*gpb.PathElem{{Name: "a"}}}, `{"b":1}`),
+				jsonUpdate(&gpb.Path{Elem: []*gpb.PathElem{{Name: "c"}}}, `"d"`),
+			},
+		}}},
+		want: `{"a":{"b":1},"c":"d"}`,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			b, fg, res := initFakeGNMI(t, test.resp)
+			if err := captureAll(context.Background(), b, res); err != nil {
+				t.Fatalf("Capture() failed: %v", err)
+			}
+			if got, want := fg.getReq.GetType(), gpb.GetRequest_CONFIG; got != want {
+				t.Errorf("Capture() got request type %v, want %v", got, want)
+			}
+			if err := restore(context.Background(), b, res.DUTs["dut"]); err != nil {
+				t.Fatalf("Restore() failed: %v", err)
+			}
+			if got := len(fg.setReq.GetReplace()); got != 1 {
+				t.Fatalf("Restore() got %d replaces, want 1", got)
+			}
+			r := fg.setReq.GetReplace()[0]
+			if got := len(r.GetPath().GetElem()); got != 0 {
+				t.Errorf("Restore() got replace path with %d elems, want root", got)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(r.GetVal().GetJsonIetfVal(), &got); err != nil {
+				t.Fatalf("Restore() replaced with invalid JSON: %v", err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Restore() replaced with diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCaptureErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		resp    *gpb.GetResponse
+		wantErr string
+	}{{
+		desc: "not JSON_IETF",
+		resp: &gpb.GetResponse{Notification: []*gpb.Notification{{
+			Update: []*gpb.Update{{Path: &gpb.Path{}, Val: &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{"x"}}}},
+		}}},
+		wantErr: "no JSON_IETF value",
+	}, {
+		desc: "deep update",
+		resp: &gpb.GetResponse{Notification: []*gpb.Notification{{
+			Update: []*gpb.Update{jsonUpdate(&gpb.Path{Elem: []*gpb.PathElem{{Name: "a"}, {Name: "b"}}}, `1`)},
+		}}},
+		wantErr: "non-top-level",
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			b, _, res := initFakeGNMI(t, test.resp)
+			err := captureAll(context.Background(), b, res)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Capture() got error %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestoreWithoutCapture(t *testing.T) {
+	b, _, res := initFakeGNMI(t, nil)
+	if err := restore(context.Background(), b, res.DUTs["dut"]); err == nil {
+		t.Error("Restore() got no error, want error")
+	}
+}
+
+var _ binding.Baseliner = &baselinerBinding{}
+
+type baselinerBinding struct {
+	*fakebind.Binding
+	captured, restored []string
+}
+
+func (b *baselinerBinding) CaptureBaseline(_ context.Context, dut *reservation.DUT) error {
+	b.captured = append(b.captured, dut.Name)
+	return nil
+}
+
+func (b *baselinerBinding) RestoreBaseline(_ context.Context, dut *reservation.DUT) error {
+	b.restored = append(b.restored, dut.Name)
+	return nil
+}
+
+func TestBaseliner(t *testing.T) {
+	Reset()
+	res := &reservation.Reservation{DUTs: map[string]*reservation.DUT{
+		"dut1": &reservation.DUT{&reservation.Dims{Name: "d1"}},
+		"dut2": &reservation.DUT{&reservation.Dims{Name: "d2"}},
+	}}
+	b := &baselinerBinding{Binding: &fakebind.Binding{Reservation: res}}
+	if err := captureAll(context.Background(), b, res); err != nil {
+		t.Fatalf("Capture() failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"d1", "d2"}, b.captured); diff != "" {
+		t.Errorf("Capture() captured diff (-want +got):\n%s", diff)
+	}
+	if err := restore(context.Background(), b, res.DUTs["dut2"]); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"d2"}, b.restored); diff != "" {
+		t.Errorf("Restore() restored diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	b, fg, res := initFakeGNMI(t, &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Update: []*gpb.Update{jsonUpdate(&gpb.Path{}, `{"a":1}`)},
+	}}})
+	dut := res.DUTs["dut"]
+	if err := saveCheckpoint(context.Background(), b, dut, "before"); err != nil {
+		t.Fatalf("SaveCheckpoint() failed: %v", err)
+	}
+	fg.getResp = &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Update: []*gpb.Update{jsonUpdate(&gpb.Path{}, `{"a":2}`)},
+	}}}
+	if err := saveCheckpoint(context.Background(), b, dut, "after"); err != nil {
+		t.Fatalf("SaveCheckpoint() failed: %v", err)
+	}
+	if err := restoreCheckpoint(context.Background(), b, dut, "before"); err != nil {
+		t.Fatalf("RestoreCheckpoint() failed: %v", err)
+	}
+	if got := len(fg.setReq.GetReplace()); got != 1 {
+		t.Fatalf("RestoreCheckpoint() got %d replaces, want 1", got)
+	}
+	if got, want := string(fg.setReq.GetReplace()[0].GetVal().GetJsonIetfVal()), `{"a":1}`; got != want {
+		t.Errorf("RestoreCheckpoint() replaced with %s, want %s", got, want)
+	}
+	if err := restoreCheckpoint(context.Background(), b, dut, "unknown"); err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Errorf("RestoreCheckpoint() of unknown checkpoint got error %v, want error containing %q", err, "no checkpoint")
+	}
+}
+
+var _ binding.Checkpointer = &checkpointerBinding{}
+
+type checkpointerBinding struct {
+	*fakebind.Binding
+	saved, restored []string
+}
+
+func (b *checkpointerBinding) SaveCheckpoint(_ context.Context, dut *reservation.DUT, name string) error {
+	b.saved = append(b.saved, dut.Name+"/"+name)
+	return nil
+}
+
+func (b *checkpointerBinding) RestoreCheckpoint(_ context.Context, dut *reservation.DUT, name string) error {
+	b.restored = append(b.restored, dut.Name+"/"+name)
+	return nil
+}
+
+func TestCheckpointer(t *testing.T) {
+	Reset()
+	dut := &reservation.DUT{&reservation.Dims{Name: "d1"}}
+	b := &checkpointerBinding{Binding: &fakebind.Binding{}}
+	if err := saveCheckpoint(context.Background(), b, dut, "cp"); err != nil {
+		t.Fatalf("SaveCheckpoint() failed: %v", err)
+	}
+	if err := restoreCheckpoint(context.Background(), b, dut, "cp"); err != nil {
+		t.Fatalf("RestoreCheckpoint() failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"d1/cp"}, b.saved); diff != "" {
+		t.Errorf("SaveCheckpoint() saved diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"d1/cp"}, b.restored); diff != "" {
+		t.Errorf("RestoreCheckpoint() restored diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestSnapshotRetry(t *testing.T) {
+	defer func(backoff time.Duration) { *reservemain.RetryBackoff = backoff }(*reservemain.RetryBackoff)
+	*reservemain.RetryBackoff = 0
+	_, fg, res := initFakeGNMI(t, &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Update: []*gpb.Update{jsonUpdate(&gpb.Path{}, `{"a":1}`)},
+	}}})
+	dials := 0
+	dialer := fakeBind.GNMIDialer
+	fakeBind.GNMIDialer = func(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (gpb.GNMIClient, error) {
+		dials++
+		return dialer(ctx, dut, opts...)
+	}
+	fg.getErr = status.Error(codes.Unavailable, "connection dropped")
+	got, err := Snapshot(context.Background(), res.DUTs["dut"])
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if want := `{"a":1}`; string(got) != want {
+		t.Errorf("Snapshot() got %s, want %s", got, want)
+	}
+	if dials != 2 {
+		t.Errorf("Snapshot() dialed gNMI %d times, want 2", dials)
+	}
+}
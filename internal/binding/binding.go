@@ -79,7 +79,7 @@ func Get() Binding {
 // "infrastructure failures," meaning the binding implementation itself is
 // responsible for the error, not the user. To generate a user error instead,
 // the binding should create or wrap an error using the "usererr" package.
-// All infrastructure failure errors are passed to ReportInfraFail.
+// All infrastructure failure errors are passed to HandleInfraFail.
 type Binding interface {
 
 	// Reserve reserves resources matching the criteria in the specified testbed.
@@ -108,6 +108,8 @@ type Binding interface {
 	// If the openconfig option is true, the config is in openconfig JSON syntax.
 	// If the append option is true, the config is appended to the existing config;
 	// otherwise the existing config is replaced with the provided config.
+	// The dry-run and confirm-timeout options are only set if the binding
+	// implements ConfigCommitter.
 	PushConfig(ctx context.Context, dut *reservation.DUT, config string, opts *ConfigOptions) error
 
 	// DialGNMI creates a client connection to the specified DUT's gNMI endpoint.
@@ -168,6 +170,10 @@ type Binding interface {
 	// If an error is a failure of the Ondatra server or binding implementation
 	// rather than user error, it will be passed to HandleInfraFail, which can
 	// classify the error as such to distinguish it from a genuine test failure.
+	// The returned error is reported to the test in place of the given error,
+	// so the implementation may decorate it, e.g. with lab diagnostics; if the
+	// returned error is nil, the given error is reported unchanged. Either way,
+	// the test is marked as failed by an infrastructure failure.
 	HandleInfraFail(err error) error
 
 	// SetATEPortState sets the enabled state of a physical port on the ATE.
@@ -180,9 +186,101 @@ type Binding interface {
 	RestartRouting(dut *reservation.DUT) error
 }
 
+// Baseliner is an optional interface that a Binding may implement to override
+// how the baseline config of a DUT is captured and restored. By default, the
+// framework snapshots the running config with a gNMI Get of the root path and
+// restores it with a gNMI Set that replaces the root. A binding may implement
+// this interface to use a faster, vendor-native checkpoint and rollback.
+type Baseliner interface {
+	// CaptureBaseline snapshots the current running config of the DUT.
+	// It is called once for every DUT, after the testbed is reserved.
+	CaptureBaseline(ctx context.Context, dut *reservation.DUT) error
+
+	// RestoreBaseline restores the DUT to the config snapshotted by the most
+	// recent call to CaptureBaseline.
+	RestoreBaseline(ctx context.Context, dut *reservation.DUT) error
+}
+
+// Checkpointer is an optional interface that a Binding may implement to
+// override how the config of a DUT is saved to and restored from a named
+// checkpoint. By default, the framework keeps a snapshot of the running config
+// from a gNMI Get of the root path for every checkpoint, and restores it with
+// a gNMI Set that replaces the root. A binding may implement this interface to
+// use vendor-native checkpoints and rollback instead.
+type Checkpointer interface {
+	// SaveCheckpoint saves the running config of the DUT to the named
+	// checkpoint, replacing any config saved to it before.
+	SaveCheckpoint(ctx context.Context, dut *reservation.DUT, name string) error
+
+	// RestoreCheckpoint restores the DUT to the config saved to the named
+	// checkpoint.
+	RestoreCheckpoint(ctx context.Context, dut *reservation.DUT, name string) error
+}
+
+// Fetcher is an optional interface that a Binding may implement to let tests
+// attach to a reservation that is already held, e.g. by a standalone
+// reservation command, instead of reserving the testbed. The framework never
+// calls Release on a fetched reservation.
+type Fetcher interface {
+	// FetchReservation returns the existing reservation with the specified ID.
+	// The framework has already verified that the testbed is valid, and will
+	// validate that the returned reservation matches the testbed criteria.
+	FetchReservation(ctx context.Context, tb *opb.Testbed, id string) (*reservation.Reservation, error)
+}
+
+// Heartbeater is an optional interface that a Binding may implement to keep a
+// reservation alive while tests run. The framework calls Heartbeat
+// periodically for as long as the testbed is reserved.
+type Heartbeater interface {
+	// Heartbeat signals that the reservation is still in use and returns the
+	// time at which the reservation expires. A zero time means the binding does
+	// not know, and the framework assumes the reservation lasts for the
+	// requested run time.
+	Heartbeat(ctx context.Context) (time.Time, error)
+}
+
+// Extender is an optional interface that a Binding may implement to let the
+// framework extend a reservation that is about to expire. The framework only
+// extends a reservation if the user allows it with a flag, up to the cap the
+// user sets.
+type Extender interface {
+	// Extend extends the reservation by the specified duration and returns the
+	// time at which the reservation now expires.
+	Extend(ctx context.Context, by time.Duration) (time.Time, error)
+}
+
 // ConfigOptions is a set of options for the config push.
 type ConfigOptions struct {
 	OpenConfig, Append bool
+	// DryRun requests that the config be validated, e.g. loaded into a
+	// candidate config, but not applied.
+	DryRun bool
+	// ConfirmTimeout, if non-zero, requests a commit-confirmed push: the config
+	// must be rolled back unless ConfirmConfig is called within the timeout.
+	ConfirmTimeout time.Duration
+}
+
+// ConfigFetcher is an optional interface that a binding can implement to fetch
+// the running config of a DUT. By default, the framework can only fetch the
+// OpenConfig, with a gNMI Get of the root path.
+type ConfigFetcher interface {
+	// RunningConfig returns the running config of the DUT, as OpenConfig JSON
+	// if openConfig is true and in the vendor's syntax otherwise.
+	RunningConfig(ctx context.Context, dut *reservation.DUT, openConfig bool) (string, error)
+}
+
+// ConfigCommitter is an optional interface that a binding can implement to
+// push config with vendor-native candidate and commit semantics. A binding
+// that implements it must honor the DryRun and ConfirmTimeout options of
+// PushConfig. Otherwise the framework emulates them: it diffs a dry run
+// against the running config, without pushing it, and it rolls back an
+// unconfirmed push by replacing the config via gNMI with a snapshot taken
+// before the push.
+type ConfigCommitter interface {
+	ConfigFetcher
+
+	// ConfirmConfig confirms the last commit-confirmed config push to the DUT.
+	ConfirmConfig(ctx context.Context, dut *reservation.DUT) error
 }
 
 // GNOIClients stores APIs to GNOI services.
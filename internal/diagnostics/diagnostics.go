@@ -0,0 +1,193 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics collects diagnostics from the reserved DUTs, such as
+// their config, key telemetry and the output of a "show tech" CLI command, for
+// debugging a test run that was interrupted.
+package diagnostics
+
+import (
+	"golang.org/x/net/context"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// telemetryPaths are the top-level state containers that are collected as key
+// telemetry, keyed by the name of the file they are written to.
+var telemetryPaths = map[string]string{
+	"interfaces.txt":        "interfaces",
+	"components.txt":        "components",
+	"network-instances.txt": "network-instances",
+}
+
+// showTechCommands are the CLI commands that collect a technical support dump,
+// by vendor. Vendors that are not listed use defaultShowTech.
+var showTechCommands = map[opb.Device_Vendor]string{
+	opb.Device_JUNIPER: "request support information | no-more",
+}
+
+const defaultShowTech = "show tech-support"
+
+// Collect collects diagnostics from every DUT in the reservation into a
+// subdirectory of dir named after the ID of the DUT. A failure to collect one
+// diagnostic does not stop the others from being collected; the returned error
+// lists all the failures.
+func Collect(ctx context.Context, res *reservation.Reservation, dir string) error {
+	var ids []string
+	for id := range res.DUTs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var errs []string
+	for _, id := range ids {
+		for _, err := range collect(ctx, res.DUTs[id], filepath.Join(dir, id)) {
+			errs = append(errs, fmt.Sprintf("DUT %q: %v", id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("failed to collect %d diagnostic(s):\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func collect(ctx context.Context, dut *reservation.DUT, dir string) []error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	write := func(file string, collectFn func() ([]byte, error)) {
+		data, err := collectFn()
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to collect %s", file))
+			return
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		log.Infof("Collected %s of DUT %s", file, dut.Name)
+	}
+	// The client that the tests cached is reused, as a gNMI client cannot be
+	// closed, so dialing another would leak its connection.
+	gnmi, err := gnmiclient.Fetch(ctx, dut)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "failed to dial gNMI"))
+	} else {
+		write("config.txt", func() ([]byte, error) {
+			return get(ctx, gnmi, gpb.GetRequest_CONFIG, &gpb.Path{})
+		})
+		var files []string
+		for file := range telemetryPaths {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			path := &gpb.Path{Elem: []*gpb.PathElem{{Name: telemetryPaths[file]}}}
+			write(file, func() ([]byte, error) {
+				return get(ctx, gnmi, gpb.GetRequest_STATE, path)
+			})
+		}
+	}
+	write("show_tech.txt", func() ([]byte, error) {
+		return showTech(ctx, dut)
+	})
+	return errs
+}
+
+// get performs a gNMI Get and formats the updates in its response, one per
+// line, as the update path followed by its value.
+func get(ctx context.Context, gnmi gpb.GNMIClient, typ gpb.GetRequest_DataType, path *gpb.Path) ([]byte, error) {
+	resp, err := gnmi.Get(ctx, &gpb.GetRequest{
+		Path:     []*gpb.Path{path},
+		Type:     typ,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			elems := append(append([]*gpb.PathElem(nil), n.GetPrefix().GetElem()...), u.GetPath().GetElem()...)
+			fmt.Fprintf(&buf, "%s: %s\n", pathString(elems), valueString(u.GetVal()))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func pathString(elems []*gpb.PathElem) string {
+	var sb strings.Builder
+	for _, e := range elems {
+		sb.WriteString("/" + e.GetName())
+		var keys []string
+		for k := range e.GetKey() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "[%s=%s]", k, e.GetKey()[k])
+		}
+	}
+	if sb.Len() == 0 {
+		return "/"
+	}
+	return sb.String()
+}
+
+func valueString(v *gpb.TypedValue) string {
+	data := v.GetJsonIetfVal()
+	if data == nil {
+		data = v.GetJsonVal()
+	}
+	if data == nil {
+		return fmt.Sprint(v.GetValue())
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
+
+func showTech(ctx context.Context, dut *reservation.DUT) ([]byte, error) {
+	cmd, ok := showTechCommands[dut.Vendor]
+	if !ok {
+		cmd = defaultShowTech
+	}
+	cli, err := binding.Get().DialCLI(ctx, dut)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial CLI")
+	}
+	defer cli.Close()
+	out, err := cli.SendCommand(ctx, cmd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	return []byte(out), nil
+}
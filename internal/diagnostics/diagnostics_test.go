@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"github.com/openconfig/ondatra/fakes/fakestreamclient"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+type fakeGNMI struct {
+	gpb.GNMIClient
+}
+
+// Get responds with the name of the requested path and the data type, and
+// fails for the network instances.
+func (f *fakeGNMI) Get(_ context.Context, req *gpb.GetRequest, _ ...grpc.CallOption) (*gpb.GetResponse, error) {
+	name := "root"
+	if elems := req.GetPath()[0].GetElem(); len(elems) > 0 {
+		name = elems[0].GetName()
+	}
+	if name == "network-instances" {
+		return nil, errors.New("unsupported path")
+	}
+	return &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Prefix: req.GetPath()[0],
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "x", Key: map[string]string{"name": "a"}}}},
+			Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{
+				JsonIetfVal: []byte(`{"type":"` + req.GetType().String() + `","name":"` + name + `"}`),
+			}},
+		}},
+	}}}, nil
+}
+
+func TestCollect(t *testing.T) {
+	res := &reservation.Reservation{DUTs: map[string]*reservation.DUT{
+		"dut1": {&reservation.Dims{Name: "dut1", Vendor: opb.Device_ARISTA}},
+		"dut2": {&reservation.Dims{Name: "dut2", Vendor: opb.Device_JUNIPER}},
+	}}
+	var dials int
+	binding.Init(&fakebind.Binding{
+		GNMIDialer: func(context.Context, *reservation.DUT, ...grpc.DialOption) (gpb.GNMIClient, error) {
+			dials++
+			return &fakeGNMI{}, nil
+		},
+		CLIDialer: func(context.Context, *reservation.DUT, ...grpc.DialOption) (binding.StreamClient, error) {
+			return fakestreamclient.New(), nil
+		},
+	})
+	dir := t.TempDir()
+	err := Collect(context.Background(), res, dir)
+	if err == nil {
+		t.Fatalf("Collect() got no error, want the failures to get the network instances")
+	}
+	for _, want := range []string{"2 diagnostic(s)", `DUT "dut1": failed to collect network-instances.txt: unsupported path`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Collect() got error %v, want it to contain %q", err, want)
+		}
+	}
+
+	tests := []struct {
+		dut, file, want string
+	}{
+		{"dut1", "config.txt", "/x[name=a]: {\n  \"type\": \"CONFIG\",\n  \"name\": \"root\"\n}\n"},
+		{"dut1", "interfaces.txt", "/interfaces/x[name=a]: {\n  \"type\": \"STATE\",\n  \"name\": \"interfaces\"\n}\n"},
+		{"dut1", "components.txt", "/components/x[name=a]: {\n  \"type\": \"STATE\",\n  \"name\": \"components\"\n}\n"},
+		{"dut1", "show_tech.txt", "show tech-support"},
+		{"dut2", "show_tech.txt", "request support information | no-more"},
+	}
+	for _, test := range tests {
+		got, err := ioutil.ReadFile(filepath.Join(dir, test.dut, test.file))
+		if err != nil {
+			t.Errorf("Collect() did not write %s of %s: %v", test.file, test.dut, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("Collect() wrote %s of %s as %q, want %q", test.file, test.dut, got, test.want)
+		}
+	}
+
+	// Collecting again reuses the cached gNMI clients rather than leaking more.
+	Collect(context.Background(), res, t.TempDir())
+	if want := len(res.DUTs); dials != want {
+		t.Errorf("Collect() twice dialed gNMI %d times, want %d", dials, want)
+	}
+}
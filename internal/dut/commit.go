@@ -0,0 +1,317 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dut
+
+import (
+	"golang.org/x/net/context"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/kylelemons/godebug/diff"
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/baseline"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+)
+
+// diffContext is the number of unchanged lines shown around every change in a
+// config diff.
+const diffContext = 3
+
+// DryRunResult is the result of a dry run of a config push.
+type DryRunResult struct {
+	// Config is the config that would be pushed, with its templates
+	// interpolated.
+	Config string
+	// Variant describes the config that was selected for the DUT.
+	Variant string
+	// Diff is a line diff of the running config against the config that the
+	// push would result in, or empty if the push would not change it.
+	Diff string
+}
+
+// FetchConfig returns the running config of the DUT, as openconfig JSON if
+// openConfig is true and in the vendor's syntax otherwise. Without a
+// binding.ConfigFetcher, only openconfig can be fetched, via gNMI.
+func FetchConfig(ctx context.Context, dut *reservation.DUT, openConfig bool) (string, error) {
+	if cf, ok := binding.Get().(binding.ConfigFetcher); ok {
+		return cf.RunningConfig(ctx, dut, openConfig)
+	}
+	if !openConfig {
+		return "", usererr.New("cannot fetch the vendor config of %s: the binding does not support it", dut.Name)
+	}
+	js, err := baseline.Snapshot(ctx, dut)
+	if err != nil {
+		return "", err
+	}
+	return string(js), nil
+}
+
+// DryRun returns the config that PushConfig would push to the DUT and a diff of
+// the running config against the result of the push, without applying it.
+// Without a binding.ConfigFetcher, only openconfig can be dry-run, and without
+// a binding.ConfigCommitter, the config is not validated by the device. An
+// openconfig subtree cannot be dry-run.
+func DryRun(ctx context.Context, dut *reservation.DUT, cfg *Config, append bool) (*DryRunResult, error) {
+	config, openConfig, desc, err := resolveConfig(dut, cfg)
+	if err != nil {
+		return nil, err
+	}
+	path, err := subtreePath(cfg, openConfig)
+	if err != nil {
+		return nil, err
+	}
+	if path != nil {
+		return nil, usererr.New("cannot dry-run the openconfig subtree at %s on %s", pathString(path), dut.Name)
+	}
+	b := binding.Get()
+	if _, ok := b.(binding.ConfigFetcher); !ok && !openConfig {
+		return nil, usererr.New("cannot dry-run vendor config on %s: the binding does not support it", dut.Name)
+	}
+	if _, ok := b.(binding.ConfigCommitter); ok {
+		opts := &binding.ConfigOptions{OpenConfig: openConfig, Append: append, DryRun: true}
+		if err := b.PushConfig(ctx, dut, config, opts); err != nil {
+			return nil, err
+		}
+	}
+	running, err := FetchConfig(ctx, dut, openConfig)
+	if err != nil {
+		return nil, err
+	}
+	want := config
+	if openConfig {
+		if running, want, err = openConfigResult(running, config, append); err != nil {
+			return nil, err
+		}
+	} else if append {
+		want = strings.TrimSuffix(running, "\n") + "\n" + config
+	}
+	return &DryRunResult{Config: config, Variant: desc, Diff: lineDiff(running, want)}, nil
+}
+
+// openConfigResult returns the running openconfig and the openconfig that the
+// push would result in, both indented for diffing. An appended config is
+// merged into the running config object by object; lists are replaced whole.
+func openConfigResult(running, config string, append bool) (string, string, error) {
+	var runVal, cfgVal interface{}
+	if err := json.Unmarshal([]byte(running), &runVal); err != nil {
+		return "", "", errors.Wrap(err, "running config is not valid JSON")
+	}
+	if err := json.Unmarshal([]byte(config), &cfgVal); err != nil {
+		return "", "", usererr.Wrapf(err, "openconfig is not valid JSON")
+	}
+	if append {
+		cfgVal = mergeJSON(runVal, cfgVal)
+	}
+	runJS, err := json.MarshalIndent(runVal, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	cfgJS, err := json.MarshalIndent(cfgVal, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	return string(runJS), string(cfgJS), nil
+}
+
+func mergeJSON(dst, src interface{}) interface{} {
+	dstObj, ok1 := dst.(map[string]interface{})
+	srcObj, ok2 := src.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return src
+	}
+	merged := make(map[string]interface{})
+	for k, v := range dstObj {
+		merged[k] = v
+	}
+	for k, v := range srcObj {
+		merged[k] = mergeJSON(merged[k], v)
+	}
+	return merged
+}
+
+// lineDiff returns a diff of the lines of a against those of b, with a few
+// lines of context around every change, or "" if they are the same.
+func lineDiff(a, b string) string {
+	lines := func(s string) []string {
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	chunks := diff.DiffChunks(lines(a), lines(b))
+	var buf bytes.Buffer
+	changed := false
+	for i, c := range chunks {
+		if len(c.Added) > 0 || len(c.Deleted) > 0 {
+			changed = true
+		}
+		for _, l := range c.Deleted {
+			fmt.Fprintf(&buf, "-%s\n", l)
+		}
+		for _, l := range c.Added {
+			fmt.Fprintf(&buf, "+%s\n", l)
+		}
+		eq := c.Equal
+		if i == len(chunks)-1 {
+			// The last chunk has no change after its unchanged lines.
+			if len(eq) > diffContext {
+				eq = eq[:diffContext]
+			}
+		} else if len(eq) > 2*diffContext {
+			for _, l := range eq[:diffContext] {
+				fmt.Fprintf(&buf, " %s\n", l)
+			}
+			buf.WriteString("...\n")
+			eq = eq[len(eq)-diffContext:]
+		}
+		for _, l := range eq {
+			fmt.Fprintf(&buf, " %s\n", l)
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return buf.String()
+}
+
+// Commit is a config push that is rolled back unless it is confirmed in time.
+type Commit struct {
+	// Variant describes the config that was selected for the DUT.
+	Variant string
+
+	dut     *reservation.DUT
+	timeout time.Duration
+	// timer rolls back the config if the framework emulates the
+	// commit-confirmed push; it is nil if the binding does natively.
+	timer *time.Timer
+	// snapshot is the config to roll back to when the commit is emulated.
+	snapshot []byte
+
+	mu          sync.Mutex
+	confirmed   bool
+	rolledBack  chan struct{}
+	rollbackErr error
+}
+
+// PushConfirmed pushes config to a DUT like PushConfig, but the config is
+// rolled back unless the returned commit is confirmed within the timeout.
+// Without a binding.ConfigCommitter, only openconfig can be pushed, and the
+// rollback replaces the config via gNMI with a snapshot taken before the push.
+// With one, an openconfig subtree cannot be pushed, as the binding only
+// commits whole configs.
+func PushConfirmed(ctx context.Context, dut *reservation.DUT, cfg *Config, append bool, timeout time.Duration) (*Commit, error) {
+	if timeout <= 0 {
+		return nil, usererr.New("confirm timeout must be a positive duration, got %v", timeout)
+	}
+	config, openConfig, desc, err := resolveConfig(dut, cfg)
+	if err != nil {
+		return nil, err
+	}
+	b := binding.Get()
+	c := &Commit{Variant: desc, dut: dut, timeout: timeout}
+	path, err := subtreePath(cfg, openConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := b.(binding.ConfigCommitter); ok {
+		if path != nil {
+			return nil, usererr.New("cannot push the openconfig subtree at %s to %s pending confirmation: the binding only commits whole configs", pathString(path), dut.Name)
+		}
+		opts := &binding.ConfigOptions{OpenConfig: openConfig, Append: append, ConfirmTimeout: timeout}
+		if err := b.PushConfig(ctx, dut, config, opts); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	if !openConfig {
+		return nil, usererr.New("cannot push vendor config to %s pending confirmation: the binding does not support it", dut.Name)
+	}
+	snapshot, err := baseline.Snapshot(ctx, dut)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to snapshot the config of %s to roll back to", dut.Name)
+	}
+	if path != nil {
+		err = setSubtree(ctx, dut, path, config, append)
+	} else {
+		err = b.PushConfig(ctx, dut, config, &binding.ConfigOptions{OpenConfig: openConfig, Append: append})
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.snapshot = snapshot
+	c.rolledBack = make(chan struct{})
+	c.timer = time.AfterFunc(timeout, func() {
+		c.rollBack(context.Background(), fmt.Sprintf("the push was not confirmed within %v", timeout))
+	})
+	return c, nil
+}
+
+// rollBack replaces the config of the DUT with the snapshot taken before the
+// push. It must be called at most once, after stopping or firing the timer.
+func (c *Commit) rollBack(ctx context.Context, reason string) {
+	defer close(c.rolledBack)
+	log.Warningf("Rolling back the config of %s because %s", c.dut.Name, reason)
+	if err := baseline.Replace(ctx, c.dut, c.snapshot); err != nil {
+		log.Errorf("Failed to roll back the config of %s: %v", c.dut.Name, err)
+		c.rollbackErr = err
+	}
+}
+
+// Confirm confirms the push, so that it is not rolled back. It fails if the
+// push was already rolled back.
+func (c *Commit) Confirm(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.confirmed {
+		return nil
+	}
+	if c.timer == nil {
+		if err := binding.Get().(binding.ConfigCommitter).ConfirmConfig(ctx, c.dut); err != nil {
+			return err
+		}
+		c.confirmed = true
+		return nil
+	}
+	if c.timer.Stop() {
+		c.confirmed = true
+		return nil
+	}
+	<-c.rolledBack
+	if c.rollbackErr != nil {
+		return errors.Wrapf(c.rollbackErr, "push to %s was not confirmed within %v and failed to roll back", c.dut.Name, c.timeout)
+	}
+	return usererr.New("push to %s was rolled back because it was not confirmed within %v", c.dut.Name, c.timeout)
+}
+
+// Cancel rolls back the push now, unless it was confirmed, and returns any
+// error rolling it back. If the rollback is already under way, it waits for
+// it to finish. A push that the binding commits natively is left to the
+// device to roll back.
+func (c *Commit) Cancel(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.confirmed || c.timer == nil {
+		return nil
+	}
+	if c.timer.Stop() {
+		c.rollBack(ctx, "the push was cancelled before it was confirmed")
+	}
+	<-c.rolledBack
+	return c.rollbackErr
+}
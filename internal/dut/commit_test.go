@@ -0,0 +1,115 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dut
+
+import (
+	"golang.org/x/net/context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// fakeCommitter is a binding with native candidate and commit semantics.
+type fakeCommitter struct {
+	*fakebind.Binding
+	running   string
+	pushed    []*binding.ConfigOptions
+	confirmed bool
+}
+
+func (f *fakeCommitter) RunningConfig(_ context.Context, _ *reservation.DUT, openConfig bool) (string, error) {
+	return f.running, nil
+}
+
+func (f *fakeCommitter) ConfirmConfig(context.Context, *reservation.DUT) error {
+	f.confirmed = true
+	return nil
+}
+
+func TestCommitter(t *testing.T) {
+	fc := &fakeCommitter{Binding: &fakebind.Binding{}, running: "hostname a\nntp server x\n"}
+	fc.ConfigPusher = func(_ context.Context, _ *reservation.DUT, _ string, opts *binding.ConfigOptions) error {
+		fc.pushed = append(fc.pushed, opts)
+		return nil
+	}
+	binding.Init(fc)
+	dut := &reservation.DUT{&reservation.Dims{Name: "dut1", Vendor: opb.Device_ARISTA}}
+	cfg := &Config{VC: map[opb.Device_Vendor]ConfigProvider{opb.Device_ARISTA: ConfigText("hostname b")}}
+
+	res, err := DryRun(context.Background(), dut, cfg, true)
+	if err != nil {
+		t.Fatalf("DryRun() failed: %v", err)
+	}
+	if want := " hostname a\n ntp server x\n+hostname b\n"; res.Diff != want {
+		t.Errorf("DryRun() got diff %q, want %q", res.Diff, want)
+	}
+
+	commit, err := PushConfirmed(context.Background(), dut, cfg, false, time.Minute)
+	if err != nil {
+		t.Fatalf("PushConfirmed() failed: %v", err)
+	}
+	if err := commit.Confirm(context.Background()); err != nil {
+		t.Fatalf("Confirm() failed: %v", err)
+	}
+	if !fc.confirmed {
+		t.Errorf("Confirm() did not confirm the push through the binding")
+	}
+	wantPushed := []*binding.ConfigOptions{
+		{Append: true, DryRun: true},
+		{ConfirmTimeout: time.Minute},
+	}
+	if diff := cmp.Diff(wantPushed, fc.pushed); diff != "" {
+		t.Errorf("got pushed options diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	var a []string
+	for _, c := range "abcdefghijklmnop" {
+		a = append(a, string(c))
+	}
+	b := append([]string(nil), a...)
+	b[1] = "B"
+	b[12] = "M"
+	want := ` a
+-b
++B
+ c
+ d
+ e
+...
+ j
+ k
+ l
+-m
++M
+ n
+ o
+ p
+`
+	if diff := cmp.Diff(want, lineDiff(strings.Join(a, "\n"), strings.Join(b, "\n"))); diff != "" {
+		t.Errorf("lineDiff() got diff (-want +got):\n%s", diff)
+	}
+	if got := lineDiff("a\nb", "a\nb"); got != "" {
+		t.Errorf("lineDiff() of the same lines got %q, want empty", got)
+	}
+}
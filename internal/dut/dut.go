@@ -17,16 +17,21 @@ package dut
 
 import (
 	"golang.org/x/net/context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"regexp"
 	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
+	"github.com/openconfig/ygot/ygot"
 	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
 	"github.com/openconfig/ondatra/internal/reservation"
 	"github.com/openconfig/ondatra/internal/usererr"
 
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
 	opb "github.com/openconfig/ondatra/proto"
 )
 
@@ -35,9 +40,71 @@ const configTrunc = 10
 
 // Config stores the potential config text to push to the device.
 type Config struct {
-	VC   map[opb.Device_Vendor]ConfigProvider
-	Open ConfigProvider
-	Vars map[string]string
+	VC map[opb.Device_Vendor]ConfigProvider
+	// Variants are vendor configs that only apply to some models or versions of
+	// the vendor's devices; they take precedence over VC.
+	Variants []*Variant
+	Open     ConfigProvider
+	Vars     map[string]string
+}
+
+// Variant is a vendor config for the devices of the vendor whose hardware
+// model and software version match regular expressions.
+type Variant struct {
+	Vendor opb.Device_Vendor
+	// HardwareModel and SoftwareVersion are regular expressions that must match
+	// the whole hardware model and software version of the device. An empty
+	// expression matches any value.
+	HardwareModel, SoftwareVersion string
+	Provider                       ConfigProvider
+}
+
+// String describes the devices that the variant applies to.
+func (v *Variant) String() string {
+	var conds []string
+	if v.HardwareModel != "" {
+		conds = append(conds, fmt.Sprintf("hardware model %q", v.HardwareModel))
+	}
+	if v.SoftwareVersion != "" {
+		conds = append(conds, fmt.Sprintf("software version %q", v.SoftwareVersion))
+	}
+	if len(conds) == 0 {
+		return fmt.Sprintf("%v config variant", v.Vendor)
+	}
+	return fmt.Sprintf("%v config variant for %s", v.Vendor, strings.Join(conds, " and "))
+}
+
+// specificity returns the number of criteria that the variant specifies.
+func (v *Variant) specificity() int {
+	n := 0
+	for _, re := range []string{v.HardwareModel, v.SoftwareVersion} {
+		if re != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *Variant) matches(dut *reservation.DUT) (bool, error) {
+	if v.Vendor != dut.Vendor {
+		return false, nil
+	}
+	for _, m := range []struct{ re, val string }{
+		{v.HardwareModel, dut.HardwareModel},
+		{v.SoftwareVersion, dut.SoftwareVersion},
+	} {
+		if m.re == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + m.re + ")$")
+		if err != nil {
+			return false, usererr.Wrapf(err, "invalid regular expression in %v", v)
+		}
+		if !re.MatchString(m.val) {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 // ConfigProvider provide config text to push to the device.
@@ -73,64 +140,207 @@ func (f ConfigFile) Get() (string, error) {
 	return string(c), nil
 }
 
-// PushConfig pushes config to a DUT.
-func PushConfig(ctx context.Context, dut *reservation.DUT, cfg *Config, append bool) error {
-	prov, ok := cfg.VC[dut.Vendor]
-	if !ok {
-		if cfg.Open == nil {
-			return errors.Errorf("no openconfig or vendor config for device %v", dut)
+// ConfigStruct is openconfig in a ygot struct, of either the device root or
+// the subtree at a path. It is not a template. A subtree is not pushed through
+// the binding, but by a gNMI Set of the subtree at its path, which replaces
+// or, if appended, updates only that subtree.
+type ConfigStruct struct {
+	// Path is the path struct of the subtree that the struct is the value of,
+	// e.g. of an interface, or nil for the device root.
+	Path   ygot.PathStruct
+	Struct ygot.ValidatedGoStruct
+}
+
+func (s ConfigStruct) String() string {
+	if s.Path == nil {
+		return fmt.Sprintf("ConfigStruct{%T}", s.Struct)
+	}
+	if p, err := resolvePath(s.Path); err == nil {
+		return fmt.Sprintf("ConfigStruct{%s: %T}", pathString(p), s.Struct)
+	}
+	return fmt.Sprintf("ConfigStruct{%T: %T}", s.Path, s.Struct)
+}
+
+// Get validates the struct against the schema and returns it as RFC7951 JSON,
+// with the members of the struct qualified with their module names. For a
+// subtree, this is the value of the subtree at its path.
+func (s ConfigStruct) Get() (string, error) {
+	if err := s.Struct.Validate(); err != nil {
+		return "", errors.Wrap(err, "openconfig struct is invalid")
+	}
+	v, err := ygot.ConstructIETFJSON(s.Struct, &ygot.RFC7951JSONConfig{AppendModuleName: true})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal openconfig struct")
+	}
+	js, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(js), nil
+}
+
+// PushConfig pushes config to a DUT and returns a description of the config
+// that was selected for it, e.g. "ARISTA config".
+func PushConfig(ctx context.Context, dut *reservation.DUT, cfg *Config, append bool) (string, error) {
+	config, openConfig, desc, err := resolveConfig(dut, cfg)
+	if err != nil {
+		return "", err
+	}
+	path, err := subtreePath(cfg, openConfig)
+	if err != nil {
+		return "", err
+	}
+	if path != nil {
+		if err := setSubtree(ctx, dut, path, config, append); err != nil {
+			return "", err
 		}
-		prov = cfg.Open
+		return desc, nil
+	}
+	opts := &binding.ConfigOptions{OpenConfig: openConfig, Append: append}
+	if err := binding.Get().PushConfig(ctx, dut, config, opts); err != nil {
+		return "", err
+	}
+	return desc, nil
+}
+
+// subtreePath returns the path of the openconfig subtree that the selected
+// config is the value of, or nil if the config is of the whole device.
+func subtreePath(cfg *Config, openConfig bool) (*gpb.Path, error) {
+	cs, ok := cfg.Open.(ConfigStruct)
+	if !ok || !openConfig || cs.Path == nil {
+		return nil, nil
+	}
+	return resolvePath(cs.Path)
+}
+
+// resolvePath resolves a path struct to an openconfig path, without the
+// device it was rooted at.
+func resolvePath(ps ygot.PathStruct) (*gpb.Path, error) {
+	p, _, errs := ygot.ResolvePath(ps)
+	if len(errs) > 0 {
+		return nil, usererr.New("invalid openconfig path struct %v: %v", ps, errs)
+	}
+	p.Origin = "openconfig"
+	p.Target = ""
+	return p, nil
+}
+
+// pathString formats a path for messages.
+func pathString(p *gpb.Path) string {
+	if s, err := ygot.PathToString(p); err == nil {
+		return s
+	}
+	return p.String()
+}
+
+// setSubtree replaces the openconfig subtree at the path with the config via
+// gNMI, or updates it with the config if append is true.
+func setSubtree(ctx context.Context, dut *reservation.DUT, path *gpb.Path, config string, append bool) error {
+	upd := []*gpb.Update{{
+		Path: path,
+		Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(config)}},
+	}}
+	req := &gpb.SetRequest{Replace: upd}
+	if append {
+		req = &gpb.SetRequest{Update: upd}
+	}
+	if _, err := gnmiclient.Set(ctx, dut, req); err != nil {
+		return errors.Wrapf(err, "failed to set openconfig at %s on DUT %s", pathString(path), dut.Name)
+	}
+	return nil
+}
+
+// resolveConfig returns the config text to push to the DUT, with its templates
+// interpolated, whether it is openconfig, and a description of it.
+func resolveConfig(dut *reservation.DUT, cfg *Config) (config string, openConfig bool, desc string, err error) {
+	prov, openConfig, desc, err := selectConfig(dut, cfg)
+	if err != nil {
+		return "", false, "", err
 	}
-	openConfig := prov == cfg.Open
 	text, err := prov.Get()
 	if err != nil {
-		return errors.Wrapf(err, "error getting config from provider %v", prov)
+		return "", false, "", usererr.Wrapf(err, "error getting config from provider %v", prov)
 	}
-	config, err := interpolateConfig(dut, text, cfg.Vars)
+	if _, ok := prov.(ConfigStruct); ok {
+		return text, openConfig, desc, nil
+	}
+	config, err = interpolateConfig(dut, text, cfg.Vars)
 	if err != nil {
-		return err
+		return "", false, "", err
 	}
-	opts := &binding.ConfigOptions{OpenConfig: openConfig, Append: append}
-	return binding.Get().PushConfig(ctx, dut, config, opts)
+	return config, openConfig, desc, nil
+}
+
+// selectConfig selects the most specific config for the DUT: the matching
+// variant with the most criteria, or else the vendor config, or else the
+// openconfig. Of equally specific variants, the first one is selected.
+func selectConfig(dut *reservation.DUT, cfg *Config) (ConfigProvider, bool, string, error) {
+	var best *Variant
+	for _, v := range cfg.Variants {
+		ok, err := v.matches(dut)
+		if err != nil {
+			return nil, false, "", err
+		}
+		if ok && (best == nil || v.specificity() > best.specificity()) {
+			best = v
+		}
+	}
+	if best != nil {
+		return best.Provider, false, best.String(), nil
+	}
+	if prov, ok := cfg.VC[dut.Vendor]; ok {
+		return prov, false, fmt.Sprintf("%v config", dut.Vendor), nil
+	}
+	if cfg.Open == nil {
+		return nil, false, "", usererr.New("no openconfig or vendor config for device %v", dut)
+	}
+	return cfg.Open, true, "openconfig", nil
 }
 
 // interpolateConfig substitutes templated variables in device config text.
-// The following Go template functions are allowed in config:
-// - {{ port "<portID>" }}: replaced with the physical port name
-// - {{ secrets "<arg1>" "<arg2>" }}: left untouched, returned as-is
-// - {{ var "<key>" }}: returns the value for the key in the vars map
+// The template is executed with the DeviceFacts of the DUT, so it can refer to
+// e.g. {{ .Name }} and loop over {{ range .Ports }}. The following Go template
+// functions are allowed in config:
+//   - {{ port "<portID>" }}: replaced with the physical port name
+//   - {{ secrets "<arg1>" "<arg2>" }}: left untouched, returned as-is
+//   - {{ var "<key>" }}: returns the value for the key in the vars map
+//   - {{ include "<path>" <data> }}: replaced with the template in the file,
+//     executed with the data
+//   - {{ cidrhost "<cidr>" <n> }}, {{ ipadd "<ip>" <n> }} and
+//     {{ cidrnetmask "<cidr>" }}: IP address arithmetic
+//   - add, sub, mul, lower, upper, trim, replace, join, splitList and until,
+//     which behave like their sprig equivalents
 func interpolateConfig(dut *reservation.DUT, config string, vars map[string]string) (string, error) {
-	funcMap := map[string]interface{}{
-		"port": func(portID string) (string, error) {
-			port, err := dut.Port(portID)
-			if err != nil {
-				return "", usererr.Wrap(err)
-			}
-			return port.Name, nil
-		},
-		// "secrets" function should be a noop
-		"secrets": func(secrets ...string) string {
-			var args []string
-			for _, s := range secrets {
-				args = append(args, fmt.Sprintf("%q", s))
-			}
-			return fmt.Sprintf("{{ secrets %s }}", strings.Join(args, " "))
-		},
-		"var": func(key string) (string, error) {
-			v, ok := vars[key]
-			if !ok {
-				return "", usererr.New("No value for key %q in vars map", key)
-			}
-			return v, nil
-		},
+	funcMap := helperFuncs()
+	funcMap["port"] = func(portID string) (string, error) {
+		port, err := dut.Port(portID)
+		if err != nil {
+			return "", usererr.Wrap(err)
+		}
+		return port.Name, nil
+	}
+	// "secrets" function should be a noop
+	funcMap["secrets"] = func(secrets ...string) string {
+		var args []string
+		for _, s := range secrets {
+			args = append(args, fmt.Sprintf("%q", s))
+		}
+		return fmt.Sprintf("{{ secrets %s }}", strings.Join(args, " "))
+	}
+	funcMap["var"] = func(key string) (string, error) {
+		v, ok := vars[key]
+		if !ok {
+			return "", usererr.New("No value for key %q in vars map", key)
+		}
+		return v, nil
 	}
+	funcMap["include"] = includeFunc(funcMap, 0)
 	template, err := template.New(dut.Name).Funcs(funcMap).Parse(config)
 	if err != nil {
 		return "", usererr.Wrapf(err, "Invalid template in config: %q", config)
 	}
 	var b strings.Builder
-	if err = template.Execute(&b, nil); err != nil {
+	if err = template.Execute(&b, deviceFacts(dut)); err != nil {
 		return "", usererr.Wrapf(err, "Invalid template in config: %q", config)
 	}
 	return b.String(), nil
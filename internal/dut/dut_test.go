@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dut
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ygot/exampleoc"
+	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+func TestSelectConfig(t *testing.T) {
+	mx := &reservation.DUT{&reservation.Dims{Name: "mx1", Vendor: opb.Device_JUNIPER, HardwareModel: "MX480", SoftwareVersion: "21.2R1"}}
+	ptx := &reservation.DUT{&reservation.Dims{Name: "ptx1", Vendor: opb.Device_JUNIPER, HardwareModel: "PTX10008", SoftwareVersion: "21.4R1"}}
+	ciena := &reservation.DUT{&reservation.Dims{Name: "ciena1", Vendor: opb.Device_CIENA}}
+	cfg := &Config{
+		VC: map[opb.Device_Vendor]ConfigProvider{opb.Device_JUNIPER: ConfigText("junos")},
+		Variants: []*Variant{
+			{Vendor: opb.Device_JUNIPER, HardwareModel: "MX.*", Provider: ConfigText("mx")},
+			{Vendor: opb.Device_JUNIPER, HardwareModel: "PTX.*", SoftwareVersion: `21\.4.*`, Provider: ConfigText("ptx 21.4")},
+			{Vendor: opb.Device_JUNIPER, HardwareModel: "PTX.*", Provider: ConfigText("ptx")},
+		},
+		Open: ConfigText("openconfig"),
+	}
+	tests := []struct {
+		dut      *reservation.DUT
+		want     string
+		wantOpen bool
+		wantDesc string
+	}{
+		{mx, "mx", false, `JUNIPER config variant for hardware model "MX.*"`},
+		{ptx, "ptx 21.4", false, `JUNIPER config variant for hardware model "PTX.*" and software version "21\\.4.*"`},
+		{ciena, "openconfig", true, "openconfig"},
+	}
+	for _, test := range tests {
+		prov, open, desc, err := selectConfig(test.dut, cfg)
+		if err != nil {
+			t.Fatalf("selectConfig(%s) failed: %v", test.dut.Name, err)
+		}
+		if got, _ := prov.Get(); got != test.want || open != test.wantOpen || desc != test.wantDesc {
+			t.Errorf("selectConfig(%s) got (%q, %t, %q), want (%q, %t, %q)", test.dut.Name, got, open, desc, test.want, test.wantOpen, test.wantDesc)
+		}
+	}
+
+	cfg.Variants = nil
+	if _, _, desc, _ := selectConfig(mx, cfg); desc != "JUNIPER config" {
+		t.Errorf("selectConfig(%s) without variants got description %q, want %q", mx.Name, desc, "JUNIPER config")
+	}
+}
+
+func TestConfigStruct(t *testing.T) {
+	intf := &exampleoc.Interface{
+		Name:    ygot.String("Ethernet1"),
+		Enabled: ygot.Bool(true),
+		Type:    exampleoc.IETFInterfaces_InterfaceType_ethernetCsmacd,
+	}
+	intfPath := ygot.NewNodePath([]string{"interfaces", "interface"}, map[string]interface{}{"name": "Ethernet1"}, ygot.NewDeviceRootBase("dut"))
+	root := &exampleoc.Device{Interface: map[string]*exampleoc.Interface{"Ethernet1": intf}}
+	tests := []struct {
+		desc string
+		cs   ConfigStruct
+		want string
+	}{{
+		desc: "root",
+		cs:   ConfigStruct{Struct: root},
+		want: `{"openconfig-interfaces:interfaces": {"interface": [{
+			"name": "Ethernet1",
+			"config": {"enabled": true, "name": "Ethernet1", "type": "iana-if-type:ethernetCsmacd"}
+		}]}}`,
+	}, {
+		desc: "subtree",
+		cs:   ConfigStruct{Path: intfPath, Struct: intf},
+		want: `{
+			"openconfig-interfaces:name": "Ethernet1",
+			"openconfig-interfaces:config": {"enabled": true, "name": "Ethernet1", "type": "iana-if-type:ethernetCsmacd"}
+		}`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := tt.cs.Get()
+			if err != nil {
+				t.Fatalf("Get() failed: %v", err)
+			}
+			var gotVal, wantVal interface{}
+			if err := json.Unmarshal([]byte(got), &gotVal); err != nil {
+				t.Fatalf("Get() returned invalid JSON %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantVal); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(wantVal, gotVal); diff != "" {
+				t.Errorf("Get() got JSON diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	invalid := &exampleoc.Interface{Name: ygot.String("Ethernet1")}
+	invalid.GetOrCreateEthernet().MacAddress = ygot.String("not a MAC")
+	cs := ConfigStruct{Path: intfPath, Struct: invalid}
+	if _, err := cs.Get(); err == nil || !strings.Contains(err.Error(), "mac-address") {
+		t.Errorf("Get() of an invalid struct got error %v, want the validation error", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dut
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/testbed"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// maxIncludeDepth bounds the nesting of included templates, to catch a
+// template that includes itself.
+const maxIncludeDepth = 16
+
+// DeviceFacts are the facts about a reserved DUT that config templates are
+// executed with, e.g. {{ .Name }} or {{ range .Ports }}...{{ end }}.
+type DeviceFacts struct {
+	// ID is the ID of the DUT in the testbed.
+	ID              string
+	Name            string
+	Vendor          string
+	HardwareModel   string
+	SoftwareVersion string
+	// Ports are the reserved ports of the DUT, sorted by ID.
+	Ports []*PortFacts
+}
+
+// PortFacts are the facts about a reserved port of a DUT.
+type PortFacts struct {
+	// ID is the ID of the port in the testbed.
+	ID    string
+	Name  string
+	Speed string
+	PMD   string
+	// PeerDevice and PeerPort are the testbed IDs of the device and port that
+	// the port is linked to, and PeerPortName is the name of that port. They
+	// are empty if the port is not linked.
+	PeerDevice   string
+	PeerPort     string
+	PeerPortName string
+}
+
+// deviceFacts returns the facts about the DUT. The ID and the peers of the
+// ports are only known if the DUT belongs to the current reservation.
+func deviceFacts(dut *reservation.DUT) *DeviceFacts {
+	f := &DeviceFacts{
+		Name:            dut.Name,
+		Vendor:          dut.Vendor.String(),
+		HardwareModel:   dut.HardwareModel,
+		SoftwareVersion: dut.SoftwareVersion,
+	}
+	res, err := testbed.Reservation()
+	if err == nil {
+		for id, d := range res.DUTs {
+			if d == dut {
+				f.ID = id
+			}
+		}
+	}
+	var portIDs []string
+	for id := range dut.Ports {
+		portIDs = append(portIDs, id)
+	}
+	sort.Strings(portIDs)
+	for _, id := range portIDs {
+		p := dut.Ports[id]
+		pf := &PortFacts{ID: id, Name: p.Name, PMD: p.PMD}
+		if p.Speed != opb.Port_S_UNKNOWN {
+			pf.Speed = strings.TrimPrefix(p.Speed.String(), "S_")
+		}
+		if f.ID != "" {
+			if devID, portID, ok := testbed.Peer(f.ID, id); ok {
+				pf.PeerDevice, pf.PeerPort = devID, portID
+				if peer, err := res.Device(devID); err == nil {
+					if pp, ok := peer.Dimensions().Ports[portID]; ok {
+						pf.PeerPortName = pp.Name
+					}
+				}
+			}
+		}
+		f.Ports = append(f.Ports, pf)
+	}
+	return f
+}
+
+// helperFuncs returns the general-purpose functions available in config
+// templates, named after their equivalents in the sprig library.
+func helperFuncs() template.FuncMap {
+	return template.FuncMap{
+		"add":       func(a, b int) int { return a + b },
+		"sub":       func(a, b int) int { return a - b },
+		"mul":       func(a, b int) int { return a * b },
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"trim":      strings.TrimSpace,
+		"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+		"splitList": func(sep, s string) []string { return strings.Split(s, sep) },
+		"until": func(n int) []int {
+			s := make([]int, n)
+			for i := range s {
+				s[i] = i
+			}
+			return s
+		},
+		"cidrhost":    cidrHost,
+		"ipadd":       ipAdd,
+		"cidrnetmask": cidrNetmask,
+	}
+}
+
+// cidrHost returns the nth address in the CIDR, e.g. cidrhost "10.0.0.0/24" 5
+// is "10.0.0.5". A negative n counts back from the end of the CIDR.
+func cidrHost(cidr string, n int) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", usererr.Wrapf(err, "invalid CIDR %q", cidr)
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	off := big.NewInt(int64(n))
+	if n < 0 {
+		off.Add(off, size)
+	}
+	if off.Sign() < 0 || off.Cmp(size) >= 0 {
+		return "", usererr.New("address %d is outside of CIDR %q", n, cidr)
+	}
+	return addIP(ipNet.IP, off).String(), nil
+}
+
+// ipAdd returns the address that is n addresses after the IP address, e.g.
+// ipadd "10.0.0.1" 2 is "10.0.0.3".
+func ipAdd(addr string, n int) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", usererr.New("invalid IP address %q", addr)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	sum := addIP(ip, big.NewInt(int64(n)))
+	if sum == nil {
+		return "", usererr.New("address %q plus %d is out of range", addr, n)
+	}
+	return sum.String(), nil
+}
+
+// cidrNetmask returns the netmask of an IPv4 CIDR in dotted-decimal notation,
+// e.g. cidrnetmask "10.0.0.0/24" is "255.255.255.0".
+func cidrNetmask(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", usererr.Wrapf(err, "invalid CIDR %q", cidr)
+	}
+	if len(ipNet.Mask) != net.IPv4len {
+		return "", usererr.New("CIDR %q is not IPv4", cidr)
+	}
+	return net.IP(ipNet.Mask).String(), nil
+}
+
+// addIP adds the offset to the IP address, or returns nil if the result is
+// out of the range of the address family.
+func addIP(ip net.IP, off *big.Int) net.IP {
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), off)
+	if sum.Sign() < 0 || sum.BitLen() > len(ip)*8 {
+		return nil
+	}
+	b := sum.Bytes()
+	res := make(net.IP, len(ip))
+	copy(res[len(res)-len(b):], b)
+	return res
+}
+
+// includeFunc returns the include template function, which executes the
+// template in the specified file with the specified data and returns the
+// result, e.g. {{ include "interfaces.tmpl" . }}. The included template has
+// the same functions as the including one.
+func includeFunc(funcs template.FuncMap, depth int) func(string, interface{}) (string, error) {
+	return func(path string, data interface{}) (string, error) {
+		if depth >= maxIncludeDepth {
+			return "", usererr.New("templates included more than %d deep; %q may include itself", maxIncludeDepth, path)
+		}
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", usererr.Wrapf(err, "error reading included template %q", path)
+		}
+		nested := make(template.FuncMap)
+		for name, fn := range funcs {
+			nested[name] = fn
+		}
+		nested["include"] = includeFunc(funcs, depth+1)
+		tmpl, err := template.New(path).Funcs(nested).Parse(string(text))
+		if err != nil {
+			return "", usererr.Wrapf(err, "invalid included template %q", path)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", usererr.Wrapf(err, "error executing included template %q", path)
+		}
+		return b.String(), nil
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dut
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/gnmi/errdiff"
+	"github.com/openconfig/ondatra/internal/reservation"
+)
+
+func TestIPFuncs(t *testing.T) {
+	tests := []struct {
+		desc    string
+		fn      func() (string, error)
+		want    string
+		wantErr string
+	}{{
+		desc: "cidrhost first",
+		fn:   func() (string, error) { return cidrHost("10.1.0.0/16", 0) },
+		want: "10.1.0.0",
+	}, {
+		desc: "cidrhost carry",
+		fn:   func() (string, error) { return cidrHost("10.1.0.0/16", 257) },
+		want: "10.1.1.1",
+	}, {
+		desc: "cidrhost from end",
+		fn:   func() (string, error) { return cidrHost("10.1.0.0/16", -2) },
+		want: "10.1.255.254",
+	}, {
+		desc: "cidrhost IPv6",
+		fn:   func() (string, error) { return cidrHost("2001:db8::/64", 16) },
+		want: "2001:db8::10",
+	}, {
+		desc:    "cidrhost out of range",
+		fn:      func() (string, error) { return cidrHost("10.0.0.0/31", 2) },
+		wantErr: "outside of CIDR",
+	}, {
+		desc:    "cidrhost invalid",
+		fn:      func() (string, error) { return cidrHost("10.0.0.0", 1) },
+		wantErr: "invalid CIDR",
+	}, {
+		desc: "ipadd",
+		fn:   func() (string, error) { return ipAdd("10.0.0.254", 3) },
+		want: "10.0.1.1",
+	}, {
+		desc: "ipadd negative",
+		fn:   func() (string, error) { return ipAdd("2001:db8::1", -2) },
+		want: "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff",
+	}, {
+		desc:    "ipadd overflow",
+		fn:      func() (string, error) { return ipAdd("255.255.255.255", 1) },
+		wantErr: "out of range",
+	}, {
+		desc: "cidrnetmask",
+		fn:   func() (string, error) { return cidrNetmask("10.0.0.0/20") },
+		want: "255.255.240.0",
+	}, {
+		desc:    "cidrnetmask IPv6",
+		fn:      func() (string, error) { return cidrNetmask("2001:db8::/64") },
+		wantErr: "not IPv4",
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := test.fn()
+			if d := errdiff.Substring(err, test.wantErr); d != "" {
+				t.Fatalf("got unexpected error: %s", d)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIncludeSelf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "self.tmpl")
+	if err := ioutil.WriteFile(path, []byte(`{{ include "`+path+`" . }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dut := &reservation.DUT{Dims: &reservation.Dims{Name: "dut1"}}
+	_, err := interpolateConfig(dut, `{{ include "`+path+`" . }}`, nil)
+	if err == nil || !strings.Contains(err.Error(), "may include itself") {
+		t.Errorf("interpolateConfig() of a self-including template got error %v, want it to mention the recursion", err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	log "github.com/golang/glog"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"github.com/openconfig/ondatra/internal/binding"
 	"github.com/openconfig/ondatra/internal/reservation"
@@ -29,11 +30,15 @@ import (
 	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
 )
 
-var _ binding.Binding = &Binding{}
+var (
+	_ binding.Binding = &Binding{}
+	_ binding.Fetcher = &Binding{}
+)
 
 // Binding is a fake testbed binding comprised of stub implementations.
 type Binding struct {
 	Reservation    *reservation.Reservation
+	Releaser       func(context.Context) error
 	ConfigPusher   func(context.Context, *reservation.DUT, string, *binding.ConfigOptions) error
 	CLIDialer      func(context.Context, *reservation.DUT, ...grpc.DialOption) (binding.StreamClient, error)
 	ConsoleDialer  func(context.Context, *reservation.DUT, ...grpc.DialOption) (binding.StreamClient, error)
@@ -44,11 +49,13 @@ type Binding struct {
 	P4RTDialer       func(context.Context, *reservation.DUT, ...grpc.DialOption) (p4pb.P4RuntimeClient, error)
 	RoutingRestarter func(*reservation.DUT) error
 	PortStateSetter  func(*reservation.ATE, string, bool) error
+	InfraFailHandler func(error) error
 }
 
 // Reset zeros out all the stub implementations.
 func (b *Binding) Reset() {
 	b.Reservation = nil
+	b.Releaser = nil
 	b.ConfigPusher = nil
 	b.TopologyPusher = nil
 	b.TrafficStarter = nil
@@ -59,6 +66,7 @@ func (b *Binding) Reset() {
 	b.P4RTDialer = nil
 	b.RoutingRestarter = nil
 	b.PortStateSetter = nil
+	b.InfraFailHandler = nil
 }
 
 // Reserve reserves a new fake testbed, reading the definition from the given path.
@@ -67,9 +75,20 @@ func (b *Binding) Reserve(_ context.Context, _ *opb.Testbed, _, _ time.Duration)
 	return b.Reservation, nil
 }
 
-// Release is a noop.
-func (b *Binding) Release(context.Context) (rerr error) {
-	return nil
+// FetchReservation returns b.Reservation if it has the given ID.
+func (b *Binding) FetchReservation(_ context.Context, _ *opb.Testbed, id string) (*reservation.Reservation, error) {
+	if b.Reservation == nil || b.Reservation.ID != id {
+		return nil, errors.Errorf("reservation %q not found", id)
+	}
+	return b.Reservation, nil
+}
+
+// Release calls b.Releaser, if set.
+func (b *Binding) Release(ctx context.Context) (rerr error) {
+	if b.Releaser == nil {
+		return nil
+	}
+	return b.Releaser(ctx)
 }
 
 // DialATEGNMI is a noop.
@@ -148,8 +167,12 @@ func (b *Binding) DialConsole(ctx context.Context, dut *reservation.DUT, opts ..
 	return b.ConsoleDialer(ctx, dut, opts...)
 }
 
-// HandleInfraFail logs the error and returns it unchanged.
+// HandleInfraFail delegates to b.InfraFailHandler if set;
+// otherwise it logs the error and returns it unchanged.
 func (b *Binding) HandleInfraFail(err error) error {
+	if b.InfraFailHandler != nil {
+		return b.InfraFailHandler(err)
+	}
 	log.Errorf("Infrastructure failure: %v", err)
 	return err
 }
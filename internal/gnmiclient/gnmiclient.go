@@ -0,0 +1,120 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gnmiclient caches the gNMI clients of the reserved devices, so that
+// all of Ondatra shares one client per device.
+package gnmiclient
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"math"
+	"sync"
+
+	"google.golang.org/grpc"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+var (
+	mu    sync.Mutex
+	gnmis = make(map[reservation.Device]*entry)
+)
+
+// entry is a cached gNMI client, which is ready once done is closed.
+type entry struct {
+	done chan struct{}
+	c    gpb.GNMIClient
+	err  error
+}
+
+// New dials a new gNMI client for the device through the binding.
+func New(ctx context.Context, dev reservation.Device) (gpb.GNMIClient, error) {
+	switch d := dev.(type) {
+	case *reservation.DUT:
+		return binding.Get().DialGNMI(ctx, d,
+			grpc.WithBlock(),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)))
+	case *reservation.ATE:
+		return binding.Get().DialATEGNMI(ctx, d, grpc.WithBlock())
+	}
+	return nil, fmt.Errorf("unsupported device type: %T", dev)
+}
+
+// Fetch returns the cached gNMI client for the device, dialing it if needed.
+// The client is dialed without holding the lock on the cache, so a slow dial
+// only blocks the fetches for the same device.
+func Fetch(ctx context.Context, dev reservation.Device) (gpb.GNMIClient, error) {
+	mu.Lock()
+	e, ok := gnmis[dev]
+	if !ok {
+		e = &entry{done: make(chan struct{})}
+		gnmis[dev] = e
+	}
+	mu.Unlock()
+	if !ok {
+		e.c, e.err = New(ctx, dev)
+		if e.err != nil {
+			// Leave the next fetch to dial again.
+			mu.Lock()
+			if gnmis[dev] == e {
+				delete(gnmis, dev)
+			}
+			mu.Unlock()
+		}
+		close(e.done)
+		return e.c, e.err
+	}
+	select {
+	case <-e.done:
+		return e.c, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Set sends the Set request to the device with the cached gNMI client. A gNMI
+// Set is declarative, so it is retried if it fails with a transient error,
+// after evicting the client.
+func Set(ctx context.Context, dev reservation.Device, req *gpb.SetRequest) (*gpb.SetResponse, error) {
+	var resp *gpb.SetResponse
+	err := retry.Do(ctx, func() error {
+		c, err := Fetch(ctx, dev)
+		if err != nil {
+			return err
+		}
+		resp, err = c.Set(ctx, req)
+		return err
+	}, func() { Evict(dev) })
+	return resp, err
+}
+
+// Evict removes the cached gNMI client for the device, if any, so that the
+// next fetch re-dials it through the binding. It should be called when a call
+// on the client fails with a transient error.
+func Evict(dev reservation.Device) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(gnmis, dev)
+}
+
+// Reset discards all cached gNMI clients.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	gnmis = make(map[reservation.Device]*entry)
+}
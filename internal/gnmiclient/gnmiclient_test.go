@@ -0,0 +1,80 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gnmiclient
+
+import (
+	"golang.org/x/net/context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+type fakeGNMI struct {
+	gpb.GNMIClient
+	dials int
+}
+
+func TestFetchDialsOutsideLock(t *testing.T) {
+	slow := &reservation.DUT{&reservation.Dims{Name: "slow"}}
+	fast := &reservation.DUT{&reservation.Dims{Name: "fast"}}
+	unblock := make(chan struct{})
+	dials := make(map[string]int)
+	fb := &fakebind.Binding{}
+	fb.GNMIDialer = func(_ context.Context, dut *reservation.DUT, _ ...grpc.DialOption) (gpb.GNMIClient, error) {
+		if dut == slow {
+			<-unblock
+		}
+		dials[dut.Name]++
+		return &fakeGNMI{dials: dials[dut.Name]}, nil
+	}
+	binding.Init(fb)
+	defer Reset()
+
+	slowDone := make(chan gpb.GNMIClient)
+	for i := 0; i < 2; i++ {
+		go func() {
+			c, err := Fetch(context.Background(), slow)
+			if err != nil {
+				t.Errorf("Fetch(slow) failed: %v", err)
+			}
+			slowDone <- c
+		}()
+	}
+	fastDone := make(chan struct{})
+	go func() {
+		defer close(fastDone)
+		if _, err := Fetch(context.Background(), fast); err != nil {
+			t.Errorf("Fetch(fast) failed: %v", err)
+		}
+	}()
+	select {
+	case <-fastDone:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Fetch(fast) blocked on the dial of another device")
+	}
+	close(unblock)
+	if c1, c2 := <-slowDone, <-slowDone; c1 != c2 {
+		t.Errorf("concurrent Fetch(slow) got different clients %v and %v", c1, c2)
+	}
+	if dials["slow"] != 1 {
+		t.Errorf("concurrent Fetch(slow) dialed %d times, want once", dials["slow"])
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ondatra/internal/report"
+	"github.com/openconfig/ondatra/internal/usererr"
 
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
 )
@@ -65,9 +67,12 @@ func (b *SetRequestBatch) WithSubscriptionMode(mode gpb.SubscriptionMode) *SetRe
 // Set creates and makes a single gNMI SetRequest call for the batched set requests.
 func (b *SetRequestBatch) Set(t testing.TB) *gpb.SetResponse {
 	t.Helper()
+	if dev, _, err := resolveBatch(b.deviceRoot.Id(), b.deviceRoot.CustomData()); err == nil {
+		checkDeviceLeased(t, dev)
+	}
 	resp, err := batchSet("openconfig", b.deviceRoot.Id(), b.deviceRoot.CustomData(), b.req)
 	if err != nil {
-		t.Fatalf("SetRequestBatch.Set: %v", err)
+		report.Fatalf(t, err, "SetRequestBatch.Set(t)")
 	}
 	return resp
 }
@@ -81,7 +86,7 @@ func (b *SetRequestBatch) Reset() {
 func (b *SetRequestBatch) BatchDelete(t testing.TB, n ygot.PathStruct) {
 	t.Helper()
 	if err := b.batchSet(n, nil, updatePath); err != nil {
-		t.Fatal(err)
+		report.Fatalf(t, err, "BatchDelete(t)")
 	}
 }
 
@@ -89,7 +94,7 @@ func (b *SetRequestBatch) BatchDelete(t testing.TB, n ygot.PathStruct) {
 func (b *SetRequestBatch) BatchReplace(t testing.TB, n ygot.PathStruct, val interface{}) {
 	t.Helper()
 	if err := b.batchSet(n, val, replacePath); err != nil {
-		t.Fatal(err)
+		report.Fatalf(t, err, "BatchReplace(t)")
 	}
 }
 
@@ -97,7 +102,7 @@ func (b *SetRequestBatch) BatchReplace(t testing.TB, n ygot.PathStruct, val inte
 func (b *SetRequestBatch) BatchUpdate(t testing.TB, n ygot.PathStruct, val interface{}) {
 	t.Helper()
 	if err := b.batchSet(n, val, updatePath); err != nil {
-		t.Fatal(err)
+		report.Fatalf(t, err, "BatchUpdate(t)")
 	}
 }
 
@@ -105,17 +110,17 @@ func (b *SetRequestBatch) BatchUpdate(t testing.TB, n ygot.PathStruct, val inter
 func (b *SetRequestBatch) batchSet(n ygot.PathStruct, val interface{}, op setOperation) error {
 	path, customData, errs := ygot.ResolvePath(n)
 	if len(errs) > 0 {
-		return fmt.Errorf("%v", errs)
+		return usererr.New("%v", errs)
 	}
 	if path.GetTarget() != b.deviceRoot.Id() {
-		return fmt.Errorf("path target doesn't equal the device target for the batch: got %q, want %q", path.GetTarget(), b.deviceRoot.Id())
+		return usererr.New("path target doesn't equal the device target for the batch: got %q, want %q", path.GetTarget(), b.deviceRoot.Id())
 	}
 	if len(customData) != 0 {
-		return fmt.Errorf("batching cannot accept a path that has its custom request options; please set request options solely via the batch object")
+		return usererr.New("batching cannot accept a path that has its custom request options; please set request options solely via the batch object")
 	}
 
 	if err := populateSetRequest(b.req, path, val, op); err != nil {
-		return err
+		return usererr.Wrap(err)
 	}
 	return nil
 }
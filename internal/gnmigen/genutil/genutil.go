@@ -18,11 +18,9 @@ package genutil
 import (
 	"golang.org/x/net/context"
 	"fmt"
-	"math"
 	"reflect"
 	"sort"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
@@ -31,7 +29,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/openconfig/goyang/pkg/yang"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/prototext"
@@ -40,18 +37,17 @@ import (
 	"github.com/openconfig/ygot/ygot"
 	"github.com/openconfig/ygot/ytypes"
 	"github.com/openconfig/gnmi/errlist"
-	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
+	"github.com/openconfig/ondatra/internal/lease"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
 	"github.com/openconfig/ondatra/internal/testbed"
+	"github.com/openconfig/ondatra/internal/usererr"
 
 	gpb "github.com/openconfig/gnmi/proto/gnmi"
 )
 
-var (
-	mu    sync.Mutex
-	gnmis = make(map[reservation.Device]gpb.GNMIClient)
-)
-
 // DataPoint is a value of a gNMI path at a particular time.
 type DataPoint struct {
 	Path *gpb.Path
@@ -334,14 +330,32 @@ func unmarshal(data []*DataPoint, structSchema *yang.Entry, structPtr ygot.GoStr
 // datapoint.
 func Get(t testing.TB, n ygot.PathStruct, singleLeaf bool) ([]*DataPoint, *gpb.Path) {
 	t.Helper()
+	checkLeased(t, n)
 	data, path, err := get(context.Background(), n, singleLeaf)
 	if err != nil {
-		t.Fatalf("Get(t) at path %s: %v", path, err)
+		report.Fatalf(t, err, "Get(t) at path %s", path)
 	}
 	return data, path
 }
 
+// get gets the data at the path, retrying with a fresh gNMI client if the
+// Subscribe fails with a transient error.
 func get(ctx context.Context, n ygot.PathStruct, singleLeaf bool) ([]*DataPoint, *gpb.Path, error) {
+	var data []*DataPoint
+	var path *gpb.Path
+	err := retry.Do(ctx, func() error {
+		var err error
+		data, path, err = getOnce(ctx, n, singleLeaf)
+		return err
+	}, func() {
+		if _, dev, _, err := resolve(n); err == nil {
+			gnmiclient.Evict(dev)
+		}
+	})
+	return data, path, err
+}
+
+func getOnce(ctx context.Context, n ygot.PathStruct, singleLeaf bool) ([]*DataPoint, *gpb.Path, error) {
 	sub, path, err := subscribe(ctx, n, gpb.SubscriptionList_ONCE)
 	if err != nil {
 		return nil, path, errors.Wrap(err, "cannot subscribe to gNMI client")
@@ -441,9 +455,10 @@ type QualifiedValue interface {
 // CollectUntil uses CollectFn to retrieve a Collection sample for a PathStruct and evaluates data against the predicate.
 func CollectUntil(t testing.TB, n ygot.PathStruct, duration time.Duration, converter ConvertFunc, pred Predicate) *Collection {
 	t.Helper()
+	checkLeased(t, n)
 	coll, path, err := collect(context.Background(), n, duration, converter, pred)
 	if err != nil {
-		t.Fatalf("CollectUntil(t) at path %s: %v", path, err)
+		report.Fatalf(t, err, "CollectUntil(t) at path %s", path)
 	}
 	return coll
 }
@@ -523,7 +538,7 @@ func (c *Collection) Await(t testing.TB) ([]QualifiedValue, bool) {
 		if ok && st.Code() == codes.DeadlineExceeded {
 			isTimeout = true
 		} else {
-			t.Fatal(err)
+			report.Fatalf(t, err, "Await(t) at path %s", c.path)
 		}
 	}
 
@@ -558,9 +573,10 @@ func resolveBatch(target string, customData map[string]interface{}) (reservation
 // for the path specified by the path struct.
 func Delete(t testing.TB, n ygot.PathStruct) *gpb.SetResponse {
 	t.Helper()
+	checkLeased(t, n)
 	resp, path, err := set(context.Background(), n, nil, deletePath)
 	if err != nil {
-		t.Fatalf("Delete(t) at path %s: %v", path, err)
+		report.Fatalf(t, err, "Delete(t) at path %s", path)
 	}
 	return resp
 }
@@ -569,9 +585,10 @@ func Delete(t testing.TB, n ygot.PathStruct) *gpb.SetResponse {
 // for the path specified by the path struct.
 func Replace(t testing.TB, n ygot.PathStruct, val interface{}) *gpb.SetResponse {
 	t.Helper()
+	checkLeased(t, n)
 	resp, path, err := set(context.Background(), n, val, replacePath)
 	if err != nil {
-		t.Fatalf("Replace(t, %v) at path %s: %v", val, path, err)
+		report.Fatalf(t, err, "Replace(t, %v) at path %s", val, path)
 	}
 	return resp
 }
@@ -580,9 +597,10 @@ func Replace(t testing.TB, n ygot.PathStruct, val interface{}) *gpb.SetResponse
 // for the path specified by the path struct.
 func Update(t testing.TB, n ygot.PathStruct, val interface{}) *gpb.SetResponse {
 	t.Helper()
+	checkLeased(t, n)
 	resp, path, err := set(context.Background(), n, val, updatePath)
 	if err != nil {
-		t.Fatalf("Update(t, %v) at path %s: %v", val, path, err)
+		report.Fatalf(t, err, "Update(t, %v) at path %s", val, path)
 	}
 	return resp
 }
@@ -612,13 +630,14 @@ func setVals(ctx context.Context, dev reservation.Device, opts *requestOpts, ori
 		return nil, errors.Errorf("gNMI set cannot be called on ATEs: %v", dev)
 	}
 	ctx = metadata.NewOutgoingContext(ctx, opts.md)
-	gnmi, err := fetchGNMI(ctx, dut, opts.client)
-	if err != nil {
-		return nil, err
-	}
-
 	log.V(1).Info(prettySetRequest(req))
-	resp, err := gnmi.Set(ctx, req)
+	var resp *gpb.SetResponse
+	var err error
+	if opts.client != nil {
+		resp, err = opts.client.Set(ctx, req)
+	} else {
+		resp, err = gnmiclient.Set(ctx, dut, req)
+	}
 	log.V(1).Infof("SetResponse:\n%s", prototext.Format(resp))
 	if err != nil {
 		return nil, fmt.Errorf("SetRequest unsuccessful: %w", err)
@@ -626,6 +645,23 @@ func setVals(ctx context.Context, dev reservation.Device, opts *requestOpts, ori
 	return resp, nil
 }
 
+// checkLeased fails the test if it may not use the device of the path struct,
+// because another test has leased it. An unresolvable path struct is left to
+// fail the call itself.
+func checkLeased(t testing.TB, n ygot.PathStruct) {
+	t.Helper()
+	if _, dev, _, err := resolve(n); err == nil {
+		checkDeviceLeased(t, dev)
+	}
+}
+
+func checkDeviceLeased(t testing.TB, dev reservation.Device) {
+	t.Helper()
+	if err := lease.Check(t.Name(), dev); err != nil {
+		report.Fatalf(t, usererr.Wrap(err), "cannot use device %s", dev.Dimensions().Name)
+	}
+}
+
 // resolve resolves a path struct to a path, device, and request options.
 func resolve(n ygot.PathStruct) (*gpb.Path, reservation.Device, *requestOpts, error) {
 	path, customData, errs := ygot.ResolvePath(n)
@@ -688,7 +724,7 @@ func BundleDatapoints(t testing.TB, datapoints []*DataPoint, prefixLen uint, lea
 	t.Helper()
 	groups, prefixes, err := bundleDatapoints(datapoints, prefixLen, leaf)
 	if err != nil {
-		t.Fatal(err)
+		report.Fatalf(t, err, "BundleDatapoints(t) with prefix length %d", prefixLen)
 	}
 	return groups, prefixes
 }
@@ -732,45 +768,13 @@ func bundleDatapoints(datapoints []*DataPoint, prefixLen uint, leaf bool) (map[s
 	return groups, prefixes, nil
 }
 
-// NewGNMI creates a new gNMI client for the specified Device.
-func NewGNMI(ctx context.Context, dev reservation.Device) (gpb.GNMIClient, error) {
-	switch d := dev.(type) {
-	case *reservation.DUT:
-		c, err := binding.Get().DialGNMI(ctx, d,
-			grpc.WithBlock(),
-			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)))
-		if err != nil {
-			return nil, err
-		}
-		return c, nil
-	case *reservation.ATE:
-		c, err := binding.Get().DialATEGNMI(ctx, d, grpc.WithBlock())
-		if err != nil {
-			return nil, err
-		}
-		return c, nil
-	}
-	return nil, fmt.Errorf("unsupported device type: %T", dev)
-}
-
-// fetchGNMI fetches the gNMI client for the given device.
+// fetchGNMI fetches the cached gNMI client for the given device.
 // If a GNMIClient is provided it will be just returned as is and not cached.
 func fetchGNMI(ctx context.Context, dev reservation.Device, c gpb.GNMIClient) (gpb.GNMIClient, error) {
 	if c != nil {
 		return c, nil
 	}
-	mu.Lock()
-	defer mu.Unlock()
-	c, ok := gnmis[dev]
-	if !ok {
-		var err error
-		c, err = NewGNMI(ctx, dev)
-		if err != nil {
-			return nil, err
-		}
-		gnmis[dev] = c
-	}
-	return c, nil
+	return gnmiclient.Fetch(ctx, dev)
 }
 
 func subscribe(ctx context.Context, n ygot.PathStruct, mode gpb.SubscriptionList_Mode) (_ gpb.GNMI_SubscribeClient, _ *gpb.Path, rerr error) {
@@ -785,6 +789,9 @@ func subscribe(ctx context.Context, n ygot.PathStruct, mode gpb.SubscriptionList
 	}
 	sub, err := gnmi.Subscribe(ctx)
 	if err != nil {
+		if opts.client == nil && retry.IsTransient(err) {
+			gnmiclient.Evict(dev)
+		}
 		return nil, path, errors.Wrap(err, "gNMI failed to Subscribe")
 	}
 	defer closer.Close(&rerr, sub.CloseSend, "error closing gNMI send stream")
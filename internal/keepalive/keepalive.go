@@ -0,0 +1,185 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keepalive keeps a reservation alive while tests run: it heartbeats
+// the reservation through the binding, warns as the reservation nears its
+// expiry, and extends the reservation up to a cap.
+package keepalive
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/openconfig/ondatra/internal/binding"
+)
+
+// nowFn is stubbed out in tests.
+var nowFn = time.Now
+
+// Config configures a Keeper.
+type Config struct {
+	// Interval is the time between heartbeats.
+	Interval time.Duration
+	// Warning is how long before the expiry to start warning and extending.
+	Warning time.Duration
+	// ExtendBy is how much to extend the reservation by at a time.
+	ExtendBy time.Duration
+	// MaxExtension caps the total extension; zero disables extending.
+	MaxExtension time.Duration
+}
+
+// Keeper keeps a reservation alive.
+type Keeper struct {
+	b     binding.Binding
+	cfg   Config
+	warnf func(format string, args ...interface{})
+
+	mu       sync.Mutex
+	expiry   time.Time
+	extended time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a Keeper of a reservation that expires after the run time, or
+// never if the run time is zero. The binding may be nil, and it is only
+// heartbeated or extended if it implements binding.Heartbeater or
+// binding.Extender. Warnings are reported with warnf.
+func New(b binding.Binding, runTime time.Duration, cfg Config, warnf func(format string, args ...interface{})) *Keeper {
+	k := &Keeper{b: b, cfg: cfg, warnf: warnf}
+	if runTime > 0 {
+		k.expiry = nowFn().Add(runTime)
+	}
+	return k
+}
+
+// Start heartbeats the reservation in the background until Stop is called.
+func (k *Keeper) Start(ctx context.Context) {
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+	go func() {
+		defer close(k.done)
+		if k.cfg.Interval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(k.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				k.tick(ctx)
+			case <-k.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops heartbeating the reservation.
+func (k *Keeper) Stop() {
+	if k.stop == nil {
+		return
+	}
+	close(k.stop)
+	<-k.done
+	k.stop = nil
+}
+
+// Remaining returns the time left until the reservation expires, and false if
+// the reservation does not expire.
+func (k *Keeper) Remaining() (time.Duration, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.expiry.IsZero() {
+		return 0, false
+	}
+	return k.expiry.Sub(nowFn()), true
+}
+
+// Expired returns whether the reservation has expired.
+func (k *Keeper) Expired() bool {
+	left, ok := k.Remaining()
+	return ok && left <= 0
+}
+
+// ExpiryWarning returns a warning that the reservation expires soon, or "" if
+// it does not expire within the warning period.
+func (k *Keeper) ExpiryWarning() string {
+	left, ok := k.Remaining()
+	if !ok || left > k.cfg.Warning || left <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("the reservation expires in %v", left.Round(time.Second))
+}
+
+func (k *Keeper) tick(ctx context.Context) {
+	if hb, ok := k.b.(binding.Heartbeater); ok {
+		expiry, err := hb.Heartbeat(ctx)
+		switch {
+		case err != nil:
+			k.warnf("Reservation heartbeat failed: %v", err)
+		case !expiry.IsZero():
+			k.mu.Lock()
+			k.expiry = expiry
+			k.mu.Unlock()
+		}
+	}
+	left, ok := k.Remaining()
+	if !ok || left > k.cfg.Warning {
+		return
+	}
+	if k.extend(ctx) {
+		return
+	}
+	if left > 0 {
+		k.warnf("The reservation expires in %v", left.Round(time.Second))
+	}
+}
+
+// extend extends the reservation if the binding supports it and the cap
+// allows it, and returns whether it did.
+func (k *Keeper) extend(ctx context.Context) bool {
+	ext, ok := k.b.(binding.Extender)
+	if !ok {
+		return false
+	}
+	k.mu.Lock()
+	by := k.cfg.MaxExtension - k.extended
+	k.mu.Unlock()
+	if by > k.cfg.ExtendBy {
+		by = k.cfg.ExtendBy
+	}
+	if by <= 0 {
+		return false
+	}
+	expiry, err := ext.Extend(ctx, by)
+	if err != nil {
+		k.warnf("Failed to extend the reservation by %v: %v", by, err)
+		return false
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if expiry.IsZero() {
+		expiry = k.expiry.Add(by)
+	}
+	k.expiry = expiry
+	k.extended += by
+	log.Infof("Extended the reservation by %v; it now expires at %v", by, expiry)
+	return true
+}
@@ -0,0 +1,190 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keepalive
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+)
+
+type fakeBinding struct {
+	binding.Binding
+	heartbeatExpiry time.Time
+	heartbeatErr    error
+	heartbeats      int
+}
+
+func (b *fakeBinding) Heartbeat(context.Context) (time.Time, error) {
+	b.heartbeats++
+	return b.heartbeatExpiry, b.heartbeatErr
+}
+
+type fakeExtender struct {
+	fakeBinding
+	extendErr error
+	extends   []time.Duration
+}
+
+func (b *fakeExtender) Extend(_ context.Context, by time.Duration) (time.Time, error) {
+	if b.extendErr != nil {
+		return time.Time{}, b.extendErr
+	}
+	b.extends = append(b.extends, by)
+	return time.Time{}, nil
+}
+
+var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func stubNow(t *testing.T) *time.Time {
+	t.Helper()
+	now := start
+	nowFn = func() time.Time { return now }
+	t.Cleanup(func() { nowFn = time.Now })
+	return &now
+}
+
+type warnings []string
+
+func (w *warnings) warnf(format string, args ...interface{}) {
+	*w = append(*w, fmt.Sprintf(format, args...))
+}
+
+var cfg = Config{
+	Interval:     time.Minute,
+	Warning:      10 * time.Minute,
+	ExtendBy:     30 * time.Minute,
+	MaxExtension: 45 * time.Minute,
+}
+
+func TestNoExpiry(t *testing.T) {
+	stubNow(t)
+	var w warnings
+	k := New(nil, 0, cfg, w.warnf)
+	k.tick(context.Background())
+	if _, ok := k.Remaining(); ok {
+		t.Errorf("Remaining() reported an expiry, want none")
+	}
+	if k.Expired() {
+		t.Errorf("Expired() got true, want false")
+	}
+	if len(w) > 0 {
+		t.Errorf("tick() warned %q, want no warnings", w)
+	}
+}
+
+func TestWarnAndExpire(t *testing.T) {
+	now := stubNow(t)
+	var w warnings
+	k := New(nil, time.Hour, cfg, w.warnf)
+
+	*now = start.Add(45 * time.Minute)
+	k.tick(context.Background())
+	if len(w) > 0 {
+		t.Errorf("tick() before the warning period warned %q, want no warnings", w)
+	}
+	if got := k.ExpiryWarning(); got != "" {
+		t.Errorf("ExpiryWarning() before the warning period got %q, want empty", got)
+	}
+
+	*now = start.Add(55 * time.Minute)
+	k.tick(context.Background())
+	want := warnings{"The reservation expires in 5m0s"}
+	if diff := cmp.Diff(want, w); diff != "" {
+		t.Errorf("tick() in the warning period got unexpected warnings diff (-want,+got):\n%s", diff)
+	}
+	if got, want := k.ExpiryWarning(), "the reservation expires in 5m0s"; got != want {
+		t.Errorf("ExpiryWarning() got %q, want %q", got, want)
+	}
+	if k.Expired() {
+		t.Errorf("Expired() before the expiry got true, want false")
+	}
+
+	*now = start.Add(time.Hour)
+	if !k.Expired() {
+		t.Errorf("Expired() at the expiry got false, want true")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	now := stubNow(t)
+	b := &fakeBinding{heartbeatExpiry: start.Add(2 * time.Hour)}
+	var w warnings
+	k := New(b, time.Hour, cfg, w.warnf)
+	k.tick(context.Background())
+	if b.heartbeats != 1 {
+		t.Errorf("tick() sent %d heartbeats, want 1", b.heartbeats)
+	}
+	*now = start.Add(time.Hour)
+	if k.Expired() {
+		t.Errorf("Expired() past the run time got true, want the heartbeat expiry to apply")
+	}
+
+	b.heartbeatErr = errors.New("lost contact")
+	k.tick(context.Background())
+	if len(w) != 1 || !strings.Contains(w[0], "lost contact") {
+		t.Errorf("tick() after a failed heartbeat warned %q, want a warning about the failure", w)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	now := stubNow(t)
+	b := &fakeExtender{}
+	var w warnings
+	k := New(b, time.Hour, cfg, w.warnf)
+
+	*now = start.Add(55 * time.Minute)
+	k.tick(context.Background())
+	*now = start.Add(85 * time.Minute)
+	k.tick(context.Background())
+	*now = start.Add(100 * time.Minute)
+	k.tick(context.Background())
+
+	if diff := cmp.Diff([]time.Duration{30 * time.Minute, 15 * time.Minute}, b.extends); diff != "" {
+		t.Errorf("tick() got unexpected extensions diff (-want,+got):\n%s", diff)
+	}
+	if got, _ := k.Remaining(); got != 5*time.Minute {
+		t.Errorf("Remaining() after extending got %v, want %v", got, 5*time.Minute)
+	}
+	want := warnings{"The reservation expires in 5m0s"}
+	if diff := cmp.Diff(want, w); diff != "" {
+		t.Errorf("tick() after reaching the extension cap got unexpected warnings diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestExtendError(t *testing.T) {
+	now := stubNow(t)
+	b := &fakeExtender{extendErr: errors.New("no capacity")}
+	var w warnings
+	k := New(b, time.Hour, cfg, w.warnf)
+	*now = start.Add(55 * time.Minute)
+	k.tick(context.Background())
+	if len(w) != 2 || !strings.Contains(w[0], "no capacity") || !strings.Contains(w[1], "expires in 5m0s") {
+		t.Errorf("tick() after a failed extension warned %q, want a failure and an expiry warning", w)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	k := New(nil, 0, Config{Interval: time.Millisecond}, func(string, ...interface{}) {})
+	k.Start(context.Background())
+	k.Stop()
+	k.Stop()
+}
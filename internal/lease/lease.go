@@ -0,0 +1,118 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lease records which test holds an exclusive lease on each reserved
+// device, so that tests that run in parallel cannot use the devices, or the
+// clients cached for the devices, of one another.
+package lease
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/reservation"
+)
+
+var (
+	mu   sync.Mutex
+	cond = sync.NewCond(&mu)
+	// holders maps a leased device to the name of the test that leased it.
+	holders = make(map[reservation.Device]string)
+)
+
+// Acquire blocks until every device can be leased by the test, then leases
+// them all at once, so tests with overlapping leases cannot deadlock.
+func Acquire(test string, devs []reservation.Device) {
+	mu.Lock()
+	defer mu.Unlock()
+	for !availableLocked(devs) {
+		cond.Wait()
+	}
+	for _, dev := range devs {
+		holders[dev] = test
+	}
+}
+
+func availableLocked(devs []reservation.Device) bool {
+	for _, dev := range devs {
+		if _, ok := holders[dev]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Release returns the devices leased by the test.
+func Release(test string, devs []reservation.Device) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, dev := range devs {
+		if holders[dev] == test {
+			delete(holders, dev)
+		}
+	}
+	cond.Broadcast()
+}
+
+// Held returns the number of devices that are currently leased.
+func Held() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(holders)
+}
+
+// Leased returns the devices leased to the test or its nearest leasing
+// ancestor, and whether any are leased at all.
+func Leased(test string) (map[reservation.Device]bool, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	return leasedLocked(test)
+}
+
+func leasedLocked(test string) (map[reservation.Device]bool, bool) {
+	for name := test; ; {
+		devs := make(map[reservation.Device]bool)
+		for dev, h := range holders {
+			if h == name {
+				devs[dev] = true
+			}
+		}
+		if len(devs) > 0 {
+			return devs, true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return nil, false
+		}
+		name = name[:i]
+	}
+}
+
+// Check returns an error if the test may not use the device: if the test has
+// leased devices but not this one, or if another test has leased it.
+func Check(test string, dev reservation.Device) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if devs, ok := leasedLocked(test); ok {
+		if !devs[dev] {
+			return errors.Errorf("device %s is not leased by test %s", dev.Dimensions().Name, test)
+		}
+		return nil
+	}
+	if h, ok := holders[dev]; ok {
+		return errors.Errorf("device %s is leased by test %s, not by test %s", dev.Dimensions().Name, h, test)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lease
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openconfig/ondatra/internal/reservation"
+)
+
+func TestCheck(t *testing.T) {
+	dut1 := &reservation.DUT{Dims: &reservation.Dims{Name: "dut1"}}
+	dut2 := &reservation.DUT{Dims: &reservation.Dims{Name: "dut2"}}
+	ate := &reservation.ATE{Dims: &reservation.Dims{Name: "ate"}}
+	Acquire("TestA", []reservation.Device{dut1, ate})
+	defer Release("TestA", []reservation.Device{dut1, ate})
+
+	tests := []struct {
+		desc, test string
+		dev        reservation.Device
+		wantErr    string
+	}{{
+		desc: "leased by test",
+		test: "TestA",
+		dev:  dut1,
+	}, {
+		desc: "leased by parent",
+		test: "TestA/sub",
+		dev:  ate,
+	}, {
+		desc:    "not leased by leasing test",
+		test:    "TestA",
+		dev:     dut2,
+		wantErr: "not leased by test TestA",
+	}, {
+		desc:    "leased by other test",
+		test:    "TestB",
+		dev:     dut1,
+		wantErr: "leased by test TestA",
+	}, {
+		desc: "not leased",
+		test: "TestB",
+		dev:  dut2,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := Check(test.test, test.dev)
+			if (err == nil) != (test.wantErr == "") || (err != nil && !strings.Contains(err.Error(), test.wantErr)) {
+				t.Errorf("Check(%q, %s) got error %v, want error containing %q", test.test, test.dev.Dimensions().Name, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAcquireBlocks(t *testing.T) {
+	dut := &reservation.DUT{Dims: &reservation.Dims{Name: "dut"}}
+	Acquire("TestA", []reservation.Device{dut})
+	acquired := make(chan struct{})
+	go func() {
+		Acquire("TestB", []reservation.Device{dut})
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() of a leased device did not block")
+	default:
+	}
+	Release("TestA", []reservation.Device{dut})
+	<-acquired
+	if _, ok := Leased("TestB"); !ok {
+		t.Errorf("Leased(TestB) got no devices after Acquire()")
+	}
+	Release("TestB", []reservation.Device{dut})
+	if n := Held(); n != 0 {
+		t.Errorf("Held() got %d, want 0", n)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operations
+
+import (
+	"golang.org/x/net/context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	tpb "github.com/openconfig/gnoi/types"
+)
+
+// Component is a hardware component of a DUT, as reported in the state of the
+// OpenConfig /components tree. Identity values are stripped of their YANG
+// module prefix, e.g. "openconfig-platform-types:LINECARD" is "LINECARD".
+type Component struct {
+	Name        string
+	Type        string
+	Parent      string
+	Description string
+	// OperStatus is the operational status, e.g. "ACTIVE" or "INACTIVE".
+	OperStatus string
+	// RedundantRole is the role of a redundant component, such as a control
+	// processor, i.e. "PRIMARY" or "SECONDARY"; it is empty otherwise.
+	RedundantRole string
+}
+
+// Components returns the hardware components of a device, sorted by name.
+func Components(ctx context.Context, dev reservation.Device) ([]*Component, error) {
+	dut, err := checkDUT(dev, "components")
+	if err != nil {
+		return nil, err
+	}
+	return components(ctx, dut)
+}
+
+func components(ctx context.Context, dut *reservation.DUT) ([]*Component, error) {
+	var resp *gpb.GetResponse
+	if err := retry.Do(ctx, func() error {
+		gnmi, err := gnmiclient.Fetch(ctx, dut)
+		if err != nil {
+			return err
+		}
+		resp, err = gnmi.Get(ctx, &gpb.GetRequest{
+			Path:     []*gpb.Path{{Elem: []*gpb.PathElem{{Name: "components"}}}},
+			Type:     gpb.GetRequest_STATE,
+			Encoding: gpb.Encoding_JSON_IETF,
+		})
+		return err
+	}, func() { gnmiclient.Evict(dut) }); err != nil {
+		return nil, errors.Wrapf(err, "error getting components of %s", dut.Name)
+	}
+	return parseComponents(resp)
+}
+
+// parseComponents parses the components in a gNMI Get response of the
+// /components path. A device may return the whole tree in one update or split
+// it into updates of any subtree, down to individual leaves.
+func parseComponents(resp *gpb.GetResponse) ([]*Component, error) {
+	byName := make(map[string]*Component)
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			data := u.GetVal().GetJsonIetfVal()
+			if data == nil {
+				data = u.GetVal().GetJsonVal()
+			}
+			var v interface{}
+			if data != nil {
+				if err := json.Unmarshal(data, &v); err != nil {
+					return nil, errors.Wrapf(err, "invalid JSON value of update at %v", u.GetPath())
+				}
+			} else if sv, ok := u.GetVal().GetValue().(*gpb.TypedValue_StringVal); ok {
+				v = sv.StringVal
+			}
+			elems := append(append([]*gpb.PathElem(nil), n.GetPrefix().GetElem()...), u.GetPath().GetElem()...)
+			root, ok := unprefixed(wrap(v, elems)).(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("unexpected value of update at %v: %s", u.GetPath(), data)
+			}
+			comps, _ := root["components"].(map[string]interface{})
+			list, _ := comps["component"].([]interface{})
+			for _, e := range list {
+				mergeComponent(byName, e)
+			}
+		}
+	}
+	var comps []*Component
+	for _, c := range byName {
+		comps = append(comps, c)
+	}
+	sort.Slice(comps, func(i, j int) bool { return comps[i].Name < comps[j].Name })
+	return comps, nil
+}
+
+// wrap nests a value at the specified path in the containers and list entries
+// of the path, so that it becomes a subtree of the root.
+func wrap(v interface{}, elems []*gpb.PathElem) interface{} {
+	for i := len(elems) - 1; i >= 0; i-- {
+		e := elems[i]
+		if len(e.GetKey()) == 0 {
+			v = map[string]interface{}{e.GetName(): v}
+			continue
+		}
+		entry, ok := v.(map[string]interface{})
+		if !ok {
+			entry = make(map[string]interface{})
+		}
+		for k, kv := range e.GetKey() {
+			entry[k] = kv
+		}
+		v = map[string]interface{}{e.GetName(): []interface{}{entry}}
+	}
+	return v
+}
+
+// unprefixed returns the JSON value with the YANG module prefixes removed from
+// its object keys.
+func unprefixed(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range v {
+			m[stripPrefix(k)] = unprefixed(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = unprefixed(e)
+		}
+	}
+	return v
+}
+
+func stripPrefix(s string) string {
+	if i := strings.Index(s, ":"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+func mergeComponent(byName map[string]*Component, e interface{}) {
+	m, ok := e.(map[string]interface{})
+	if !ok {
+		return
+	}
+	name, _ := m["name"].(string)
+	if name == "" {
+		return
+	}
+	c, ok := byName[name]
+	if !ok {
+		c = &Component{Name: name}
+		byName[name] = c
+	}
+	state, _ := m["state"].(map[string]interface{})
+	for key, field := range map[string]*string{
+		"parent":      &c.Parent,
+		"description": &c.Description,
+	} {
+		if s, ok := state[key].(string); ok {
+			*field = s
+		}
+	}
+	for key, field := range map[string]*string{
+		"type":           &c.Type,
+		"oper-status":    &c.OperStatus,
+		"redundant-role": &c.RedundantRole,
+	} {
+		if s, ok := state[key].(string); ok {
+			*field = stripPrefix(s)
+		}
+	}
+}
+
+func findComponent(comps []*Component, name string) *Component {
+	for _, c := range comps {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// awaitComponents polls the components of the DUT until they satisfy the
+// condition or the deadline passes. Errors are tolerated while polling, as the
+// device may be unreachable while its components restart.
+func awaitComponents(ctx context.Context, dut *reservation.DUT, deadline time.Time, cond func([]*Component) bool, desc string) error {
+	var lastErr error
+	for !time.Now().After(deadline) {
+		comps, err := components(ctx, dut)
+		if err == nil && cond(comps) {
+			return nil
+		}
+		if err != nil {
+			log.Infof("Waiting for %s: %v", desc, err)
+			gnmiclient.Evict(dut)
+			lastErr = err
+		}
+		if err := sleep(ctx, StatusWait); err != nil {
+			return errors.Wrapf(err, "stopped waiting for %s", desc)
+		}
+	}
+	if lastErr != nil {
+		return errors.Wrapf(lastErr, "timed out waiting for %s", desc)
+	}
+	return errors.Errorf("timed out waiting for %s", desc)
+}
+
+// componentPath returns the gNOI path of the named component.
+func componentPath(name string) *tpb.Path {
+	return &tpb.Path{
+		Origin: "openconfig",
+		Elem: []*tpb.PathElem{
+			{Name: "components"},
+			{Name: "component", Key: map[string]string{"name": name}},
+		},
+	}
+}
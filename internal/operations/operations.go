@@ -28,20 +28,27 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
+	"github.com/openconfig/ygot/ygot"
 	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/dut"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
 	"github.com/openconfig/ondatra/internal/usererr"
+	"github.com/openconfig/ondatra/telemetry"
 
 	ospb "github.com/openconfig/gnoi/os"
 	spb "github.com/openconfig/gnoi/system"
+	tpb "github.com/openconfig/gnoi/types"
 )
 
-const (
-	defaultRebootTimeout = 30 * time.Minute
-	defaultStatusWait    = 10 * time.Second
-)
+const defaultRebootTimeout = 30 * time.Minute
 
 var (
+	// StatusWait is the time between polls of the status of a device, unless
+	// the device specifies it. It is a variable so that tests can shorten it.
+	StatusWait = 10 * time.Second
+
 	mu    sync.Mutex
 	gnois = make(map[*reservation.DUT]binding.GNOIClients)
 )
@@ -67,6 +74,14 @@ func fetchGNOI(ctx context.Context, dut *reservation.DUT) (binding.GNOIClients,
 	return gnoi, nil
 }
 
+// evictGNOI removes the cached gNOI client for the given DUT, if any, so that
+// the next fetch re-dials it through the binding.
+func evictGNOI(dut *reservation.DUT) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(gnois, dut)
+}
+
 // Install executes an install operation.
 // The gNOI install scenarios are documented on the Install function here:
 // https://github.com/openconfig/gnoi/blob/master/os/os.proto
@@ -99,7 +114,7 @@ func Install(ctx context.Context, dev reservation.Device, version string, standb
 	}
 	if validated == nil { // if content is needed
 		if reader == nil {
-			return errors.Errorf("device %v does not have package and no package specified in install operation", dev)
+			return usererr.New("device %v does not have package and no package specified in install operation", dev)
 		}
 		awaitChan := make(chan error)
 		go func() {
@@ -114,7 +129,7 @@ func Install(ctx context.Context, dev reservation.Device, version string, standb
 		}
 	}
 	if gotVersion := validated.GetVersion(); gotVersion != version {
-		return errors.Errorf("installed version %q does not match requested version %q", gotVersion, version)
+		return usererr.New("installed version %q does not match requested version %q", gotVersion, version)
 	}
 	return nil
 }
@@ -169,21 +184,27 @@ func awaitPackageInstall(ic ospb.OS_InstallClient) (*ospb.Validated, error) {
 }
 
 // Ping executes the ping command from target device to a specified destination.
-func Ping(ctx context.Context, dev reservation.Device, dest string) (rerr error) {
+func Ping(ctx context.Context, dev reservation.Device, dest string) error {
 	dut, err := checkDUT(dev, "ping")
 	if err != nil {
 		return err
 	}
 	if dest == "" {
-		return errors.Errorf("no destination for ping operation: %v", dest)
+		return usererr.New("no destination for ping operation: %v", dest)
 	}
+	return retry.Do(ctx, func() error {
+		return ping(ctx, dut, dest)
+	}, func() { evictGNOI(dut) })
+}
+
+func ping(ctx context.Context, dut *reservation.DUT, dest string) (rerr error) {
 	gnoi, err := fetchGNOI(ctx, dut)
 	if err != nil {
 		return errors.Wrap(err, "error dialing gnoi")
 	}
 	ping, err := gnoi.System().Ping(ctx, &spb.PingRequest{Destination: dest})
 	if err != nil {
-		return errors.Wrapf(err, "error on gnoi ping of %s from %v", dest, dev)
+		return errors.Wrapf(err, "error on gnoi ping of %s from %v", dest, dut)
 	}
 	defer closer.Close(&rerr, ping.CloseSend, "error closing gnoi ping client")
 	if _, err := ping.Recv(); err != nil {
@@ -195,10 +216,10 @@ func Ping(ctx context.Context, dev reservation.Device, dest string) (rerr error)
 // SetInterfaceState sets the state of a specified interface on a device.
 func SetInterfaceState(ctx context.Context, dev reservation.Device, intf string, enabled *bool) error {
 	if intf == "" {
-		return errors.Errorf("no interface provided in set interface state operation on device %v", dev)
+		return usererr.New("no interface provided in set interface state operation on device %v", dev)
 	}
 	if enabled == nil {
-		return errors.Errorf("no enabled state provided in set interface state operation on device %v", dev)
+		return usererr.New("no enabled state provided in set interface state operation on device %v", dev)
 	}
 	if dut, ok := dev.(*reservation.DUT); ok {
 		return setDUTInterfaceState(ctx, dut, intf, *enabled)
@@ -206,78 +227,153 @@ func SetInterfaceState(ctx context.Context, dev reservation.Device, intf string,
 	return binding.Get().SetATEPortState(dev.(*reservation.ATE), intf, *enabled)
 }
 
-func setDUTInterfaceState(ctx context.Context, dut *reservation.DUT, intf string, enabled bool) error {
-	const ocFormat = `{
-   "interfaces": {
-      "interface": [
-         {
-            "config": {
-               "enabled": %v,
-               "name": "%s",
-               "type": "ethernetCsmacd"
-            },
-            "name": "%s"
-         }
-      ]
-   }
-}`
-	ocCfg := fmt.Sprintf(ocFormat, enabled, intf, intf)
-	opts := &binding.ConfigOptions{OpenConfig: true, Append: true}
-	if err := binding.Get().PushConfig(ctx, dut, ocCfg, opts); err != nil {
+func setDUTInterfaceState(ctx context.Context, d *reservation.DUT, intf string, enabled bool) error {
+	cfg := &dut.Config{Open: dut.ConfigStruct{
+		Path: ygot.NewNodePath(
+			[]string{"interfaces", "interface"},
+			map[string]interface{}{"name": intf},
+			ygot.NewDeviceRootBase(d.Name)),
+		Struct: &telemetry.Interface{
+			Name:    ygot.String(intf),
+			Enabled: ygot.Bool(enabled),
+			Type:    telemetry.IETFInterfaces_InterfaceType_ethernetCsmacd,
+		},
+	}}
+	if _, err := dut.PushConfig(ctx, d, cfg, true); err != nil {
 		return errors.Wrap(err, "failed to set interface state")
 	}
 	return nil
 }
 
-// Reboot reboots a device.
-func Reboot(ctx context.Context, dev reservation.Device, timeout time.Duration) error {
-	dut, err := checkDUT(dev, "restart routing")
+// Reboot reboots a device or, if the component is not empty, only the named
+// component of the device, e.g. a line card. When rebooting a component, it
+// also waits for the component to go down and become active again.
+func Reboot(ctx context.Context, dev reservation.Device, component string, timeout time.Duration) error {
+	dut, err := checkDUT(dev, "reboot")
 	if err != nil {
 		return err
 	}
-	gnoi, err := fetchGNOI(ctx, dut)
-	if err != nil {
-		return errors.Wrap(err, "error dialing gnoi")
-	}
-	if _, err := gnoi.System().Reboot(ctx, &spb.RebootRequest{Method: spb.RebootMethod_COLD}); err != nil {
-		return errors.Wrap(err, "error on gnoi reboot")
-	}
 	rebootTimeout := timeout
 	switch {
 	case rebootTimeout == 0:
 		rebootTimeout = defaultRebootTimeout
 	case rebootTimeout < 0:
-		return errors.New("reboot timeout must be a positive duration")
+		return usererr.New("reboot timeout must be a positive duration")
+	}
+	gnoi, err := fetchGNOI(ctx, dut)
+	if err != nil {
+		return errors.Wrap(err, "error dialing gnoi")
+	}
+	req := &spb.RebootRequest{Method: spb.RebootMethod_COLD}
+	statusReq := &spb.RebootStatusRequest{}
+	if component != "" {
+		req.Subcomponents = []*tpb.Path{componentPath(component)}
+		statusReq.Subcomponents = req.Subcomponents
+	}
+	if _, err := gnoi.System().Reboot(ctx, req); err != nil {
+		return errors.Wrap(err, "error on gnoi reboot")
 	}
 	rebootDeadline := time.Now().Add(rebootTimeout)
-	retry := true
-	for retry {
-		if time.Now().After(rebootDeadline) {
-			retry = false
-			break
-		}
-		resp, err := gnoi.System().RebootStatus(ctx, &spb.RebootStatusRequest{})
+	for !time.Now().After(rebootDeadline) {
+		resp, err := gnoi.System().RebootStatus(ctx, statusReq)
 		switch {
 		case status.Code(err) == codes.Unimplemented:
 			// Unimplemented means we don't have a valid way
 			// to validate health of reboot.
-			return nil
+			return awaitComponent(ctx, dut, component, rebootDeadline)
 		case err == nil:
 			if !resp.GetActive() {
-				return nil
+				return awaitComponent(ctx, dut, component, rebootDeadline)
+			}
+		case retry.IsTransient(err):
+			// The connection was likely dropped by the reboot, so re-dial.
+			evictGNOI(dut)
+			if c, err := fetchGNOI(ctx, dut); err == nil {
+				gnoi = c
 			}
 		default:
 			// any other error just sleep.
 		}
 		statusWait := time.Duration(resp.GetWait()) * time.Nanosecond
 		if statusWait <= 0 {
-			statusWait = defaultStatusWait
+			statusWait = StatusWait
+		}
+		if err := sleep(ctx, statusWait); err != nil {
+			return errors.Wrapf(err, "stopped waiting for reboot of %s", dev)
 		}
-		time.Sleep(statusWait)
 	}
 	return errors.Errorf("reboot of %s timed out after %s", dev, rebootTimeout)
 }
 
+// sleep waits for the duration, unless the context is done first, in which
+// case it returns the error of the context.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// awaitComponent waits until the named component of the DUT is no longer
+// active, then until it is active again, unless the component is empty. The
+// device may report that the reboot is no longer active before the component
+// has even gone down.
+func awaitComponent(ctx context.Context, dut *reservation.DUT, component string, deadline time.Time) error {
+	if component == "" {
+		return nil
+	}
+	active := func(comps []*Component) bool {
+		c := findComponent(comps, component)
+		return c != nil && c.OperStatus == "ACTIVE"
+	}
+	if err := awaitComponents(ctx, dut, deadline, func(comps []*Component) bool {
+		return !active(comps)
+	}, fmt.Sprintf("component %s of %s to go down", component, dut.Name)); err != nil {
+		return err
+	}
+	return awaitComponents(ctx, dut, deadline, active,
+		fmt.Sprintf("component %s of %s to become active", component, dut.Name))
+}
+
+// SwitchControlProcessor switches the active control processor, i.e. the
+// supervisor, of a device to the named component, then waits for the device to
+// report the component as the primary control processor.
+func SwitchControlProcessor(ctx context.Context, dev reservation.Device, target string, timeout time.Duration) error {
+	dut, err := checkDUT(dev, "switch control processor")
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return usererr.New("no target control processor provided in switch control processor operation on device %v", dev)
+	}
+	switch {
+	case timeout == 0:
+		timeout = defaultRebootTimeout
+	case timeout < 0:
+		return usererr.New("switch control processor timeout must be a positive duration")
+	}
+	gnoi, err := fetchGNOI(ctx, dut)
+	if err != nil {
+		return errors.Wrap(err, "error dialing gnoi")
+	}
+	if _, err := gnoi.System().SwitchControlProcessor(ctx, &spb.SwitchControlProcessorRequest{
+		ControlProcessor: componentPath(target),
+	}); err != nil && !retry.IsTransient(err) {
+		return errors.Wrap(err, "error on gnoi switch control processor")
+	}
+	// The switchover drops the connections served by the old supervisor.
+	evictGNOI(dut)
+	gnmiclient.Evict(dut)
+	return awaitComponents(ctx, dut, time.Now().Add(timeout), func(comps []*Component) bool {
+		c := findComponent(comps, target)
+		return c != nil && c.RedundantRole == "PRIMARY"
+	}, fmt.Sprintf("control processor %s of %s to become primary", target, dut.Name))
+}
+
 // RestartRouting restarts routing on a device.
 func RestartRouting(ctx context.Context, dev reservation.Device) error {
 	dut, err := checkDUT(dev, "restart routing")
@@ -289,7 +385,7 @@ func RestartRouting(ctx context.Context, dev reservation.Device) error {
 
 func checkDUT(dev reservation.Device, op string) (*reservation.DUT, error) {
 	if _, ok := dev.(*reservation.ATE); ok {
-		return nil, errors.Errorf("%s operation not supported on ATEs: %v", op, dev)
+		return nil, usererr.New("%s operation not supported on ATEs: %v", op, dev)
 	}
 	return dev.(*reservation.DUT), nil
 }
@@ -22,6 +22,7 @@ import (
 	"google.golang.org/grpc"
 	"github.com/openconfig/ondatra/internal/binding"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/retry"
 
 	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
 )
@@ -31,18 +32,43 @@ var (
 	p4rts = make(map[*reservation.DUT]p4pb.P4RuntimeClient)
 )
 
-// FetchP4RT fetches the P4RT client for the given DUT.
+// idempotentMethods are the P4RT methods that only read state from the DUT,
+// so a call that fails with a transient error can be safely retried on a
+// re-dialed client.
+var idempotentMethods = map[string]bool{
+	"/p4.v1.P4Runtime/GetForwardingPipelineConfig": true,
+	"/p4.v1.P4Runtime/Capabilities":                true,
+}
+
+// FetchP4RT fetches a cached P4RT client for the given DUT.
+// Idempotent unary calls on the client are retried if they fail with a
+// transient error; any such failure also evicts the client from the cache,
+// so that the next fetch re-dials it through the binding.
 func FetchP4RT(ctx context.Context, dut *reservation.DUT) (p4pb.P4RuntimeClient, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	p4rt, ok := p4rts[dut]
 	if !ok {
 		var err error
-		p4rt, err = binding.Get().DialP4RT(ctx, dut, grpc.WithBlock())
+		var c p4pb.P4RuntimeClient
+		interceptor := retry.UnaryClientInterceptor(
+			func(method string) bool { return idempotentMethods[method] },
+			func() { evictP4RT(dut, c) })
+		c, err = binding.Get().DialP4RT(ctx, dut, grpc.WithBlock(), grpc.WithChainUnaryInterceptor(interceptor))
 		if err != nil {
 			return nil, err
 		}
+		p4rt = c
 		p4rts[dut] = p4rt
 	}
 	return p4rt, nil
 }
+
+// evictP4RT removes the P4RT client for the DUT from the cache, if cached.
+func evictP4RT(dut *reservation.DUT, c p4pb.P4RuntimeClient) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p4rts[dut] == c {
+		delete(p4rts, dut)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openconfig/ondatra/internal/usererr"
+)
+
+// InfraFailPrefix prefixes the messages of tests that fail with an
+// infrastructure failure.
+const InfraFailPrefix = "INFRASTRUCTURE FAILURE: "
+
+// Fatalf fails the test with the error returned by a failed Ondatra call.
+// The error is classified and recorded with Error, and the failure message is
+// marked with InfraFailPrefix if it is an infrastructure failure.
+func Fatalf(t testing.TB, err error, format string, args ...interface{}) {
+	t.Helper()
+	err = Error(t.Name(), err)
+	msg := fmt.Sprintf(format, args...)
+	if !usererr.In(err) {
+		msg = InfraFailPrefix + msg
+	}
+	t.Fatalf("%s: %v", msg, err)
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitXML renders the report as JUnit XML. Test failures are reported as
+// JUnit failures and infrastructure failures as JUnit errors, so tools that
+// understand JUnit can tell them apart.
+func junitXML(r *Report) ([]byte, error) {
+	suite := junitSuite{
+		Name:      "ondatra",
+		Time:      seconds(r.Duration),
+		Timestamp: r.Start.UTC().Format(time.RFC3339),
+		SystemOut: actionLines(r.Actions),
+	}
+	if r.ReservationID != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "reservation_id", Value: r.ReservationID})
+	}
+	for _, d := range r.Devices {
+		prefix := fmt.Sprintf("%s.%s.", strings.ToLower(d.Type), d.ID)
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: prefix + "name", Value: d.Name},
+			junitProperty{Name: prefix + "vendor", Value: d.Vendor},
+			junitProperty{Name: prefix + "hardware_model", Value: d.HardwareModel},
+			junitProperty{Name: prefix + "software_version", Value: d.SoftwareVersion},
+		)
+	}
+	for _, t := range r.Tests {
+		c := junitCase{
+			Name:      t.Name,
+			ClassName: "ondatra",
+			Time:      seconds(t.Duration),
+			SystemOut: actionLines(t.Actions),
+		}
+		suite.Tests++
+		switch t.Outcome {
+		case Skipped:
+			suite.Skipped++
+			c.Skipped = &struct{}{}
+		case Failed:
+			f := &junitFailure{Type: string(t.Failure.Kind), Message: "test failed"}
+			if len(t.Failure.Messages) > 0 {
+				f.Message = t.Failure.Messages[0]
+				f.Text = strings.Join(t.Failure.Messages, "\n")
+			}
+			if t.Failure.Kind == InfraFailure {
+				suite.Errors++
+				c.Error = f
+			} else {
+				suite.Failures++
+				c.Failure = f
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal JUnit report")
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report records the results of an Ondatra test run and writes them as
+// JUnit XML and JSON.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+)
+
+// Outcome is the outcome of a test.
+type Outcome string
+
+const (
+	// Passed means the test passed.
+	Passed Outcome = "PASSED"
+	// Failed means the test failed.
+	Failed Outcome = "FAILED"
+	// Skipped means the test was skipped.
+	Skipped Outcome = "SKIPPED"
+)
+
+// FailureKind classifies the cause of a test failure.
+type FailureKind string
+
+const (
+	// TestFailure is a failure of the test or the device under test.
+	TestFailure FailureKind = "TEST"
+	// InfraFailure is a failure of the testbed infrastructure or the binding.
+	InfraFailure FailureKind = "INFRA"
+)
+
+// Report is the result of an Ondatra test run.
+type Report struct {
+	ReservationID string        `json:"reservation_id,omitempty"`
+	Start         time.Time     `json:"start"`
+	Duration      time.Duration `json:"duration_ns"`
+	Devices       []*Device     `json:"devices,omitempty"`
+	// Actions are the actions performed outside of any test.
+	Actions []*Action `json:"actions,omitempty"`
+	Tests   []*Test   `json:"tests,omitempty"`
+}
+
+// Device describes a reserved DUT or ATE.
+type Device struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	Vendor          string `json:"vendor"`
+	HardwareModel   string `json:"hardware_model"`
+	SoftwareVersion string `json:"software_version"`
+}
+
+// Test is the result of a single test function.
+type Test struct {
+	Name     string        `json:"name"`
+	Outcome  Outcome       `json:"outcome"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration_ns"`
+	Failure  *Failure      `json:"failure,omitempty"`
+	Actions  []*Action     `json:"actions,omitempty"`
+}
+
+// Failure describes why a test failed.
+type Failure struct {
+	Kind     FailureKind `json:"kind"`
+	Messages []string    `json:"messages,omitempty"`
+}
+
+// Action is an Ondatra action performed on a device.
+type Action struct {
+	Time time.Time `json:"time"`
+	// Test is the full name of the test or subtest that performed the action.
+	Test    string `json:"test,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	mu    sync.Mutex
+	rep   = &Report{Start: time.Now()}
+	tests = make(map[string]*Test)
+	errs  = make(map[string][]error)
+	nowFn = time.Now
+)
+
+// Reset discards everything recorded so far and restarts the run clock.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	rep = &Report{Start: nowFn()}
+	tests = make(map[string]*Test)
+	errs = make(map[string][]error)
+}
+
+// SetReservation records the reservation ID and the reserved devices.
+func SetReservation(res *reservation.Reservation) {
+	var devs []*Device
+	addDev := func(id, typ string, dims *reservation.Dims) {
+		devs = append(devs, &Device{
+			ID:              id,
+			Type:            typ,
+			Name:            dims.Name,
+			Vendor:          dims.Vendor.String(),
+			HardwareModel:   dims.HardwareModel,
+			SoftwareVersion: dims.SoftwareVersion,
+		})
+	}
+	for id, d := range res.DUTs {
+		addDev(id, "DUT", d.Dims)
+	}
+	for id, a := range res.ATEs {
+		addDev(id, "ATE", a.Dims)
+	}
+	sort.Slice(devs, func(i, j int) bool {
+		if devs[i].Type != devs[j].Type {
+			return devs[i].Type > devs[j].Type
+		}
+		return devs[i].ID < devs[j].ID
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	rep.ReservationID = res.ID
+	rep.Devices = devs
+}
+
+// TestStarted records the start of the top-level test with the given name.
+func TestStarted(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	t := &Test{Name: name, Start: nowFn()}
+	tests[name] = t
+	rep.Tests = append(rep.Tests, t)
+}
+
+// TestFinished records the outcome of the top-level test with the given name.
+// A failed test is classified as an infrastructure failure if any error
+// recorded for it or its subtests is an infrastructure failure.
+func TestFinished(name string, outcome Outcome) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := tests[name]
+	if !ok {
+		return
+	}
+	t.Outcome = outcome
+	t.Duration = nowFn().Sub(t.Start)
+	if outcome != Failed {
+		return
+	}
+	t.Failure = &Failure{Kind: TestFailure}
+	for _, err := range errs[name] {
+		if !usererr.In(err) {
+			t.Failure.Kind = InfraFailure
+		}
+		t.Failure.Messages = append(t.Failure.Messages, err.Error())
+	}
+}
+
+// Error records an error that caused the test with the given name to fail,
+// and returns the error to report to the test. Errors wrapped as user errors
+// are test failures; all others are infrastructure failures, which are first
+// passed to the HandleInfraFail method of the binding to decorate.
+func Error(test string, err error) error {
+	if !usererr.In(err) {
+		if herr := binding.Get().HandleInfraFail(err); herr != nil {
+			err = herr
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	top := topLevel(test)
+	errs[top] = append(errs[top], err)
+	return err
+}
+
+// RecordAction records an action performed on a device by the test with the
+// given name. An empty test name denotes an action outside of any test.
+func RecordAction(test, device, msg string) {
+	mu.Lock()
+	defer mu.Unlock()
+	a := &Action{Time: nowFn(), Test: test, Device: device, Message: msg}
+	if t, ok := tests[topLevel(test)]; ok {
+		t.Actions = append(t.Actions, a)
+		return
+	}
+	rep.Actions = append(rep.Actions, a)
+}
+
+// topLevel returns the name of the top-level test of a test or subtest.
+func topLevel(test string) string {
+	if i := strings.Index(test, "/"); i >= 0 {
+		return test[:i]
+	}
+	return test
+}
+
+// Get returns a deep copy of the report recorded so far, which is safe to use
+// while tests are still recorded.
+func Get() *Report {
+	mu.Lock()
+	defer mu.Unlock()
+	r := *rep
+	r.Duration = nowFn().Sub(r.Start)
+	r.Devices = nil
+	for _, d := range rep.Devices {
+		dc := *d
+		r.Devices = append(r.Devices, &dc)
+	}
+	r.Actions = copyActions(rep.Actions)
+	r.Tests = nil
+	for _, t := range rep.Tests {
+		tc := *t
+		if t.Failure != nil {
+			fc := *t.Failure
+			fc.Messages = append([]string(nil), t.Failure.Messages...)
+			tc.Failure = &fc
+		}
+		tc.Actions = copyActions(t.Actions)
+		r.Tests = append(r.Tests, &tc)
+	}
+	return &r
+}
+
+func copyActions(actions []*Action) []*Action {
+	var c []*Action
+	for _, a := range actions {
+		ac := *a
+		c = append(c, &ac)
+	}
+	return c
+}
+
+// WriteJSON writes the report as JSON to the specified file.
+func WriteJSON(path string) error {
+	data, err := json.MarshalIndent(Get(), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal JSON report")
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write JSON report to %s", path)
+	}
+	return nil
+}
+
+// WriteJUnit writes the report as JUnit XML to the specified file.
+func WriteJUnit(path string) error {
+	data, err := junitXML(Get())
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write JUnit report to %s", path)
+	}
+	return nil
+}
+
+func actionLines(as []*Action) string {
+	var sb strings.Builder
+	for _, a := range as {
+		fmt.Fprintf(&sb, "%s %s", a.Time.Format(time.RFC3339Nano), a.Message)
+		if a.Test != "" {
+			fmt.Fprintf(&sb, " [%s]", a.Test)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,202 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+func init() {
+	binding.Init(&fakebind.Binding{})
+}
+
+func fakeClock() func() {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	nowFn = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return func() { nowFn = time.Now }
+}
+
+func recordRun() {
+	Reset()
+	SetReservation(&reservation.Reservation{
+		ID: "res1",
+		DUTs: map[string]*reservation.DUT{
+			"dut": &reservation.DUT{&reservation.Dims{
+				Name:            "dut1",
+				Vendor:          opb.Device_ARISTA,
+				HardwareModel:   "model",
+				SoftwareVersion: "version",
+			}},
+		},
+		ATEs: map[string]*reservation.ATE{
+			"ate": &reservation.ATE{&reservation.Dims{Name: "ate1", Vendor: opb.Device_IXIA}},
+		},
+	})
+	RecordAction("", "", "Reserving the testbed")
+
+	TestStarted("TestPass")
+	RecordAction("TestPass/sub", "dut1", "Pushing config to dut1")
+	TestFinished("TestPass", Passed)
+
+	TestStarted("TestSkip")
+	TestFinished("TestSkip", Skipped)
+
+	TestStarted("TestFail")
+	Error("TestFail", usererr.New("bad flow"))
+	TestFinished("TestFail", Failed)
+
+	TestStarted("TestInfra")
+	Error("TestInfra/sub", usererr.New("bad flow"))
+	Error("TestInfra", errors.New("lab is down"))
+	TestFinished("TestInfra", Failed)
+
+	TestStarted("TestUnclassified")
+	TestFinished("TestUnclassified", Failed)
+}
+
+func TestRecord(t *testing.T) {
+	defer fakeClock()()
+	recordRun()
+	r := Get()
+	if got, want := r.ReservationID, "res1"; got != want {
+		t.Errorf("ReservationID got %q, want %q", got, want)
+	}
+	wantDevs := []*Device{
+		{ID: "dut", Type: "DUT", Name: "dut1", Vendor: "ARISTA", HardwareModel: "model", SoftwareVersion: "version"},
+		{ID: "ate", Type: "ATE", Name: "ate1", Vendor: "IXIA"},
+	}
+	if diff := cmp.Diff(wantDevs, r.Devices); diff != "" {
+		t.Errorf("Devices got diff (-want +got):\n%s", diff)
+	}
+	if got := len(r.Actions); got != 1 {
+		t.Errorf("got %d run actions, want 1", got)
+	}
+
+	type result struct {
+		Outcome Outcome
+		Failure *Failure
+		Actions int
+	}
+	got := make(map[string]result)
+	for _, test := range r.Tests {
+		got[test.Name] = result{test.Outcome, test.Failure, len(test.Actions)}
+	}
+	want := map[string]result{
+		"TestPass":         {Outcome: Passed, Actions: 1},
+		"TestSkip":         {Outcome: Skipped},
+		"TestFail":         {Outcome: Failed, Failure: &Failure{Kind: TestFailure, Messages: []string{"bad flow"}}},
+		"TestInfra":        {Outcome: Failed, Failure: &Failure{Kind: InfraFailure, Messages: []string{"bad flow", "lab is down"}}},
+		"TestUnclassified": {Outcome: Failed, Failure: &Failure{Kind: TestFailure}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Tests got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetCopies(t *testing.T) {
+	defer fakeClock()()
+	Reset()
+	TestStarted("TestRunning")
+	r := Get()
+	RecordAction("TestRunning", "dut1", "Pushing config to dut1")
+	TestFinished("TestRunning", Passed)
+	if got := r.Tests[0]; got.Outcome != "" || len(got.Actions) != 0 {
+		t.Errorf("Get() returned a test changed by later recording: %+v", got)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	defer fakeClock()()
+	recordRun()
+	path := filepath.Join(t.TempDir(), "report.xml")
+	if err := WriteJUnit(path); err != nil {
+		t.Fatalf("WriteJUnit() failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got junitSuites
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("WriteJUnit() wrote invalid XML: %v", err)
+	}
+	if len(got.Suites) != 1 {
+		t.Fatalf("WriteJUnit() wrote %d suites, want 1", len(got.Suites))
+	}
+	s := got.Suites[0]
+	if s.Tests != 5 || s.Failures != 2 || s.Errors != 1 || s.Skipped != 1 {
+		t.Errorf("WriteJUnit() got tests=%d failures=%d errors=%d skipped=%d, want 5, 2, 1, 1",
+			s.Tests, s.Failures, s.Errors, s.Skipped)
+	}
+	props := make(map[string]string)
+	for _, p := range s.Properties {
+		props[p.Name] = p.Value
+	}
+	if got, want := props["reservation_id"], "res1"; got != want {
+		t.Errorf("WriteJUnit() got reservation_id property %q, want %q", got, want)
+	}
+	if got, want := props["dut.dut.vendor"], "ARISTA"; got != want {
+		t.Errorf("WriteJUnit() got dut.dut.vendor property %q, want %q", got, want)
+	}
+	for _, c := range s.Cases {
+		if c.Name == "TestInfra" {
+			if c.Error == nil || c.Error.Type != "INFRA" {
+				t.Errorf("WriteJUnit() got TestInfra error %+v, want INFRA error", c.Error)
+			}
+		}
+		if c.Name == "TestPass" && !strings.Contains(c.SystemOut, "Pushing config to dut1 [TestPass/sub]") {
+			t.Errorf("WriteJUnit() got TestPass system-out %q, want the pushed config action", c.SystemOut)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	defer fakeClock()()
+	recordRun()
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteJSON(path); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(Report)
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	if diff := cmp.Diff(Get(), got, cmpopts.IgnoreFields(Report{}, "Duration")); diff != "" {
+		t.Errorf("WriteJSON() round trip got diff (-want +got):\n%s", diff)
+	}
+}
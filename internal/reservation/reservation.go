@@ -44,7 +44,29 @@ type Dims struct {
 	Vendor          opb.Device_Vendor
 	HardwareModel   string
 	SoftwareVersion string
+	// ExtraDimensions are the additional dimensions of the device, which are
+	// matched against the extra_dimensions of the testbed device.
+	ExtraDimensions map[string]string
 	Ports           map[string]*Port
+	// Metadata is the binding-specific metadata of the device.
+	Metadata Metadata
+}
+
+// Metadata is information about a reserved device that the binding knows,
+// but that is not matched against the testbed criteria. Any of the fields may
+// be empty if the binding does not know them.
+type Metadata struct {
+	// ManagementAddress is the host or IP address of the management interface.
+	ManagementAddress string
+	// CredentialsHandle is an opaque reference to the credentials of the
+	// device, e.g. the key of a secret store entry; it is never the secret.
+	CredentialsHandle string
+	SerialNumber      string
+	ChassisType       string
+	// LineCards are the names of the line cards installed in the device.
+	LineCards []string
+	// Labels are the key-value labels of the device.
+	Labels map[string]string
 }
 
 func (d *Dims) String() string {
@@ -82,6 +104,16 @@ func (a *ATE) String() string {
 // Port is a reserved Port.
 type Port struct {
 	Name string
+	// Speed is the speed of the port, or S_UNKNOWN if it is not known.
+	Speed opb.Port_Speed
+	// PMD is the physical medium dependent (transceiver) type of the port,
+	// or empty if it is not known.
+	PMD string
+	// Breakout is the name of the physical port that the port is a breakout
+	// channel of, or empty if the port is not a breakout channel.
+	Breakout string
+	// LAGMember is whether the port is wired as a LAG member.
+	LAGMember bool
 }
 
 func (p *Port) String() string {
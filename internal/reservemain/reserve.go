@@ -0,0 +1,126 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reservemain
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"flag"
+	"github.com/openconfig/ondatra/internal/closer"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/keepalive"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/testbed"
+)
+
+// Main is the logic of a standalone reservation command. It reserves the
+// testbed specified by the -testbed flag with the binding, writes the reserved
+// devices and ports to standard output, and holds the reservation until the
+// -run_time elapses or the command is interrupted, then releases it. While it
+// holds the reservation, it heartbeats and extends it like a test run does.
+func Main(b binding.Binding) error {
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	binding.Init(b)
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	return hold(context.Background(), os.Stdout, sigc)
+}
+
+func hold(ctx context.Context, w io.Writer, sigc <-chan os.Signal) (rerr error) {
+	fmt.Fprintln(w, "Reserving the testbed")
+	if err := testbed.Reserve(ctx, *TestbedPath, *RunTime, *WaitTime); err != nil {
+		return err
+	}
+	defer closer.Close(&rerr, func() error {
+		fmt.Fprintln(w, "Releasing the testbed")
+		return testbed.Release(ctx)
+	}, "error releasing testbed")
+	res, err := testbed.Reservation()
+	if err != nil {
+		return err
+	}
+	writeReservation(w, res)
+	if _, ok := binding.Get().(binding.Fetcher); ok {
+		fmt.Fprintf(w, "Attach tests to the reservation with -reservation_id=%s\n", res.ID)
+	}
+	var timeout <-chan time.Time
+	if *RunTime > 0 {
+		fmt.Fprintf(w, "Holding the testbed for %v; interrupt to release it early\n", *RunTime)
+		timeout = time.After(*RunTime)
+	} else {
+		fmt.Fprintln(w, "Holding the testbed until interrupted")
+	}
+	warnf := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\n", args...)
+	}
+	k := keepalive.New(binding.Get(), *RunTime, KeepaliveConfig(), warnf)
+	k.Start(ctx)
+	var msg string
+	select {
+	case s := <-sigc:
+		msg = fmt.Sprintf("Caught %v", s)
+	case <-timeout:
+		msg = "Run time elapsed"
+	}
+	// Stop the keeper before writing again, as it may write warnings to w.
+	k.Stop()
+	fmt.Fprintln(w, msg)
+	return nil
+}
+
+// writeReservation writes the reserved devices and ports, sorted by ID.
+func writeReservation(w io.Writer, res *reservation.Reservation) {
+	fmt.Fprintf(w, "Reservation ID: %s\n", res.ID)
+	var dutIDs, ateIDs []string
+	for id := range res.DUTs {
+		dutIDs = append(dutIDs, id)
+	}
+	for id := range res.ATEs {
+		ateIDs = append(ateIDs, id)
+	}
+	sort.Strings(dutIDs)
+	sort.Strings(ateIDs)
+	for _, id := range dutIDs {
+		writeDevice(w, "DUT", id, res.DUTs[id].Dims)
+	}
+	for _, id := range ateIDs {
+		writeDevice(w, "ATE", id, res.ATEs[id].Dims)
+	}
+}
+
+func writeDevice(w io.Writer, kind, id string, dims *reservation.Dims) {
+	fmt.Fprintf(w, "%s %s: %s (%v %s %s)\n", kind, id, dims.Name, dims.Vendor, dims.HardwareModel, dims.SoftwareVersion)
+	if addr := dims.Metadata.ManagementAddress; addr != "" {
+		fmt.Fprintf(w, "  management address: %s\n", addr)
+	}
+	var pids []string
+	for pid := range dims.Ports {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	for _, pid := range pids {
+		fmt.Fprintf(w, "  %s: %s\n", pid, dims.Ports[pid].Name)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reservemain
+
+import (
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/testbed"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// heartbeatBinding is a fake binding that reports its heartbeats.
+type heartbeatBinding struct {
+	*fakebind.Binding
+	beats chan struct{}
+}
+
+func (b *heartbeatBinding) Heartbeat(context.Context) (time.Time, error) {
+	select {
+	case b.beats <- struct{}{}:
+	default:
+	}
+	return time.Time{}, nil
+}
+
+func TestHold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testbed.textproto")
+	tb := `duts{id:"dut" ports{id:"port1"}} ates{id:"ate" ports{id:"port1"}} links{a:"dut:port1" b:"ate:port1"}`
+	if err := ioutil.WriteFile(path, []byte(tb), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := &heartbeatBinding{beats: make(chan struct{}, 1)}
+	b.Binding = &fakebind.Binding{Reservation: &reservation.Reservation{
+		ID: "res1",
+		DUTs: map[string]*reservation.DUT{"dut": &reservation.DUT{&reservation.Dims{
+			Name:            "dut1",
+			Vendor:          opb.Device_ARISTA,
+			HardwareModel:   "model",
+			SoftwareVersion: "version",
+			Ports:           map[string]*reservation.Port{"port1": {Name: "Ethernet1"}},
+		}}},
+		ATEs: map[string]*reservation.ATE{"ate": &reservation.ATE{&reservation.Dims{
+			Name:            "ate1",
+			Vendor:          opb.Device_IXIA,
+			HardwareModel:   "model",
+			SoftwareVersion: "version",
+			Ports:           map[string]*reservation.Port{"port1": {Name: "1/1"}},
+		}}},
+	}}
+	binding.Init(b)
+	*TestbedPath = path
+	defer func() { *TestbedPath = "" }()
+
+	t.Run("interrupted", func(t *testing.T) {
+		var sb strings.Builder
+		sigc := make(chan os.Signal, 1)
+		sigc <- syscall.SIGINT
+		if err := hold(context.Background(), &sb, sigc); err != nil {
+			t.Fatalf("hold() got error: %v", err)
+		}
+		for _, want := range []string{
+			"Reservation ID: res1\n",
+			"DUT dut: dut1 (ARISTA model version)\n  port1: Ethernet1\n",
+			"ATE ate: ate1 (IXIA model version)\n  port1: 1/1\n",
+			"Attach tests to the reservation with -reservation_id=res1\n",
+			"Holding the testbed until interrupted\n",
+			"Releasing the testbed\n",
+		} {
+			if !strings.Contains(sb.String(), want) {
+				t.Errorf("hold() wrote %q, want it to contain %q", sb.String(), want)
+			}
+		}
+		if _, err := testbed.Reservation(); err == nil {
+			t.Errorf("hold() did not release the testbed")
+		}
+	})
+
+	t.Run("run time elapsed", func(t *testing.T) {
+		*RunTime = time.Millisecond
+		defer func() { *RunTime = 0 }()
+		var sb strings.Builder
+		if err := hold(context.Background(), &sb, nil); err != nil {
+			t.Fatalf("hold() got error: %v", err)
+		}
+		if want := "Run time elapsed\n"; !strings.Contains(sb.String(), want) {
+			t.Errorf("hold() wrote %q, want it to contain %q", sb.String(), want)
+		}
+	})
+
+	t.Run("heartbeats", func(t *testing.T) {
+		*HeartbeatInterval = time.Millisecond
+		defer func() { *HeartbeatInterval = time.Minute }()
+		sigc := make(chan os.Signal)
+		go func() {
+			<-b.beats
+			sigc <- syscall.SIGINT
+		}()
+		var sb strings.Builder
+		if err := hold(context.Background(), &sb, sigc); err != nil {
+			t.Fatalf("hold() got error: %v", err)
+		}
+		if want := "Caught interrupt\n"; !strings.Contains(sb.String(), want) {
+			t.Errorf("hold() wrote %q, want it to contain %q", sb.String(), want)
+		}
+	})
+}
@@ -17,16 +17,70 @@ package reservemain
 
 import (
 	"flag"
+	"time"
 
+	"github.com/openconfig/ondatra/internal/keepalive"
 )
 
 var (
 	// TestbedPath is a flag for the testbed path.
 	TestbedPath = flag.String("testbed", "", "Path to the Ondatra testbed file")
+	// ReservationID is a flag for the ID of an existing reservation to attach to.
+	ReservationID = flag.String("reservation_id", "", "ID of an existing reservation of the testbed to attach to, "+
+		"instead of reserving the testbed. The reservation is not released when the test exits. "+
+		"The binding must support attaching to reservations.")
 	// RunTime is a flag for the run time duration of the reservation.
 	RunTime = flag.Duration("run_time", 0, "Timeout of the test run, excluding the wait time for the testbed to be ready. "+
 		"A zero value means here is no time limit. Must be a non-negative value.")
 	// WaitTime is a flag for the wait time duration of the reservation.
 	WaitTime = flag.Duration("wait_time", 0, "Maximum amount of time the test should wait until the testbed is ready. "+
 		"A zero value lets the binding implementation choose an appropriate wait time. Must be a non-negative value.")
+	// HeartbeatInterval is a flag for the interval between reservation heartbeats.
+	HeartbeatInterval = flag.Duration("heartbeat_interval", time.Minute, "Interval between heartbeats of the reservation, "+
+		"which also check how long until the reservation expires.")
+	// ExpiryWarning is a flag for how long before the reservation expires to warn about it.
+	ExpiryWarning = flag.Duration("expiry_warning", 10*time.Minute, "How long before the reservation expires to start "+
+		"warning about it and, if allowed, extending it.")
+	// RunTimeExtension is a flag for how much to extend the reservation by at a time.
+	RunTimeExtension = flag.Duration("run_time_extension", 30*time.Minute, "How much to extend the reservation by "+
+		"each time it nears its expiry, if the binding supports it and -max_run_time_extension allows it.")
+	// MaxRunTimeExtension is a flag for the cap on the total extension of the reservation.
+	MaxRunTimeExtension = flag.Duration("max_run_time_extension", 0, "Maximum total time to extend the reservation by. "+
+		"A zero value means the reservation is never extended.")
+	// RestoreConfig is a flag for whether to restore the baseline config of the DUTs after every test.
+	RestoreConfig = flag.Bool("restore_config", false, "Whether to snapshot the config of every DUT when the testbed is reserved "+
+		"and restore it after every test.")
+	// JUnitReport is a flag for the path of the JUnit XML report.
+	JUnitReport = flag.String("junit_report", "", "Path to write a JUnit XML report of the test results to. "+
+		"If empty, no JUnit XML report is written.")
+	// JSONReport is a flag for the path of the JSON report.
+	JSONReport = flag.String("json_report", "", "Path to write a JSON report of the test results to. "+
+		"If empty, no JSON report is written.")
+	// SignalGracePeriod is a flag for how long to wait for the current test to finish on a signal.
+	SignalGracePeriod = flag.Duration("signal_grace_period", time.Minute, "How long to wait, after an interrupt or "+
+		"termination signal, for the current test to finish before collecting diagnostics and releasing the testbed. "+
+		"The remaining tests are skipped. A second signal stops the wait.")
+	// DiagnosticsDir is a flag for the directory of the diagnostics collected on a signal.
+	DiagnosticsDir = flag.String("diagnostics_dir", "", "Directory to collect diagnostics of the DUTs into when the "+
+		"tests are interrupted by a signal. If empty, a new temporary directory is used.")
+	// RetryAttempts is a flag for the maximum attempts of a call that fails with a transient error.
+	RetryAttempts = flag.Int("retry_attempts", 4, "Maximum number of attempts, including the first, of an idempotent "+
+		"call on an Ondatra-managed client that fails with a transient error, e.g. after a DUT reboot. "+
+		"A value of 1 disables retries.")
+	// RetryBackoff is a flag for the wait before the first retry.
+	RetryBackoff = flag.Duration("retry_backoff", time.Second, "Wait before the first retry of a call that failed "+
+		"with a transient error. The wait doubles after every retry, up to -retry_max_backoff.")
+	// RetryMaxBackoff is a flag for the maximum wait between retries.
+	RetryMaxBackoff = flag.Duration("retry_max_backoff", 30*time.Second, "Maximum wait between retries of a call "+
+		"that failed with a transient error.")
 )
+
+// KeepaliveConfig returns the keepalive config set by flags.
+func KeepaliveConfig() keepalive.Config {
+	return keepalive.Config{
+		Interval:     *HeartbeatInterval,
+		Warning:      *ExpiryWarning,
+		ExtendBy:     *RunTimeExtension,
+		MaxExtension: *MaxRunTimeExtension,
+	}
+}
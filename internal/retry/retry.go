@@ -0,0 +1,142 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry retries calls on Ondatra-managed clients that fail with
+// transient errors, such as a connection dropped by a DUT reboot.
+package retry
+
+import (
+	"golang.org/x/net/context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc"
+	"github.com/openconfig/ondatra/internal/reservemain"
+)
+
+// sleepFn is stubbed out in tests.
+var sleepFn = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Policy configures how calls are retried.
+type Policy struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+	// InitialBackoff is the wait before the first retry; it doubles after
+	// every retry, up to MaxBackoff.
+	InitialBackoff, MaxBackoff time.Duration
+}
+
+// FlagPolicy returns the retry policy set by flags.
+func FlagPolicy() Policy {
+	return Policy{
+		Attempts:       *reservemain.RetryAttempts,
+		InitialBackoff: *reservemain.RetryBackoff,
+		MaxBackoff:     *reservemain.RetryMaxBackoff,
+	}
+}
+
+// IsTransient returns whether the error is a transient connection failure,
+// i.e. a gRPC Unavailable error or a reset connection.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	for e := err; e != nil; e = unwrap(e) {
+		if s, ok := status.FromError(e); ok && s.Code() == codes.Unavailable {
+			return true
+		}
+		if e == syscall.ECONNRESET {
+			return true
+		}
+	}
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// unwrap can unwrap errors produced by errors.Wrap or by the %w verb.
+func unwrap(err error) error {
+	if c, ok := err.(interface {
+		Cause() error
+	}); ok {
+		return c.Cause()
+	}
+	return errors.Unwrap(err)
+}
+
+// Do calls fn, retrying it with exponential backoff according to the flag
+// policy for as long as it fails with a transient error. Before every retry,
+// evict is called so that fn can re-dial a fresh client; evict may be nil.
+// The fn must be safe to call more than once.
+func Do(ctx context.Context, fn func() error, evict func()) error {
+	return FlagPolicy().Do(ctx, fn, evict)
+}
+
+// Do calls fn, retrying it according to the policy; see the Do function.
+func (p Policy) Do(ctx context.Context, fn func() error, evict func()) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !IsTransient(err) || attempt >= p.Attempts {
+			return err
+		}
+		log.Warningf("Retrying after transient error (attempt %d of %d): %v", attempt, p.Attempts, err)
+		if evict != nil {
+			evict()
+		}
+		if serr := sleepFn(ctx, backoff); serr != nil {
+			return err
+		}
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor for a client cached by
+// Ondatra. On a transient error, it calls evict, so that the client is
+// re-dialed the next time it is fetched. If the method is idempotent, as
+// reported by the idempotent func, it also retries the call per the flag
+// policy; it retries on the same connection, which gRPC reconnects.
+func UnaryClientInterceptor(idempotent func(method string) bool, evict func()) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		call := func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if !idempotent(method) {
+			err := call()
+			if IsTransient(err) {
+				evict()
+			}
+			return err
+		}
+		err := Do(ctx, call, nil)
+		if IsTransient(err) {
+			evict()
+		}
+		return err
+	}
+}
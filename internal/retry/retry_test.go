@@ -0,0 +1,190 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{{
+		desc: "nil",
+	}, {
+		desc: "unavailable",
+		err:  status.Error(codes.Unavailable, "dropped"),
+		want: true,
+	}, {
+		desc: "wrapped unavailable",
+		err:  errors.Wrap(fmt.Errorf("set: %w", status.Error(codes.Unavailable, "dropped")), "outer"),
+		want: true,
+	}, {
+		desc: "connection reset",
+		err:  errors.Wrap(syscall.ECONNRESET, "read"),
+		want: true,
+	}, {
+		desc: "connection reset message",
+		err:  errors.New("read tcp: connection reset by peer"),
+		want: true,
+	}, {
+		desc: "other code",
+		err:  status.Error(codes.InvalidArgument, "bad path"),
+	}, {
+		desc: "other error",
+		err:  errors.New("oops"),
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := IsTransient(test.err); got != test.want {
+				t.Errorf("IsTransient(%v) got %t, want %t", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func stubSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleepFn
+	sleepFn = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { sleepFn = orig })
+	return &waits
+}
+
+func TestDo(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "dropped")
+	p := Policy{Attempts: 5, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	tests := []struct {
+		desc      string
+		errs      []error
+		wantCalls int
+		wantWaits []time.Duration
+		wantErr   bool
+	}{{
+		desc:      "success",
+		errs:      []error{nil},
+		wantCalls: 1,
+	}, {
+		desc:      "permanent error",
+		errs:      []error{errors.New("oops")},
+		wantCalls: 1,
+		wantErr:   true,
+	}, {
+		desc:      "transient then success",
+		errs:      []error{unavailable, unavailable, nil},
+		wantCalls: 3,
+		wantWaits: []time.Duration{time.Second, 2 * time.Second},
+	}, {
+		desc:      "attempts exhausted",
+		errs:      []error{unavailable, unavailable, unavailable, unavailable, unavailable, nil},
+		wantCalls: 5,
+		wantWaits: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		wantErr:   true,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			waits := stubSleep(t)
+			var calls, evicts int
+			err := p.Do(context.Background(), func() error {
+				err := test.errs[calls]
+				calls++
+				return err
+			}, func() { evicts++ })
+			if (err != nil) != test.wantErr {
+				t.Errorf("Do() got error %v, want error? %t", err, test.wantErr)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("Do() made %d calls, want %d", calls, test.wantCalls)
+			}
+			if evicts != test.wantCalls-1 {
+				t.Errorf("Do() evicted %d times, want %d", evicts, test.wantCalls-1)
+			}
+			if diff := cmp.Diff(test.wantWaits, *waits); diff != "" {
+				t.Errorf("Do() waited with diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int
+	p := Policy{Attempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	err := p.Do(ctx, func() error {
+		calls++
+		return status.Error(codes.Unavailable, "dropped")
+	}, nil)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Do() got error %v, want the Unavailable error", err)
+	}
+	if calls != 1 {
+		t.Errorf("Do() made %d calls, want 1", calls)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	stubSleep(t)
+	tests := []struct {
+		desc, method string
+		wantCalls    int
+	}{{
+		desc:      "idempotent",
+		method:    "/svc/Get",
+		wantCalls: 4,
+	}, {
+		desc:      "not idempotent",
+		method:    "/svc/Write",
+		wantCalls: 1,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var calls, evicts int
+			interceptor := UnaryClientInterceptor(
+				func(method string) bool { return method == "/svc/Get" },
+				func() { evicts++ })
+			invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				calls++
+				return status.Error(codes.Unavailable, "dropped")
+			}
+			err := interceptor(context.Background(), test.method, nil, nil, nil, invoker)
+			if status.Code(err) != codes.Unavailable {
+				t.Errorf("interceptor got error %v, want the Unavailable error", err)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("interceptor made %d calls, want %d", calls, test.wantCalls)
+			}
+			if evicts != 1 {
+				t.Errorf("interceptor evicted %d times, want 1", evicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// includeKey is the top-level key of a YAML or JSON testbed that lists the
+// testbeds it includes.
+const includeKey = "include"
+
+// includeDirective is the prefix of a comment line in a text proto testbed
+// that includes another testbed.
+const includeDirective = "# include:"
+
+// LoadTestbed reads the testbed at the specified path.
+//
+// The file is parsed according to its extension: ".yaml" and ".yml" files as
+// YAML, ".json" files as JSON, and all others as text proto. YAML and JSON
+// testbeds use the JSON mapping of the Testbed proto.
+//
+// A testbed may include other testbeds, either with a top-level "include" key,
+// whose value is a path or a list of paths, in YAML and JSON, or with
+// "# include: <path>" comment lines in text proto. Relative paths are relative
+// to the directory of the including file. The included testbeds are merged in
+// order, then the including testbed is merged on top: a device with the ID of
+// an included device overlays it, with set fields replacing the included
+// values, ports merged by ID and extra dimensions merged by key; other devices
+// and all links are added.
+func LoadTestbed(path string) (*opb.Testbed, error) {
+	return load(path, nil)
+}
+
+func load(path string, stack []string) (*opb.Testbed, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve testbed path %s", path)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, usererr.New("testbed include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+	s, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read testbed proto %s", path)
+	}
+	tb, includes, err := parse(path, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(includes) == 0 {
+		return tb, nil
+	}
+	merged := &opb.Testbed{}
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		base, err := load(inc, stack)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to include testbed in %s", path)
+		}
+		overlay(merged, base)
+	}
+	overlay(merged, tb)
+	return merged, nil
+}
+
+func parse(path string, s []byte) (*opb.Testbed, []string, error) {
+	tb := &opb.Testbed{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(s, &v); err != nil {
+			return nil, nil, usererr.Wrapf(err, "failed to parse testbed YAML %s", path)
+		}
+		js, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return nil, nil, usererr.Wrapf(err, "failed to convert testbed YAML %s to JSON", path)
+		}
+		return parseJSON(path, js)
+	case ".json":
+		return parseJSON(path, s)
+	}
+	if err := prototext.Unmarshal(s, tb); err != nil {
+		return nil, nil, usererr.Wrapf(err, "failed to parse testbed proto %s", path)
+	}
+	var includes []string
+	scanner := bufio.NewScanner(bytes.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, includeDirective) {
+			includes = append(includes, strings.TrimSpace(strings.TrimPrefix(line, includeDirective)))
+		}
+	}
+	return tb, includes, nil
+}
+
+func parseJSON(path string, s []byte) (*opb.Testbed, []string, error) {
+	m := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(s, &m); err != nil {
+		return nil, nil, usererr.Wrapf(err, "failed to parse testbed JSON %s", path)
+	}
+	var includes []string
+	if inc, ok := m[includeKey]; ok {
+		if err := json.Unmarshal(inc, &includes); err != nil {
+			var one string
+			if err := json.Unmarshal(inc, &one); err != nil {
+				return nil, nil, usererr.New("%q in testbed %s must be a path or a list of paths", includeKey, path)
+			}
+			includes = []string{one}
+		}
+		delete(m, includeKey)
+	}
+	s, err := json.Marshal(m)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to re-encode testbed JSON %s", path)
+	}
+	tb := &opb.Testbed{}
+	if err := protojson.Unmarshal(s, tb); err != nil {
+		return nil, nil, usererr.Wrapf(err, "failed to parse testbed JSON %s", path)
+	}
+	return tb, includes, nil
+}
+
+// jsonValue converts a value decoded from YAML to one that can be encoded as
+// JSON, whose objects must have string keys.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	}
+	return v
+}
+
+// overlay merges the overlay testbed into the base testbed.
+func overlay(base, over *opb.Testbed) {
+	base.Duts = overlayDevices(base.GetDuts(), over.GetDuts())
+	base.Ates = overlayDevices(base.GetAtes(), over.GetAtes())
+	base.Links = append(base.Links, over.GetLinks()...)
+}
+
+func overlayDevices(base, over []*opb.Device) []*opb.Device {
+	byID := make(map[string]*opb.Device)
+	for _, d := range base {
+		byID[d.GetId()] = d
+	}
+	for _, od := range over {
+		bd, ok := byID[od.GetId()]
+		if !ok {
+			bd = &opb.Device{}
+			byID[od.GetId()] = bd
+			base = append(base, bd)
+		}
+		ports := od.GetPorts()
+		od = proto.Clone(od).(*opb.Device)
+		od.Ports = nil
+		proto.Merge(bd, od)
+		bd.Ports = overlayPorts(bd.GetPorts(), ports)
+	}
+	return base
+}
+
+func overlayPorts(base, over []*opb.Port) []*opb.Port {
+	byID := make(map[string]*opb.Port)
+	for _, p := range base {
+		byID[p.GetId()] = p
+	}
+	for _, op := range over {
+		if bp, ok := byID[op.GetId()]; ok {
+			proto.Merge(bp, op)
+			continue
+		}
+		p := proto.Clone(op).(*opb.Port)
+		byID[p.GetId()] = p
+		base = append(base, p)
+	}
+	return base
+}
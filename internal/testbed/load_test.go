@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gnmi/errdiff"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+func TestLoadTestbed(t *testing.T) {
+	tests := []struct {
+		desc, file string
+		want       *opb.Testbed
+	}{{
+		desc: "yaml overlay",
+		file: "overlay.yaml",
+		want: &opb.Testbed{
+			Duts: []*opb.Device{{
+				Id:              "dut",
+				Vendor:          opb.Device_ARISTA,
+				HardwareModel:   "base-model",
+				SoftwareVersion: "4.2",
+				Ports:           []*opb.Port{{Id: "port1", Speed: opb.Port_S_100GB}, {Id: "port2"}},
+				ExtraDimensions: map[string]string{"pool": "shared", "label": "foo"},
+			}, {
+				Id:     "dut2",
+				Vendor: opb.Device_CISCO,
+			}},
+			Ates: []*opb.Device{{
+				Id:     "ate",
+				Vendor: opb.Device_IXIA,
+				Ports:  []*opb.Port{{Id: "port1"}, {Id: "port2"}},
+			}},
+			Links: []*opb.Link{{A: "dut:port1", B: "ate:port1"}, {A: "dut:port2", B: "ate:port2"}},
+		},
+	}, {
+		desc: "nested text proto and json overlays",
+		file: "overlay.textproto",
+		want: &opb.Testbed{
+			Duts: []*opb.Device{{
+				Id:              "dut",
+				Vendor:          opb.Device_JUNIPER,
+				HardwareModel:   "base-model",
+				SoftwareVersion: "4.2",
+				Ports:           []*opb.Port{{Id: "port1"}},
+				ExtraDimensions: map[string]string{"pool": "shared"},
+			}},
+			Ates: []*opb.Device{{
+				Id:     "ate",
+				Vendor: opb.Device_IXIA,
+				Ports:  []*opb.Port{{Id: "port1"}},
+			}},
+			Links: []*opb.Link{{A: "dut:port1", B: "ate:port1"}},
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := LoadTestbed(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatalf("LoadTestbed() failed: %v", err)
+			}
+			if diff := cmp.Diff(test.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("LoadTestbed() got diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadTestbedErrors(t *testing.T) {
+	tests := []struct {
+		desc, file, wantErr string
+	}{{
+		desc:    "missing file",
+		file:    "missing.textproto",
+		wantErr: "failed to read",
+	}, {
+		desc:    "include cycle",
+		file:    "cycle_a.yaml",
+		wantErr: "include cycle",
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := LoadTestbed(filepath.Join("testdata", test.file))
+			if diff := errdiff.Substring(err, test.wantErr); diff != "" {
+				t.Errorf("LoadTestbed() %s", diff)
+			}
+		})
+	}
+}
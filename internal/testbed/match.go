@@ -0,0 +1,44 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/openconfig/ondatra/internal/usererr"
+)
+
+// RegexPrefix prefixes testbed criteria that are RE2 regular expressions.
+const RegexPrefix = "regex:"
+
+// Matcher returns a func that reports whether a value matches a testbed
+// criterion, such as a hardware model, software version or extra dimension.
+// An empty criterion matches any value, a criterion prefixed with "regex:"
+// matches the values that are fully matched by the RE2 regex that follows,
+// and any other criterion only matches itself.
+func Matcher(criterion string) (func(string) bool, error) {
+	if !strings.HasPrefix(criterion, RegexPrefix) {
+		return func(v string) bool {
+			return criterion == "" || v == criterion
+		}, nil
+	}
+	expr := strings.TrimPrefix(criterion, RegexPrefix)
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, usererr.Wrapf(err, "invalid regex %q", expr)
+	}
+	return re.MatchString, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"testing"
+
+	"github.com/openconfig/gnmi/errdiff"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		criterion, value string
+		want             bool
+	}{
+		{criterion: "", value: "anything", want: true},
+		{criterion: "7.4.1", value: "7.4.1", want: true},
+		{criterion: "7.4", value: "7.4.1", want: false},
+		{criterion: `regex:7\.4\..*`, value: "7.4.1", want: true},
+		{criterion: `regex:7\.4`, value: "7.4.1", want: false},
+		{criterion: "regex:a|b", value: "b", want: true},
+		{criterion: "regex:a|b", value: "ab", want: false},
+	}
+	for _, test := range tests {
+		match, err := Matcher(test.criterion)
+		if err != nil {
+			t.Fatalf("Matcher(%q) got error: %v", test.criterion, err)
+		}
+		if got := match(test.value); got != test.want {
+			t.Errorf("Matcher(%q)(%q) got %v, want %v", test.criterion, test.value, got, test.want)
+		}
+	}
+}
+
+func TestMatcherError(t *testing.T) {
+	_, err := Matcher("regex:a(b")
+	if diff := errdiff.Substring(err, "invalid regex"); diff != "" {
+		t.Errorf("Matcher() got unexpected error diff: %s", diff)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"fmt"
+	"strings"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// DOT renders the devices, ports and links of the testbed as a Graphviz graph.
+// Every device is a record node with a field per port, and every link is an
+// edge between the port fields, labeled with its link criteria.
+func DOT(tb *opb.Testbed) string {
+	var sb strings.Builder
+	sb.WriteString("graph testbed {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	writeDOTNodes(&sb, "DUT", "record", tb.GetDuts())
+	writeDOTNodes(&sb, "ATE", "Mrecord", tb.GetAtes())
+	for _, ln := range tb.GetLinks() {
+		fmt.Fprintf(&sb, "  %s -- %s", dotPort(ln.GetA()), dotPort(ln.GetB()))
+		if l := linkLabel(ln); l != "" {
+			fmt.Fprintf(&sb, " [label=%q]", l)
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func writeDOTNodes(sb *strings.Builder, kind, shape string, devs []*opb.Device) {
+	for _, d := range devs {
+		fields := []string{dotEscape(deviceLabel(kind, d, "\n"))}
+		if len(d.GetPorts()) > 0 {
+			var ports []string
+			for _, p := range d.GetPorts() {
+				ports = append(ports, fmt.Sprintf("<%s> %s", p.GetId(), dotEscape(portLabel(p, "\n"))))
+			}
+			fields = append(fields, "{"+strings.Join(ports, "|")+"}")
+		}
+		fmt.Fprintf(sb, "  %q [shape=%s, label=\"{%s}\"];\n", d.GetId(), shape, strings.Join(fields, "|"))
+	}
+}
+
+// dotPort converts a "<device-id>:<port-id>" port ID to a DOT node port.
+func dotPort(pid string) string {
+	dev, port := splitPortID(pid)
+	if port == "" {
+		return fmt.Sprintf("%q", dev)
+	}
+	return fmt.Sprintf("%q:%q", dev, port)
+}
+
+// dotEscape escapes the characters that are special in a DOT record label,
+// including the backslash, and converts newlines to DOT line breaks.
+func dotEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			sb.WriteString(`\n`)
+			continue
+		case strings.ContainsRune(`\{}|<>"`, r):
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Mermaid renders the devices and links of the testbed as a Mermaid flowchart.
+// Every link is an edge labeled with the IDs of the ports it connects and with
+// its link criteria.
+func Mermaid(tb *opb.Testbed) string {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for _, d := range tb.GetDuts() {
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", d.GetId(), mermaidEscape(deviceLabel("DUT", d, "<br/>")))
+	}
+	for _, d := range tb.GetAtes() {
+		fmt.Fprintf(&sb, "  %s([\"%s\"])\n", d.GetId(), mermaidEscape(deviceLabel("ATE", d, "<br/>")))
+	}
+	for _, ln := range tb.GetLinks() {
+		devA, portA := splitPortID(ln.GetA())
+		devB, portB := splitPortID(ln.GetB())
+		label := fmt.Sprintf("%s <-> %s", portA, portB)
+		if l := linkLabel(ln); l != "" {
+			label += "<br/>" + l
+		}
+		fmt.Fprintf(&sb, "  %s ---|\"%s\"| %s\n", devA, mermaidEscape(label), devB)
+	}
+	return sb.String()
+}
+
+// mermaidEscape escapes the characters that would end a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// deviceLabel describes a device by its kind, ID and any criteria that it
+// specifies, one per line, separated by the specified line break.
+func deviceLabel(kind string, d *opb.Device, br string) string {
+	lines := []string{kind + " " + d.GetId()}
+	var criteria []string
+	if v := d.GetVendor(); v != opb.Device_UNKNOWN {
+		criteria = append(criteria, v.String())
+	}
+	for _, c := range []string{d.GetHardwareModel(), d.GetSoftwareVersion()} {
+		if c != "" {
+			criteria = append(criteria, c)
+		}
+	}
+	if len(criteria) > 0 {
+		lines = append(lines, strings.Join(criteria, " "))
+	}
+	return strings.Join(lines, br)
+}
+
+// portLabel describes a port by its ID and any criteria that it specifies.
+func portLabel(p *opb.Port, br string) string {
+	lines := []string{p.GetId()}
+	var criteria []string
+	if s := p.GetSpeed(); s != opb.Port_S_UNKNOWN {
+		criteria = append(criteria, speedName(s))
+	}
+	if p.GetPmd() != "" {
+		criteria = append(criteria, p.GetPmd())
+	}
+	if p.GetGroup() != "" {
+		criteria = append(criteria, "group "+p.GetGroup())
+	}
+	if len(criteria) > 0 {
+		lines = append(lines, strings.Join(criteria, " "))
+	}
+	return strings.Join(lines, br)
+}
+
+// linkLabel describes the criteria of a link, or returns "" if it has none.
+func linkLabel(ln *opb.Link) string {
+	var criteria []string
+	if s := ln.GetMinSpeed(); s != opb.Port_S_UNKNOWN {
+		criteria = append(criteria, ">= "+speedName(s))
+	}
+	if ln.GetLagMember() {
+		criteria = append(criteria, "LAG")
+	}
+	return strings.Join(criteria, ", ")
+}
+
+func speedName(s opb.Port_Speed) string {
+	return strings.TrimPrefix(s.String(), "S_")
+}
+
+// splitPortID splits a "<device-id>:<port-id>" port ID.
+func splitPortID(pid string) (dev, port string) {
+	if i := strings.Index(pid, ":"); i >= 0 {
+		return pid[:i], pid[i+1:]
+	}
+	return pid, ""
+}
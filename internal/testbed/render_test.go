@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+var renderTB = &opb.Testbed{
+	Duts: []*opb.Device{{
+		Id:            "dut",
+		Vendor:        opb.Device_ARISTA,
+		HardwareModel: "regex:7280|7500",
+		Ports:         []*opb.Port{{Id: "port1", Speed: opb.Port_S_100GB}, {Id: "port2"}},
+	}},
+	Ates: []*opb.Device{{
+		Id:    "ate",
+		Ports: []*opb.Port{{Id: "port1"}, {Id: "port2"}},
+	}},
+	Links: []*opb.Link{
+		{A: "dut:port1", B: "ate:port1", MinSpeed: opb.Port_S_100GB, LagMember: true},
+		{A: "dut:port2", B: "ate:port2"},
+	},
+}
+
+func TestDOT(t *testing.T) {
+	want := `graph testbed {
+  rankdir=LR;
+  "dut" [shape=record, label="{DUT dut\nARISTA regex:7280\|7500|{<port1> port1\n100GB|<port2> port2}}"];
+  "ate" [shape=Mrecord, label="{ATE ate|{<port1> port1|<port2> port2}}"];
+  "dut":"port1" -- "ate":"port1" [label=">= 100GB, LAG"];
+  "dut":"port2" -- "ate":"port2";
+}
+`
+	if diff := cmp.Diff(want, DOT(renderTB)); diff != "" {
+		t.Errorf("DOT() got unexpected diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestDOTEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "port1", want: "port1"},
+		{in: "line1\nline2", want: `line1\nline2`},
+		{in: `regex:4\.2|4\.3`, want: `regex:4\\.2\|4\\.3`},
+		{in: `a\"b`, want: `a\\\"b`},
+		{in: "{<x>}", want: `\{\<x\>\}`},
+	}
+	for _, test := range tests {
+		if got := dotEscape(test.in); got != test.want {
+			t.Errorf("dotEscape(%q) got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	want := `graph LR
+  dut["DUT dut<br/>ARISTA regex:7280|7500"]
+  ate(["ATE ate"])
+  dut ---|"port1 <-> port1<br/>>= 100GB, LAG"| ate
+  dut ---|"port2 <-> port2"| ate
+`
+	if diff := cmp.Diff(want, Mermaid(renderTB)); diff != "" {
+		t.Errorf("Mermaid() got unexpected diff (-want,+got):\n%s", diff)
+	}
+}
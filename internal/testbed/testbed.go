@@ -18,13 +18,13 @@ package testbed
 import (
 	"golang.org/x/net/context"
 	"fmt"
-	"io/ioutil"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
-	"google.golang.org/protobuf/encoding/prototext"
 	"github.com/openconfig/ondatra/internal/binding"
 	"github.com/openconfig/ondatra/internal/reservation"
 	"github.com/openconfig/ondatra/internal/usererr"
@@ -35,6 +35,12 @@ import (
 var (
 	resMu sync.RWMutex
 	res   *reservation.Reservation
+	// attached is whether res was fetched rather than reserved, in which case
+	// it is not released.
+	attached bool
+	// peers maps the ID of every linked port of the testbed, in the format
+	// "<device-id>:<port-id>", to the ID of the port it is linked to.
+	peers map[string]string
 )
 
 // Reservation returns the current reservation.
@@ -48,6 +54,7 @@ func Reservation() (*reservation.Reservation, error) {
 }
 
 // Reserve reserves a testbed, typically reading its definition for the test.
+// The testbed file is read with LoadTestbed.
 func Reserve(ctx context.Context, testbedPath string, runTime, waitTime time.Duration) error {
 	if testbedPath == "" {
 		return errors.New("testbed path not specified")
@@ -63,70 +70,172 @@ func Reserve(ctx context.Context, testbedPath string, runTime, waitTime time.Dur
 	if res != nil {
 		return errors.New("testbed is already reserved; RunTests was already called")
 	}
-	tb := &opb.Testbed{}
-	s, err := ioutil.ReadFile(testbedPath)
+	tb, err := loadValid(testbedPath)
 	if err != nil {
-		return errors.Wrapf(err, "failed to read testbed proto %s", testbedPath)
+		return err
 	}
-	err = prototext.Unmarshal(s, tb)
+	r, err := binding.Get().Reserve(ctx, tb, runTime, waitTime)
 	if err != nil {
-		return errors.Wrapf(err, "failed to parse testbed proto %s", testbedPath)
+		return err
 	}
-	if err := validateTB(tb); err != nil {
+	if err := validateRes(tb, r); err != nil {
+		// The reservation is not recorded, so Release would not release it.
+		if rerr := binding.Get().Release(ctx); rerr != nil {
+			return errors.Errorf("%v; also failed to release the reservation: %v", err, rerr)
+		}
 		return err
 	}
-	r, err := binding.Get().Reserve(ctx, tb, runTime, waitTime)
+	res = r
+	peers = linkPeers(tb)
+	return nil
+}
+
+// Fetch attaches to the existing reservation with the specified ID, which
+// must match the testbed at the specified path. The binding must implement
+// binding.Fetcher. An attached reservation is not released by Release, so
+// that it can be attached to again.
+func Fetch(ctx context.Context, testbedPath, id string) error {
+	if testbedPath == "" {
+		return errors.New("testbed path not specified")
+	}
+	resMu.Lock()
+	defer resMu.Unlock()
+	if res != nil {
+		return errors.New("testbed is already reserved; RunTests was already called")
+	}
+	f, ok := binding.Get().(binding.Fetcher)
+	if !ok {
+		return usererr.New("binding does not support attaching to reservation %q", id)
+	}
+	tb, err := loadValid(testbedPath)
 	if err != nil {
 		return err
 	}
+	r, err := f.FetchReservation(ctx, tb, id)
+	if err != nil {
+		return err
+	}
+	if r.ID != id {
+		return errors.Errorf("fetched reservation has ID %q, want %q", r.ID, id)
+	}
 	if err := validateRes(tb, r); err != nil {
 		return err
 	}
 	res = r
+	peers = linkPeers(tb)
+	attached = true
 	return nil
 }
 
+// Peer returns the device and port IDs of the port that the specified port is
+// linked to in the testbed, or false if the port is not linked.
+func Peer(devID, portID string) (string, string, bool) {
+	resMu.RLock()
+	defer resMu.RUnlock()
+	peer, ok := peers[devID+":"+portID]
+	if !ok {
+		return "", "", false
+	}
+	parts := strings.SplitN(peer, ":", 2)
+	return parts[0], parts[1], true
+}
+
+func linkPeers(tb *opb.Testbed) map[string]string {
+	m := make(map[string]string)
+	for _, ln := range tb.GetLinks() {
+		m[ln.GetA()] = ln.GetB()
+		m[ln.GetB()] = ln.GetA()
+	}
+	return m
+}
+
+func loadValid(testbedPath string) (*opb.Testbed, error) {
+	tb, err := LoadTestbed(testbedPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTB(tb); err != nil {
+		return nil, err
+	}
+	return tb, nil
+}
+
 // portMap registers which ports are connected to which other ports, in the format "<device-id>:<port-id>".
 // Non-connected ports map to "", which allows to check for validity of port IDs in links.
 // Each pair of connected ports A and B must be in the map twice: port A's ID mapping to port B's ID and port B's ID mapping to port A's ID.
 type portMap map[string]string
 
 func validateTB(tb *opb.Testbed) error {
+	if errs := Validate(tb); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Validate checks that the testbed is well formed, without reserving it, and
+// returns all the errors it finds.
+func Validate(tb *opb.Testbed) []error {
+	var errs []error
 	pm := make(portMap)
+	ports := make(map[string]*opb.Port)
+	devIDs := make(map[string]bool)
 	for _, d := range append(tb.GetDuts(), tb.GetAtes()...) {
 		if err := checkID(d.GetId()); err != nil {
-			return err
+			errs = append(errs, err)
+		}
+		if devIDs[d.GetId()] {
+			errs = append(errs, usererr.New("duplicate device %q", d.GetId()))
+		}
+		devIDs[d.GetId()] = true
+		if err := checkCriteria(d); err != nil {
+			errs = append(errs, err)
 		}
 		for _, p := range d.GetPorts() {
 			if err := checkID(p.GetId()); err != nil {
-				return err
+				errs = append(errs, err)
 			}
 			pid := fmt.Sprintf("%s:%s", d.GetId(), p.GetId())
 			if _, ok := pm[pid]; ok {
-				return usererr.New("duplicate port %q", pid)
+				errs = append(errs, usererr.New("duplicate port %q", pid))
+				continue
 			}
 			pm[pid] = ""
+			ports[pid] = p
 		}
 	}
 	for _, ln := range tb.GetLinks() {
-		dupB, ok := pm[ln.GetA()]
-		if !ok {
-			return usererr.New("nonexistent linked port ID %q", ln.GetA())
+		dupB, okA := pm[ln.GetA()]
+		if !okA {
+			errs = append(errs, usererr.New("nonexistent linked port ID %q", ln.GetA()))
 		}
-		dupA, ok := pm[ln.GetB()]
-		if !ok {
-			return usererr.New("nonexistent linked port ID %q", ln.GetB())
+		dupA, okB := pm[ln.GetB()]
+		if !okB {
+			errs = append(errs, usererr.New("nonexistent linked port ID %q", ln.GetB()))
+		}
+		if !okA || !okB {
+			continue
 		}
+		conflict := false
 		if dupB != "" {
-			return usererr.New("conflicting connections from %q to %q and %q", ln.GetA(), dupB, ln.GetB())
+			errs = append(errs, usererr.New("conflicting connections from %q to %q and %q", ln.GetA(), dupB, ln.GetB()))
+			conflict = true
 		}
 		if dupA != "" {
-			return usererr.New("conflicting connections from %q to %q and %q", ln.GetB(), dupA, ln.GetA())
+			errs = append(errs, usererr.New("conflicting connections from %q to %q and %q", ln.GetB(), dupA, ln.GetA()))
+			conflict = true
+		}
+		if conflict {
+			continue
 		}
 		pm[ln.GetA()] = ln.GetB()
 		pm[ln.GetB()] = ln.GetA()
+		for _, pid := range []string{ln.GetA(), ln.GetB()} {
+			if s := ports[pid].GetSpeed(); s != opb.Port_S_UNKNOWN && s < ln.GetMinSpeed() {
+				errs = append(errs, usererr.New("port %q has speed %v, less than the minimum speed %v of its link", pid, s, ln.GetMinSpeed()))
+			}
+		}
 	}
-	return nil
+	return errs
 }
 
 var idRE = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
@@ -140,6 +249,22 @@ func checkID(id string) error {
 	return nil
 }
 
+// checkCriteria checks that the regex criteria of the device are valid.
+func checkCriteria(d *opb.Device) error {
+	if _, err := Matcher(d.GetHardwareModel()); err != nil {
+		return usererr.Wrapf(err, "invalid hardware model of device %q", d.GetId())
+	}
+	if _, err := Matcher(d.GetSoftwareVersion()); err != nil {
+		return usererr.Wrapf(err, "invalid software version of device %q", d.GetId())
+	}
+	for k, v := range d.GetExtraDimensions() {
+		if _, err := Matcher(v); err != nil {
+			return usererr.Wrapf(err, "invalid extra dimension %q of device %q", k, d.GetId())
+		}
+	}
+	return nil
+}
+
 func validateRes(tb *opb.Testbed, res *reservation.Reservation) error {
 	for _, dut := range tb.GetDuts() {
 		rd, err := res.DUT(dut.GetId())
@@ -159,6 +284,11 @@ func validateRes(tb *opb.Testbed, res *reservation.Reservation) error {
 			return err
 		}
 	}
+	for _, ln := range tb.GetLinks() {
+		if err := validateLink(ln, res); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -176,6 +306,30 @@ func validateDevice(dev *opb.Device, rd reservation.Device) error {
 	if dims.SoftwareVersion == "" {
 		return errors.Errorf("no software version for reserved device: %v", rd)
 	}
+	if v := dev.GetVendor(); v != opb.Device_UNKNOWN && dims.Vendor != v {
+		return errors.Errorf("reserved device has vendor %v, want %v: %v", dims.Vendor, v, rd)
+	}
+	if err := checkMatch("hardware model", dev.GetHardwareModel(), dims.HardwareModel, rd); err != nil {
+		return err
+	}
+	if err := checkMatch("software version", dev.GetSoftwareVersion(), dims.SoftwareVersion, rd); err != nil {
+		return err
+	}
+	var keys []string
+	for k := range dev.GetExtraDimensions() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := dims.ExtraDimensions[k]
+		if !ok {
+			return errors.Errorf("reserved device has no extra dimension %q: %v", k, rd)
+		}
+		if err := checkMatch(fmt.Sprintf("extra dimension %q", k), dev.GetExtraDimensions()[k], v, rd); err != nil {
+			return err
+		}
+	}
+	breakouts := make(map[string]string)
 	for _, p := range dev.GetPorts() {
 		rp, err := dims.Port(p.GetId())
 		if err != nil {
@@ -184,11 +338,63 @@ func validateDevice(dev *opb.Device, rd reservation.Device) error {
 		if rp.Name == "" {
 			return errors.Errorf("no name for reserved port: %v", rp)
 		}
+		if s := p.GetSpeed(); s != opb.Port_S_UNKNOWN && rp.Speed != s {
+			return errors.Errorf("reserved port has speed %v, want %v: %v", rp.Speed, s, rp)
+		}
+		if pmd := p.GetPmd(); pmd != "" && rp.PMD != pmd {
+			return errors.Errorf("reserved port has PMD %q, want %q: %v", rp.PMD, pmd, rp)
+		}
+		if g := p.GetGroup(); g != "" {
+			if rp.Breakout == "" {
+				return errors.Errorf("reserved port of group %q is not a breakout channel: %v", g, rp)
+			}
+			if b, ok := breakouts[g]; ok && b != rp.Breakout {
+				return errors.Errorf("reserved ports of group %q are breakout channels of different ports %q and %q", g, b, rp.Breakout)
+			}
+			breakouts[g] = rp.Breakout
+		}
 	}
 	return nil
 }
 
-// Release releases the testbed.
+// checkMatch checks that a value of the reserved device matches the testbed
+// criterion for it.
+func checkMatch(what, criterion, value string, rd reservation.Device) error {
+	match, err := Matcher(criterion)
+	if err != nil {
+		return err
+	}
+	if !match(value) {
+		return errors.Errorf("reserved device has %s %q, which does not match %q: %v", what, value, criterion, rd)
+	}
+	return nil
+}
+
+func validateLink(ln *opb.Link, res *reservation.Reservation) error {
+	if ln.GetMinSpeed() == opb.Port_S_UNKNOWN && !ln.GetLagMember() {
+		return nil
+	}
+	for _, end := range []string{ln.GetA(), ln.GetB()} {
+		parts := strings.SplitN(end, ":", 2)
+		rd, err := res.Device(parts[0])
+		if err != nil {
+			return err
+		}
+		rp, err := rd.Dimensions().Port(parts[1])
+		if err != nil {
+			return err
+		}
+		if rp.Speed < ln.GetMinSpeed() {
+			return errors.Errorf("reserved port %s has speed %v, want at least %v: %v", end, rp.Speed, ln.GetMinSpeed(), rp)
+		}
+		if ln.GetLagMember() && !rp.LAGMember {
+			return errors.Errorf("reserved port %s is not a LAG member: %v", end, rp)
+		}
+	}
+	return nil
+}
+
+// Release releases the testbed, unless it was attached to with Fetch.
 func Release(ctx context.Context) error {
 	resMu.Lock()
 	defer resMu.Unlock()
@@ -196,5 +402,10 @@ func Release(ctx context.Context) error {
 		return nil
 	}
 	res = nil
+	peers = nil
+	if attached {
+		attached = false
+		return nil
+	}
 	return binding.Get().Release(ctx)
 }
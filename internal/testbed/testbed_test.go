@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testbed
+
+import (
+	"golang.org/x/net/context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/prototext"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/fakebind"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		desc, tb string
+		want     []string
+	}{{
+		desc: "valid",
+		tb: `
+			duts {id: "dut" ports {id: "port1"}}
+			ates {id: "ate" ports {id: "port1"}}
+			links {a: "dut:port1" b: "ate:port1"}
+		`,
+	}, {
+		desc: "all errors",
+		tb: `
+			duts {id: "dut" ports {id: "port1"} ports {id: "port1"}}
+			duts {id: "dut" hardware_model: "regex:("}
+			ates {id: "ate" ports {id: "port1" speed: S_10GB} ports {id: "port2"}}
+			links {a: "dut:port1" b: "ate:port1" min_speed: S_100GB}
+			links {a: "dut:port9" b: "ate:port1"}
+			links {a: "dut:port1" b: "ate:port2"}
+		`,
+		want: []string{
+			`duplicate port "dut:port1"`,
+			`duplicate device "dut"`,
+			`invalid hardware model of device "dut"`,
+			`port "ate:port1" has speed S_10GB, less than the minimum speed S_100GB of its link`,
+			`nonexistent linked port ID "dut:port9"`,
+			`conflicting connections from "dut:port1" to "ate:port1" and "ate:port2"`,
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tb := &opb.Testbed{}
+			if err := prototext.Unmarshal([]byte(test.tb), tb); err != nil {
+				t.Fatalf("failed to parse testbed: %v", err)
+			}
+			var got []string
+			for _, err := range Validate(tb) {
+				got = append(got, err.Error())
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("Validate() got errors %q, want errors containing %q", got, test.want)
+			}
+			for i, want := range test.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("Validate() got error %q, want error containing %q", got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveReleasesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testbed.textproto")
+	if err := ioutil.WriteFile(path, []byte(`duts {id: "dut"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fb := &fakebind.Binding{Reservation: &reservation.Reservation{ID: "res1"}}
+	var released bool
+	fb.Releaser = func(context.Context) error {
+		released = true
+		return errors.New("release failed")
+	}
+	binding.Init(fb)
+
+	err := Reserve(context.Background(), path, 0, 0)
+	if err == nil {
+		t.Fatalf("Reserve() of a reservation without the DUT got no error")
+	}
+	if !released {
+		t.Errorf("Reserve() did not release the invalid reservation")
+	}
+	if want := "release failed"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Reserve() got error %v, want it to contain %q", err, want)
+	}
+	if _, err := Reservation(); err == nil {
+		t.Errorf("Reserve() recorded the invalid reservation")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The validate command checks a testbed file offline, without a binding or a
+// reservation, and reports all the errors it finds. If the testbed is valid,
+// it can also render the testbed as a Graphviz DOT or Mermaid graph, e.g. for
+// reviewing topology changes:
+//
+//	validate -testbed=testbed.textproto -format=mermaid -out=testbed.mmd
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/golang/glog"
+	"github.com/openconfig/ondatra/internal/testbed"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+var (
+	testbedPath = flag.String("testbed", "", "Path to the testbed file.")
+	format      = flag.String("format", "", `Format in which to render a valid testbed: "dot", "mermaid", or empty to only validate it.`)
+	outPath     = flag.String("out", "", "Path of the file to write the rendered testbed to; defaults to stdout.")
+)
+
+var renderers = map[string]func(*opb.Testbed) string{
+	"dot":     testbed.DOT,
+	"mermaid": testbed.Mermaid,
+}
+
+func main() {
+	flag.Parse()
+	if *testbedPath == "" {
+		log.Exit("testbed path not specified")
+	}
+	render, ok := renderers[*format]
+	if !ok && *format != "" {
+		log.Exitf("invalid format %q: must be \"dot\" or \"mermaid\"", *format)
+	}
+	tb, err := testbed.LoadTestbed(*testbedPath)
+	if err != nil {
+		log.Exit(err)
+	}
+	if errs := testbed.Validate(tb); len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "Testbed %s has %d error(s):\n", *testbedPath, len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  %v\n", err)
+		}
+		os.Exit(1)
+	}
+	if render == nil {
+		fmt.Fprintf(os.Stderr, "Testbed %s is valid\n", *testbedPath)
+		return
+	}
+	out := render(tb)
+	if *outPath == "" {
+		fmt.Print(out)
+		return
+	}
+	if err := ioutil.WriteFile(*outPath, []byte(out), 0644); err != nil {
+		log.Exitf("failed to write rendered testbed: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knebind
+
+import (
+	"bytes"
+	"golang.org/x/net/context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// otgATE is an ixia-c controller for an ATE node, driven through the REST
+// API of the Open Traffic Generator model.
+type otgATE struct {
+	url    string
+	client *http.Client
+
+	// mu serializes the operations on the controller, which may be driven by
+	// parallel tests, and guards the fields below.
+	mu sync.Mutex
+	// cfg is the last config set on the controller.
+	cfg *otgConfig
+	// protocolsRunning is whether protocols were started since the topology
+	// was last pushed.
+	protocolsRunning bool
+}
+
+func (a *otgATE) do(ctx context.Context, method, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrapf(err, "error marshalling OTG request %v", body)
+	}
+	req, err := http.NewRequest(method, a.url+path, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "error creating OTG request to %s", path)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "error sending OTG request to %s", path)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("OTG request to %s failed with status %s: %s", path, resp.Status, respBody)
+	}
+	return nil
+}
+
+// setConfig sets the config of the controller; a.mu must be held.
+func (a *otgATE) setConfig(ctx context.Context, cfg *otgConfig) error {
+	if err := a.do(ctx, http.MethodPost, "/config", cfg); err != nil {
+		return err
+	}
+	a.cfg = cfg
+	return nil
+}
+
+func (a *otgATE) setProtocolState(ctx context.Context, state string) error {
+	return a.do(ctx, http.MethodPost, "/control/state", &otgControlState{
+		Choice:   "protocol",
+		Protocol: &otgProtocolCtrl{Choice: "all", All: &otgState{State: state}},
+	})
+}
+
+func (a *otgATE) setTransmitState(ctx context.Context, flowNames []string, state string) error {
+	return a.do(ctx, http.MethodPost, "/control/state", &otgControlState{
+		Choice: "traffic",
+		Traffic: &otgTrafficCtrl{
+			Choice:       "flow_transmit",
+			FlowTransmit: &otgFlowsState{FlowNames: flowNames, State: state},
+		},
+	})
+}
+
+func (a *otgATE) setLinkState(ctx context.Context, portNames []string, state string) error {
+	return a.do(ctx, http.MethodPost, "/control/state", &otgControlState{
+		Choice: "port",
+		Port:   &otgPortCtrl{Choice: "link", Link: &otgPortsState{PortNames: portNames, State: state}},
+	})
+}
+
+func (a *otgATE) updateFlows(ctx context.Context, flows []*otgFlow) error {
+	return a.do(ctx, http.MethodPatch, "/config", &otgConfigUpdate{
+		Choice: "flows",
+		Flows:  &otgFlowsUpdate{PropertyNames: []string{"rate", "size"}, Flows: flows},
+	})
+}
+
+// otgFor returns the OTG controller of the ATE, which is reached through the
+// "https" service of the ATE node.
+func (b *Bind) otgFor(ate *reservation.ATE) (*otgATE, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a, ok := b.ate2OTG[ate]; ok {
+		return a, nil
+	}
+	addr, err := serviceAddr(b.ate2Node[ate], "https")
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Using OTG controller for ate %s@%s", ate.Name, addr)
+	a := &otgATE{
+		url: fmt.Sprintf("https://%s", addr),
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+	}
+	b.ate2OTG[ate] = a
+	return a, nil
+}
+
+// PushTopology sets the topology of the ATE as the config of its OTG
+// controller, which removes all flows.
+func (b *Bind) PushTopology(ate *reservation.ATE, top *opb.Topology) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cfg, err := otgTopology(top)
+	if err != nil {
+		return err
+	}
+	if err := a.setConfig(context.Background(), cfg); err != nil {
+		return err
+	}
+	a.protocolsRunning = false
+	return nil
+}
+
+// UpdateTopology sets the topology of the ATE, keeping its flows, and
+// restarts protocols if they were running.
+func (b *Bind) UpdateTopology(ate *reservation.ATE, top *opb.Topology) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cfg == nil {
+		return usererr.New("no topology pushed to %s", ate.Name)
+	}
+	cfg, err := otgTopology(top)
+	if err != nil {
+		return err
+	}
+	cfg.Flows = a.cfg.Flows
+	ctx := context.Background()
+	if err := a.setConfig(ctx, cfg); err != nil {
+		return err
+	}
+	if a.protocolsRunning {
+		return a.setProtocolState(ctx, "start")
+	}
+	return nil
+}
+
+// StartProtocols starts the protocols on the ATE's OTG controller.
+func (b *Bind) StartProtocols(ate *reservation.ATE) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.setProtocolState(context.Background(), "start"); err != nil {
+		return err
+	}
+	a.protocolsRunning = true
+	return nil
+}
+
+// StopProtocols stops the protocols on the ATE's OTG controller.
+func (b *Bind) StopProtocols(ate *reservation.ATE) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.setProtocolState(context.Background(), "stop"); err != nil {
+		return err
+	}
+	a.protocolsRunning = false
+	return nil
+}
+
+// StartTraffic sets the flows in the config of the ATE's OTG controller and
+// starts transmitting them.
+func (b *Bind) StartTraffic(ate *reservation.ATE, flows []*opb.Flow) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cfg == nil {
+		return usererr.New("no topology pushed to %s", ate.Name)
+	}
+	ofs, err := otgFlows(flows)
+	if err != nil {
+		return err
+	}
+	cfg := *a.cfg
+	cfg.Flows = ofs
+	ctx := context.Background()
+	if err := a.setConfig(ctx, &cfg); err != nil {
+		return err
+	}
+	if a.protocolsRunning {
+		// Setting the config stops protocols, so they must be restarted.
+		if err := a.setProtocolState(ctx, "start"); err != nil {
+			return err
+		}
+	}
+	return a.setTransmitState(ctx, flowNames(ofs), "start")
+}
+
+// UpdateTraffic updates the rate and size of running flows.
+func (b *Bind) UpdateTraffic(ate *reservation.ATE, flows []*opb.Flow) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ofs, err := otgFlows(flows)
+	if err != nil {
+		return err
+	}
+	return a.updateFlows(context.Background(), ofs)
+}
+
+// StopTraffic stops transmitting all flows.
+func (b *Bind) StopTraffic(ate *reservation.ATE) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.setTransmitState(context.Background(), nil, "stop")
+}
+
+// SetATEPortState sets the link state of the ATE port.
+func (b *Bind) SetATEPortState(ate *reservation.ATE, port string, enabled bool) error {
+	a, err := b.otgFor(ate)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := "down"
+	if enabled {
+		state = "up"
+	}
+	return a.setLinkState(context.Background(), []string{port}, state)
+}
+
+// DialATEGNMI creates a client connection to the ATE node's "gnmi" service,
+// which serves the telemetry of the OTG controller.
+func (b *Bind) DialATEGNMI(ctx context.Context, ate *reservation.ATE, opts ...grpc.DialOption) (gpb.GNMIClient, error) {
+	conn, err := b.dialGRPC(ctx, ate.Name, b.ate2Node[ate], "gnmi", opts)
+	if err != nil {
+		return nil, err
+	}
+	return gpb.NewGNMIClient(conn), nil
+}
@@ -29,6 +29,7 @@ type Config struct {
 	TopoPath           string `yaml:"topology"`
 	CLIPath            string `yaml:"cli"`
 	KubecfgPath        string `yaml:"kubecfg"`
+	SSHPath            string `yaml:"ssh"`
 }
 
 func (c *Config) String() string {
@@ -58,5 +59,9 @@ func ParseConfigFile(configFile string) (*Config, error) {
 		// If no CLI path specified, use kne_cli available in PATH.
 		c.CLIPath = "kne_cli"
 	}
+	if c.SSHPath == "" {
+		// If no SSH path specified, use ssh available in PATH.
+		c.SSHPath = "ssh"
+	}
 	return c, nil
 }
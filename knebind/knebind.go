@@ -19,7 +19,11 @@ import (
 	"golang.org/x/net/context"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,15 +53,18 @@ var (
 		kpb.Node_IXIA_TG:      opb.Device_IXIA,
 	}
 
-	fetchTopo = fetchTopology // to be stubbed out by tests
+	fetchTopo  = fetchTopology  // to be stubbed out by tests
+	pushVendor = pushVendorText // to be stubbed out by tests
 )
 
 // Bind implements the ondatra Binding interface for KNE
 type Bind struct {
 	binding.Binding
-	dut2GNMIAddr map[*reservation.DUT]string
-	mu           sync.Mutex
-	cfg          *Config
+	dut2Node map[*reservation.DUT]*kpb.Node
+	ate2Node map[*reservation.ATE]*kpb.Node
+	mu       sync.Mutex
+	ate2OTG  map[*reservation.ATE]*otgATE
+	cfg      *Config
 }
 
 // New returns a new KNE bind instance.
@@ -66,8 +73,10 @@ func New(cfg *Config) (*Bind, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 	return &Bind{
-		cfg:          cfg,
-		dut2GNMIAddr: make(map[*reservation.DUT]string),
+		cfg:      cfg,
+		dut2Node: make(map[*reservation.DUT]*kpb.Node),
+		ate2Node: make(map[*reservation.ATE]*kpb.Node),
+		ate2OTG:  make(map[*reservation.ATE]*otgATE),
 	}, nil
 }
 
@@ -104,6 +113,19 @@ func (b *Bind) Reserve(ctx context.Context, tb *opb.Testbed, runTime time.Durati
 	return res, nil
 }
 
+// FetchReservation implements the binding.Fetcher interface. KNE nodes are not
+// held by a reservation, so it resolves the testbed on the topology again,
+// which deterministically yields the same nodes and interfaces, and returns
+// the reservation with the specified ID.
+func (b *Bind) FetchReservation(ctx context.Context, tb *opb.Testbed, id string) (*reservation.Reservation, error) {
+	res, err := b.Reserve(ctx, tb, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	res.ID = id
+	return res, nil
+}
+
 func fetchTopology(cfg *Config) (*kpb.Topology, error) {
 	args := []string{"topology", "service", cfg.TopoPath}
 	if cfg.KubecfgPath != "" {
@@ -126,11 +148,12 @@ func (b *Bind) resolveDUT(dev *opb.Device, a *assign) (*reservation.DUT, error)
 	if err != nil {
 		return nil, err
 	}
-	dut := &reservation.DUT{dims}
-	b.dut2GNMIAddr[dut], err = gnmiAddr(a.dev2Node[dev])
-	if err != nil {
+	node := a.dev2Node[dev]
+	if _, err := serviceAddr(node, "gnmi"); err != nil {
 		return nil, err
 	}
+	dut := &reservation.DUT{dims}
+	b.dut2Node[dut] = node
 	return dut, nil
 }
 
@@ -139,7 +162,9 @@ func (b *Bind) resolveATE(dev *opb.Device, a *assign) (*reservation.ATE, error)
 	if err != nil {
 		return nil, err
 	}
-	return &reservation.ATE{dims}, nil
+	ate := &reservation.ATE{dims}
+	b.ate2Node[ate] = a.dev2Node[dev]
+	return ate, nil
 }
 
 func (b *Bind) resolveDims(dev *opb.Device, a *assign) (*reservation.Dims, error) {
@@ -148,28 +173,54 @@ func (b *Bind) resolveDims(dev *opb.Device, a *assign) (*reservation.Dims, error
 	if !ok {
 		return nil, errors.Errorf("No known device vendor for node type: %v", node.GetType())
 	}
-	typeName := kpb.Node_Type_name[int32(node.GetType())]
 	dims := &reservation.Dims{
-		Name:   node.GetName(),
-		Vendor: vendor,
-		// TODO: Determine appropriate hardware model and software version
-		HardwareModel:   typeName,
-		SoftwareVersion: typeName,
+		Name:            node.GetName(),
+		Vendor:          vendor,
+		HardwareModel:   hardwareModel(node),
+		SoftwareVersion: softwareVersion(node),
 		Ports:           make(map[string]*reservation.Port),
 	}
+	for k, v := range node.GetLabels() {
+		if dims.ExtraDimensions == nil {
+			dims.ExtraDimensions = make(map[string]string)
+			dims.Metadata.Labels = make(map[string]string)
+		}
+		dims.ExtraDimensions[k] = v
+		dims.Metadata.Labels[k] = v
+	}
+	dims.Metadata.ManagementAddress = managementAddr(node)
 	for _, p := range dev.GetPorts() {
-		dims.Ports[p.GetId()] = &reservation.Port{Name: a.port2Intf[p].name}
+		intf := a.port2Intf[p]
+		dims.Ports[p.GetId()] = &reservation.Port{Name: intf.name, Speed: intf.speed}
 	}
 	return dims, nil
 }
 
-func gnmiAddr(node *kpb.Node) (string, error) {
+// managementAddr returns the outside IP of the node's "ssh" service, or else
+// of any of its services, or "" if it has none.
+func managementAddr(node *kpb.Node) string {
+	var ports []uint32
+	for port, s := range node.GetServices() {
+		if s.GetName() == "ssh" {
+			return s.GetOutsideIp()
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) == 0 {
+		return ""
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return node.GetServices()[ports[0]].GetOutsideIp()
+}
+
+// serviceAddr returns the outside address of the named service of the node.
+func serviceAddr(node *kpb.Node, name string) (string, error) {
 	for _, s := range node.GetServices() {
-		if s.GetName() == "gnmi" {
+		if s.GetName() == name {
 			return fmt.Sprintf("%s:%d", s.GetOutsideIp(), s.GetOutside()), nil
 		}
 	}
-	return "", errors.Errorf("No GNMI service found in node: %v", node)
+	return "", errors.Errorf("No %s service found in node: %v", strings.ToUpper(name), node)
 }
 
 // Release is a no-op because there's need to reserve local VMs.
@@ -182,9 +233,89 @@ func (b *Bind) SetTestMetadata(_ *binding.TestMetadata) error {
 	return nil
 }
 
+// PushConfig pushes OpenConfig JSON to the DUT with a gNMI Set, and vendor
+// config text with the "kne_cli topology push" command.
+func (b *Bind) PushConfig(ctx context.Context, dut *reservation.DUT, config string, opts *binding.ConfigOptions) error {
+	if opts.OpenConfig {
+		return b.pushOpenConfig(ctx, dut, config, opts.Append)
+	}
+	if opts.Append {
+		return errors.Errorf("appending vendor config is not supported on KNE node %s", dut.Name)
+	}
+	return pushVendor(b.cfg, dut.Name, config)
+}
+
+func (b *Bind) pushOpenConfig(ctx context.Context, dut *reservation.DUT, config string, append bool) error {
+	// Dial a connection for this push alone and close it afterwards, so that
+	// repeated pushes do not leak connections.
+	conn, err := b.dialGRPC(ctx, dut.Name, b.dut2Node[dut], "gnmi", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	gnmi := gpb.NewGNMIClient(conn)
+	u := &gpb.Update{
+		Path: &gpb.Path{},
+		Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(config)}},
+	}
+	req := &gpb.SetRequest{Replace: []*gpb.Update{u}}
+	if append {
+		req = &gpb.SetRequest{Update: []*gpb.Update{u}}
+	}
+	if _, err := gnmi.Set(ctx, req); err != nil {
+		return errors.Wrapf(err, "error pushing OpenConfig to node %s", dut.Name)
+	}
+	return nil
+}
+
+func pushVendorText(cfg *Config, node, config string) error {
+	f, err := ioutil.TempFile("", "kne-config-*")
+	if err != nil {
+		return errors.Wrap(err, "error creating config file")
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(config); err != nil {
+		f.Close()
+		return errors.Wrap(err, "error writing config file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "error writing config file")
+	}
+	args := []string{"topology", "push", cfg.TopoPath, node, f.Name()}
+	if cfg.KubecfgPath != "" {
+		args = append(args, fmt.Sprintf("--kubecfg=%s", cfg.KubecfgPath))
+	}
+	cmd := exec.Command(cfg.CLIPath, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "error executing command %v: %s", cmd, out)
+	}
+	return nil
+}
+
+// DialGNMI creates a client connection to the DUT's "gnmi" service.
 func (b *Bind) DialGNMI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (gpb.GNMIClient, error) {
-	addr := b.dut2GNMIAddr[dut]
-	log.Infof("Dialing GNMI dut %s@%s", dut.Name, addr)
+	conn, err := b.dialGRPC(ctx, dut.Name, b.dut2Node[dut], "gnmi", opts)
+	if err != nil {
+		return nil, err
+	}
+	return gpb.NewGNMIClient(conn), nil
+}
+
+// DialGNOI creates a client connection to the DUT's "gnoi" service.
+func (b *Bind) DialGNOI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.GNOIClients, error) {
+	conn, err := b.dialGRPC(ctx, dut.Name, b.dut2Node[dut], "gnoi", opts)
+	if err != nil {
+		return nil, err
+	}
+	return binding.NewGNOIClients(conn), nil
+}
+
+func (b *Bind) dialGRPC(ctx context.Context, name string, node *kpb.Node, service string, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	addr, err := serviceAddr(node, service)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Dialing %s %s@%s", strings.ToUpper(service), name, addr)
 	opts = append(opts,
 		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})),
 		grpc.WithPerRPCCredentials(&passCred{
@@ -195,7 +326,23 @@ func (b *Bind) DialGNMI(ctx context.Context, dut *reservation.DUT, opts ...grpc.
 	if err != nil {
 		return nil, errors.Wrapf(err, "DialContext(ctx, %s, %v)", addr, opts)
 	}
-	return gpb.NewGNMIClient(conn), nil
+	return conn, nil
+}
+
+// DialCLI creates an SSH session to the DUT's "ssh" service.
+func (b *Bind) DialCLI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.StreamClient, error) {
+	addr, err := serviceAddr(b.dut2Node[dut], "ssh")
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Dialing SSH dut %s@%s", dut.Name, addr)
+	return newSSHClient(b.cfg, addr)
+}
+
+// DialConsole creates an SSH session to the DUT's "ssh" service, because
+// KNE nodes have no separate console.
+func (b *Bind) DialConsole(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.StreamClient, error) {
+	return b.DialCLI(ctx, dut, opts...)
 }
 
 type passCred struct {
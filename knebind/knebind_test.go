@@ -16,11 +16,15 @@ package knebind
 
 import (
 	"golang.org/x/net/context"
+	"io/ioutil"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/openconfig/gnmi/errdiff"
+	"github.com/openconfig/ondatra/internal/binding"
 	"github.com/openconfig/ondatra/internal/reservation"
 
 	kpb "github.com/google/kne/proto/topo"
@@ -30,13 +34,17 @@ import (
 func TestReserve(t *testing.T) {
 	topo := &kpb.Topology{
 		Nodes: []*kpb.Node{{
-			Name:     "node1",
-			Type:     kpb.Node_ARISTA_CEOS,
-			Services: map[uint32]*kpb.Service{1234: {Name: "gnmi"}},
+			Name:   "node1",
+			Type:   kpb.Node_ARISTA_CEOS,
+			Labels: map[string]string{"rack": "r1"},
+			Services: map[uint32]*kpb.Service{
+				1234: {Name: "gnmi", OutsideIp: "10.0.0.1"},
+				22:   {Name: "ssh", OutsideIp: "10.0.0.2"},
+			},
 		}, {
 			Name:     "node2",
 			Type:     kpb.Node_CISCO_CXR,
-			Services: map[uint32]*kpb.Service{2345: {Name: "gnmi"}},
+			Services: map[uint32]*kpb.Service{2345: {Name: "gnmi", OutsideIp: "10.0.0.3"}},
 		}, {
 			Name:     "node3",
 			Type:     kpb.Node_JUNIPER_CEVO,
@@ -89,10 +97,15 @@ func TestReserve(t *testing.T) {
 		Vendor:          opb.Device_ARISTA,
 		HardwareModel:   "ARISTA_CEOS",
 		SoftwareVersion: "ARISTA_CEOS",
+		ExtraDimensions: map[string]string{"rack": "r1"},
 		Ports: map[string]*reservation.Port{
 			"port1": {Name: "intf1"},
 			"port2": {Name: "intf2"},
 		},
+		Metadata: reservation.Metadata{
+			ManagementAddress: "10.0.0.2",
+			Labels:            map[string]string{"rack": "r1"},
+		},
 	}}
 	wantDUT2 := &reservation.DUT{&reservation.Dims{
 		Name:            "node2",
@@ -103,6 +116,7 @@ func TestReserve(t *testing.T) {
 			"port1": {Name: "intf1"},
 			"port2": {Name: "intf2"},
 		},
+		Metadata: reservation.Metadata{ManagementAddress: "10.0.0.3"},
 	}}
 	wantDUT3 := &reservation.DUT{&reservation.Dims{
 		Name:            "node3",
@@ -323,7 +337,7 @@ func TestReserveErrors(t *testing.T) {
 				{ANode: "node2", AInt: "eth1", ZNode: "node4", ZInt: "eth1"},
 			},
 		},
-		wantErr: "No KNE topology",
+		wantErr: "no pair of nodes has a direct link for dut1:port1<->dut2:port1",
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -341,3 +355,118 @@ func TestReserveErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchReservation(t *testing.T) {
+	fetchTopo = func(*Config) (*kpb.Topology, error) {
+		return &kpb.Topology{
+			Nodes: []*kpb.Node{{
+				Name:     "node1",
+				Type:     kpb.Node_ARISTA_CEOS,
+				Services: map[uint32]*kpb.Service{1234: {Name: "gnmi"}},
+			}},
+		}, nil
+	}
+	b, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	res, err := b.FetchReservation(context.Background(), &opb.Testbed{Duts: []*opb.Device{{Id: "dut"}}}, "res1")
+	if err != nil {
+		t.Fatalf("FetchReservation() got error: %v", err)
+	}
+	if got, want := res.ID, "res1"; got != want {
+		t.Errorf("FetchReservation() got reservation ID %q, want %q", got, want)
+	}
+	if got, want := res.DUTs["dut"].Name, "node1"; got != want {
+		t.Errorf("FetchReservation() got DUT name %q, want %q", got, want)
+	}
+}
+
+func TestPushConfig(t *testing.T) {
+	var gotNode, gotConfig string
+	pushVendor = func(_ *Config, node, config string) error {
+		gotNode, gotConfig = node, config
+		return nil
+	}
+	b, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	dut := &reservation.DUT{&reservation.Dims{Name: "node1"}}
+	if err := b.PushConfig(context.Background(), dut, "hostname node1", &binding.ConfigOptions{}); err != nil {
+		t.Fatalf("PushConfig() got error: %v", err)
+	}
+	if gotNode != "node1" || gotConfig != "hostname node1" {
+		t.Errorf("PushConfig() pushed %q to %q, want %q to %q", gotConfig, gotNode, "hostname node1", "node1")
+	}
+	err = b.PushConfig(context.Background(), dut, "hostname node1", &binding.ConfigOptions{Append: true})
+	if diff := errdiff.Substring(err, "not supported"); diff != "" {
+		t.Errorf("PushConfig() with append got unexpected error diff: %s", diff)
+	}
+}
+
+func TestDialMissingService(t *testing.T) {
+	b, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	dut := &reservation.DUT{&reservation.Dims{Name: "node1"}}
+	b.dut2Node[dut] = &kpb.Node{
+		Name:     "node1",
+		Services: map[uint32]*kpb.Service{1234: {Name: "gnmi"}},
+	}
+	_, err = b.DialGNOI(context.Background(), dut)
+	if diff := errdiff.Substring(err, "No GNOI service"); diff != "" {
+		t.Errorf("DialGNOI() got unexpected error diff: %s", diff)
+	}
+	_, err = b.DialCLI(context.Background(), dut)
+	if diff := errdiff.Substring(err, "No SSH service"); diff != "" {
+		t.Errorf("DialCLI() got unexpected error diff: %s", diff)
+	}
+}
+
+func TestSSHClient(t *testing.T) {
+	var gotArgs [][]string
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotArgs = append(gotArgs, append([]string{name}, args...))
+		// Echo the last argument, which is the command for SendCommand, and
+		// echo stdin back for the interactive session.
+		if args[len(args)-1] == "host" {
+			return exec.CommandContext(ctx, "cat")
+		}
+		return exec.CommandContext(ctx, "echo", args[len(args)-1])
+	}
+	defer func() { execCommand = exec.CommandContext }()
+
+	c, err := newSSHClient(&Config{Username: "user", Password: "pass", SSHPath: "ssh"}, "host:22")
+	if err != nil {
+		t.Fatalf("newSSHClient() got error: %v", err)
+	}
+	defer c.Close()
+	got, err := c.SendCommand(context.Background(), "show version")
+	if err != nil {
+		t.Fatalf("SendCommand() got error: %v", err)
+	}
+	if want := "show version\n"; got != want {
+		t.Errorf("SendCommand() got %q, want %q", got, want)
+	}
+	wantArgs := [][]string{
+		{"ssh", "-p", "22", "-l", "user", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", "-tt", "host"},
+		{"ssh", "-p", "22", "-l", "user", "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null", "-o", "LogLevel=ERROR", "host", "show version"},
+	}
+	if diff := cmp.Diff(wantArgs, gotArgs); diff != "" {
+		t.Errorf("ssh got unexpected diff in args (-want,+got): %s", diff)
+	}
+
+	if _, err := c.Stdin().Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Stdin().Write() got error: %v", err)
+	}
+	c.Stdin().Close()
+	out, err := ioutil.ReadAll(c.Stdout())
+	if err != nil {
+		t.Fatalf("ReadAll(Stdout()) got error: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "hello"; got != want {
+		t.Errorf("Stdout() got %q, want %q", got, want)
+	}
+}
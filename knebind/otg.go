@@ -0,0 +1,567 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knebind
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// The types below are the subset of the Open Traffic Generator (OTG) model
+// that the ATE topologies and flows of Ondatra are translated into.
+// See https://github.com/open-traffic-generator/models for the full model.
+
+type otgConfig struct {
+	Ports   []*otgPort   `json:"ports,omitempty"`
+	Lags    []*otgLag    `json:"lags,omitempty"`
+	Devices []*otgDevice `json:"devices,omitempty"`
+	Flows   []*otgFlow   `json:"flows,omitempty"`
+}
+
+type otgPort struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+type otgLag struct {
+	Name     string          `json:"name"`
+	Ports    []*otgLagPort   `json:"ports"`
+	Protocol *otgLagProtocol `json:"protocol"`
+}
+
+type otgLagPort struct {
+	PortName string           `json:"port_name"`
+	Ethernet *otgLagPortEther `json:"ethernet"`
+}
+
+type otgLagPortEther struct {
+	Name string `json:"name"`
+	MAC  string `json:"mac"`
+}
+
+type otgLagProtocol struct {
+	Choice string              `json:"choice"`
+	Static map[string]struct{} `json:"static,omitempty"`
+	LACP   map[string]struct{} `json:"lacp,omitempty"`
+}
+
+type otgDevice struct {
+	Name      string         `json:"name"`
+	Ethernets []*otgEthernet `json:"ethernets"`
+}
+
+type otgEthernet struct {
+	Name          string          `json:"name"`
+	Connection    *otgConnection  `json:"connection"`
+	MAC           string          `json:"mac"`
+	MTU           uint32          `json:"mtu,omitempty"`
+	Vlans         []*otgVlan      `json:"vlans,omitempty"`
+	IPv4Addresses []*otgIPAddress `json:"ipv4_addresses,omitempty"`
+	IPv6Addresses []*otgIPAddress `json:"ipv6_addresses,omitempty"`
+}
+
+type otgConnection struct {
+	Choice   string `json:"choice"`
+	PortName string `json:"port_name,omitempty"`
+	LagName  string `json:"lag_name,omitempty"`
+}
+
+type otgVlan struct {
+	Name string `json:"name"`
+	ID   uint32 `json:"id"`
+}
+
+type otgIPAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Gateway string `json:"gateway"`
+	Prefix  int    `json:"prefix"`
+}
+
+type otgFlow struct {
+	Name     string         `json:"name"`
+	TxRx     *otgTxRx       `json:"tx_rx"`
+	Packet   []*otgHeader   `json:"packet,omitempty"`
+	Size     *otgSize       `json:"size,omitempty"`
+	Rate     *otgRate       `json:"rate,omitempty"`
+	Duration *otgDuration   `json:"duration,omitempty"`
+	Metrics  *otgFlowMetric `json:"metrics"`
+}
+
+type otgTxRx struct {
+	Choice string      `json:"choice"`
+	Device *otgDevices `json:"device"`
+}
+
+type otgDevices struct {
+	TxNames []string `json:"tx_names"`
+	RxNames []string `json:"rx_names"`
+}
+
+// otgHeader is a packet header, keyed by its choice, e.g. "ipv4", and with
+// the fields of the header as a map from field name to pattern.
+type otgHeader struct {
+	Choice   string                 `json:"choice"`
+	Ethernet map[string]*otgPattern `json:"ethernet,omitempty"`
+	Vlan     map[string]*otgPattern `json:"vlan,omitempty"`
+	IPv4     map[string]*otgPattern `json:"ipv4,omitempty"`
+	IPv6     map[string]*otgPattern `json:"ipv6,omitempty"`
+	MPLS     map[string]*otgPattern `json:"mpls,omitempty"`
+	GRE      map[string]*otgPattern `json:"gre,omitempty"`
+	TCP      map[string]*otgPattern `json:"tcp,omitempty"`
+	UDP      map[string]*otgPattern `json:"udp,omitempty"`
+}
+
+// otgPattern is the value of a header field: a single value, or a range of
+// values that increments with every packet.
+type otgPattern struct {
+	Choice    string        `json:"choice"`
+	Value     interface{}   `json:"value,omitempty"`
+	Increment *otgIncrement `json:"increment,omitempty"`
+}
+
+type otgIncrement struct {
+	Start interface{} `json:"start"`
+	Step  interface{} `json:"step"`
+	Count uint32      `json:"count"`
+}
+
+type otgSize struct {
+	Choice      string          `json:"choice"`
+	Fixed       uint32          `json:"fixed,omitempty"`
+	Random      *otgSizeRange   `json:"random,omitempty"`
+	WeightPairs *otgWeightPairs `json:"weight_pairs,omitempty"`
+}
+
+type otgSizeRange struct {
+	Min uint32 `json:"min"`
+	Max uint32 `json:"max"`
+}
+
+type otgWeightPairs struct {
+	Choice     string `json:"choice"`
+	Predefined string `json:"predefined"`
+}
+
+type otgRate struct {
+	Choice     string  `json:"choice"`
+	PPS        uint64  `json:"pps,omitempty"`
+	BPS        uint64  `json:"bps,omitempty"`
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+type otgDuration struct {
+	Choice       string           `json:"choice"`
+	FixedPackets *otgFixedPackets `json:"fixed_packets,omitempty"`
+	Continuous   *otgContinuous   `json:"continuous,omitempty"`
+}
+
+type otgFixedPackets struct {
+	Packets uint32 `json:"packets"`
+	Gap     uint32 `json:"gap,omitempty"`
+}
+
+type otgContinuous struct {
+	Gap uint32 `json:"gap,omitempty"`
+}
+
+type otgFlowMetric struct {
+	Enable bool `json:"enable"`
+}
+
+// otgControlState is the body of a request to change the protocol, traffic or
+// port state of the ATE.
+type otgControlState struct {
+	Choice   string           `json:"choice"`
+	Protocol *otgProtocolCtrl `json:"protocol,omitempty"`
+	Traffic  *otgTrafficCtrl  `json:"traffic,omitempty"`
+	Port     *otgPortCtrl     `json:"port,omitempty"`
+}
+
+type otgProtocolCtrl struct {
+	Choice string    `json:"choice"`
+	All    *otgState `json:"all"`
+}
+
+type otgTrafficCtrl struct {
+	Choice       string         `json:"choice"`
+	FlowTransmit *otgFlowsState `json:"flow_transmit"`
+}
+
+type otgFlowsState struct {
+	FlowNames []string `json:"flow_names,omitempty"`
+	State     string   `json:"state"`
+}
+
+type otgPortCtrl struct {
+	Choice string         `json:"choice"`
+	Link   *otgPortsState `json:"link"`
+}
+
+type otgPortsState struct {
+	PortNames []string `json:"port_names"`
+	State     string   `json:"state"`
+}
+
+type otgState struct {
+	State string `json:"state"`
+}
+
+// otgConfigUpdate is the body of a request to update the rate and size of
+// flows without replacing the config.
+type otgConfigUpdate struct {
+	Choice string          `json:"choice"`
+	Flows  *otgFlowsUpdate `json:"flows"`
+}
+
+type otgFlowsUpdate struct {
+	PropertyNames []string   `json:"property_names"`
+	Flows         []*otgFlow `json:"flows"`
+}
+
+func ethName(intf string) string  { return intf + ".eth" }
+func ipv4Name(intf string) string { return intf + ".ipv4" }
+func ipv6Name(intf string) string { return intf + ".ipv6" }
+
+// otgTopology translates an ATE topology into the ports, LAGs and devices of
+// an OTG config. The location of each port is its KNE interface name.
+func otgTopology(top *opb.Topology) (*otgConfig, error) {
+	cfg := &otgConfig{}
+	ports := make(map[string]bool)
+	addPort := func(name string) {
+		if !ports[name] {
+			ports[name] = true
+			cfg.Ports = append(cfg.Ports, &otgPort{Name: name, Location: name})
+		}
+	}
+	var macs uint32
+	nextMAC := func() string {
+		macs++
+		return fmt.Sprintf("02:00:00:%02x:%02x:%02x", byte(macs>>16), byte(macs>>8), byte(macs))
+	}
+	for _, l := range top.GetLags() {
+		lag := &otgLag{Name: l.GetName(), Protocol: &otgLagProtocol{Choice: "static", Static: map[string]struct{}{}}}
+		for _, p := range l.GetPorts() {
+			addPort(p)
+			lag.Ports = append(lag.Ports, &otgLagPort{
+				PortName: p,
+				Ethernet: &otgLagPortEther{Name: l.GetName() + "." + p + ".eth", MAC: nextMAC()},
+			})
+		}
+		cfg.Lags = append(cfg.Lags, lag)
+	}
+	lacpLags := make(map[string]bool)
+	for _, i := range top.GetInterfaces() {
+		if err := checkUnsupportedIntf(i); err != nil {
+			return nil, err
+		}
+		eth := &otgEthernet{
+			Name:       ethName(i.GetName()),
+			MAC:        nextMAC(),
+			MTU:        i.GetEthernet().GetMtu(),
+			Connection: &otgConnection{Choice: "port_name", PortName: i.GetPort()},
+		}
+		if i.GetLag() != "" {
+			eth.Connection = &otgConnection{Choice: "lag_name", LagName: i.GetLag()}
+			if i.GetEnableLacp() {
+				lacpLags[i.GetLag()] = true
+			}
+		} else {
+			addPort(i.GetPort())
+		}
+		if vid := i.GetEthernet().GetVlanId(); vid != 0 {
+			eth.Vlans = []*otgVlan{{Name: i.GetName() + ".vlan", ID: vid}}
+		}
+		if i.GetIpv4() != nil {
+			ip, err := otgIP(ipv4Name(i.GetName()), i.GetIpv4())
+			if err != nil {
+				return nil, err
+			}
+			eth.IPv4Addresses = []*otgIPAddress{ip}
+		}
+		if i.GetIpv6() != nil {
+			ip, err := otgIP(ipv6Name(i.GetName()), i.GetIpv6())
+			if err != nil {
+				return nil, err
+			}
+			eth.IPv6Addresses = []*otgIPAddress{ip}
+		}
+		cfg.Devices = append(cfg.Devices, &otgDevice{Name: i.GetName(), Ethernets: []*otgEthernet{eth}})
+	}
+	for _, lag := range cfg.Lags {
+		if lacpLags[lag.Name] {
+			lag.Protocol = &otgLagProtocol{Choice: "lacp", LACP: map[string]struct{}{}}
+		}
+	}
+	return cfg, nil
+}
+
+func checkUnsupportedIntf(i *opb.InterfaceConfig) error {
+	switch {
+	case i.GetIsis() != nil:
+		return usererr.New("IS-IS on interface %q is not supported on KNE ATEs", i.GetName())
+	case i.GetBgp() != nil:
+		return usererr.New("BGP on interface %q is not supported on KNE ATEs", i.GetName())
+	case len(i.GetNetworks()) > 0:
+		return usererr.New("networks on interface %q are not supported on KNE ATEs", i.GetName())
+	case i.GetEthernet().GetMacsec() != nil:
+		return usererr.New("MACsec on interface %q is not supported on KNE ATEs", i.GetName())
+	}
+	return nil
+}
+
+func otgIP(name string, ipc *opb.IpConfig) (*otgIPAddress, error) {
+	ip, ipNet, err := net.ParseCIDR(ipc.GetAddressCidr())
+	if err != nil {
+		return nil, usererr.Wrapf(err, "invalid address of %q", name)
+	}
+	prefix, _ := ipNet.Mask.Size()
+	return &otgIPAddress{
+		Name:    name,
+		Address: ip.String(),
+		Gateway: ipc.GetDefaultGateway(),
+		Prefix:  prefix,
+	}, nil
+}
+
+// otgFlows translates ATE flows into OTG flows. The flow endpoints are the
+// devices of the topology, at the IP layer of the flow's outermost IP header.
+func otgFlows(flows []*opb.Flow) ([]*otgFlow, error) {
+	var ofs []*otgFlow
+	for _, f := range flows {
+		of, err := otgFlowOf(f)
+		if err != nil {
+			return nil, err
+		}
+		ofs = append(ofs, of)
+	}
+	return ofs, nil
+}
+
+func otgFlowOf(f *opb.Flow) (*otgFlow, error) {
+	endpointName := ethName
+	for _, h := range f.GetHeaders() {
+		if h.GetIpv4() != nil {
+			endpointName = ipv4Name
+			break
+		}
+		if h.GetIpv6() != nil {
+			endpointName = ipv6Name
+			break
+		}
+	}
+	devs := &otgDevices{}
+	for _, ep := range f.GetSrcEndpoints() {
+		if ep.GetNetworkName() != "" {
+			return nil, usererr.New("network endpoints of flow %q are not supported on KNE ATEs", f.GetName())
+		}
+		devs.TxNames = append(devs.TxNames, endpointName(ep.GetInterfaceName()))
+	}
+	for _, ep := range f.GetDstEndpoints() {
+		if ep.GetNetworkName() != "" {
+			return nil, usererr.New("network endpoints of flow %q are not supported on KNE ATEs", f.GetName())
+		}
+		devs.RxNames = append(devs.RxNames, endpointName(ep.GetInterfaceName()))
+	}
+	of := &otgFlow{
+		Name:    f.GetName(),
+		TxRx:    &otgTxRx{Choice: "device", Device: devs},
+		Metrics: &otgFlowMetric{Enable: true},
+	}
+	for _, h := range f.GetHeaders() {
+		oh, err := otgHeaderOf(h)
+		if err != nil {
+			return nil, usererr.Wrapf(err, "invalid header in flow %q", f.GetName())
+		}
+		of.Packet = append(of.Packet, oh...)
+	}
+	var err error
+	if of.Size, err = otgSizeOf(f.GetFrameSize()); err != nil {
+		return nil, usererr.Wrapf(err, "invalid frame size in flow %q", f.GetName())
+	}
+	of.Rate = otgRateOf(f.GetFrameRate())
+	of.Duration = otgDurationOf(f.GetTransmission())
+	return of, nil
+}
+
+func otgHeaderOf(h *opb.Header) ([]*otgHeader, error) {
+	switch t := h.GetType().(type) {
+	case *opb.Header_Eth:
+		fields, err := fieldPatterns(map[string]*opb.AddressRange{"src": t.Eth.GetSrcAddr(), "dst": t.Eth.GetDstAddr()})
+		if err != nil {
+			return nil, err
+		}
+		hs := []*otgHeader{{Choice: "ethernet", Ethernet: fields}}
+		if vid := t.Eth.GetVlanId(); vid != 0 {
+			hs = append(hs, &otgHeader{Choice: "vlan", Vlan: map[string]*otgPattern{"id": valuePattern(vid)}})
+		}
+		return hs, nil
+	case *opb.Header_Ipv4:
+		fields, err := fieldPatterns(map[string]*opb.AddressRange{"src": t.Ipv4.GetSrcAddr(), "dst": t.Ipv4.GetDstAddr()})
+		if err != nil {
+			return nil, err
+		}
+		if ttl := t.Ipv4.GetTtl(); ttl != 0 {
+			fields["time_to_live"] = valuePattern(ttl)
+		}
+		if t.Ipv4.GetDontFragment() {
+			fields["dont_fragment"] = valuePattern(1)
+		}
+		return []*otgHeader{{Choice: "ipv4", IPv4: fields}}, nil
+	case *opb.Header_Ipv6:
+		fields, err := fieldPatterns(map[string]*opb.AddressRange{"src": t.Ipv6.GetSrcAddr(), "dst": t.Ipv6.GetDstAddr()})
+		if err != nil {
+			return nil, err
+		}
+		if hl := t.Ipv6.GetHopLimit(); hl != 0 {
+			fields["hop_limit"] = valuePattern(hl)
+		}
+		if fl := uintPattern(t.Ipv6.GetFlowLabel()); fl != nil {
+			fields["flow_label"] = fl
+		}
+		return []*otgHeader{{Choice: "ipv6", IPv6: fields}}, nil
+	case *opb.Header_Mpls:
+		fields := make(map[string]*otgPattern)
+		if l := uintPattern(t.Mpls.GetLabel()); l != nil {
+			fields["label"] = l
+		}
+		if ttl := t.Mpls.GetTtl(); ttl != 0 {
+			fields["time_to_live"] = valuePattern(ttl)
+		}
+		if exp := t.Mpls.GetExp(); exp != 0 {
+			fields["traffic_class"] = valuePattern(exp)
+		}
+		return []*otgHeader{{Choice: "mpls", MPLS: fields}}, nil
+	case *opb.Header_Gre:
+		return []*otgHeader{{Choice: "gre", GRE: map[string]*otgPattern{}}}, nil
+	case *opb.Header_Tcp:
+		fields := make(map[string]*otgPattern)
+		if p := uintPattern(t.Tcp.GetSrcPort()); p != nil {
+			fields["src_port"] = p
+		}
+		if p := uintPattern(t.Tcp.GetDstPort()); p != nil {
+			fields["dst_port"] = p
+		}
+		if seq := t.Tcp.GetSeq(); seq != 0 {
+			fields["seq_num"] = valuePattern(seq)
+		}
+		return []*otgHeader{{Choice: "tcp", TCP: fields}}, nil
+	case *opb.Header_Udp:
+		fields := make(map[string]*otgPattern)
+		if p := uintPattern(t.Udp.GetSrcPort()); p != nil {
+			fields["src_port"] = p
+		}
+		if p := uintPattern(t.Udp.GetDstPort()); p != nil {
+			fields["dst_port"] = p
+		}
+		return []*otgHeader{{Choice: "udp", UDP: fields}}, nil
+	}
+	return nil, usererr.New("unsupported header type %T", h.GetType())
+}
+
+func valuePattern(v interface{}) *otgPattern {
+	return &otgPattern{Choice: "value", Value: v}
+}
+
+// fieldPatterns returns the patterns of the address fields that are set.
+func fieldPatterns(addrs map[string]*opb.AddressRange) (map[string]*otgPattern, error) {
+	fields := make(map[string]*otgPattern)
+	for name, ar := range addrs {
+		if ar.GetMin() == "" {
+			continue
+		}
+		if ar.GetRandom() {
+			return nil, usererr.New("random %s addresses are not supported on KNE ATEs", name)
+		}
+		if ar.GetCount() <= 1 {
+			fields[name] = valuePattern(ar.GetMin())
+			continue
+		}
+		fields[name] = &otgPattern{Choice: "increment", Increment: &otgIncrement{
+			Start: ar.GetMin(),
+			Step:  ar.GetStep(),
+			Count: ar.GetCount(),
+		}}
+	}
+	return fields, nil
+}
+
+// uintPattern returns the pattern of a range of integers, or nil if unset.
+func uintPattern(r *opb.UIntRange) *otgPattern {
+	switch {
+	case r == nil || (r.GetMin() == 0 && r.GetMax() == 0):
+		return nil
+	case r.GetCount() <= 1 && r.GetMin() == r.GetMax():
+		return valuePattern(r.GetMin())
+	}
+	step, count := r.GetStep(), r.GetCount()
+	if step == 0 {
+		step = 1
+	}
+	if count == 0 {
+		count = (r.GetMax()-r.GetMin())/step + 1
+	}
+	return &otgPattern{Choice: "increment", Increment: &otgIncrement{Start: r.GetMin(), Step: step, Count: count}}
+}
+
+func otgSizeOf(fs *opb.FrameSize) (*otgSize, error) {
+	switch t := fs.GetType().(type) {
+	case *opb.FrameSize_Fixed:
+		return &otgSize{Choice: "fixed", Fixed: t.Fixed}, nil
+	case *opb.FrameSize_Random_:
+		return &otgSize{Choice: "random", Random: &otgSizeRange{Min: t.Random.GetMin(), Max: t.Random.GetMax()}}, nil
+	case *opb.FrameSize_ImixPreset_:
+		if t.ImixPreset != opb.FrameSize_IMIX_DEFAULT {
+			return nil, usererr.New("IMIX preset %v is not supported on KNE ATEs", t.ImixPreset)
+		}
+		return &otgSize{Choice: "weight_pairs", WeightPairs: &otgWeightPairs{Choice: "predefined", Predefined: "imix"}}, nil
+	}
+	return nil, nil
+}
+
+func otgRateOf(fr *opb.FrameRate) *otgRate {
+	switch t := fr.GetType().(type) {
+	case *opb.FrameRate_Percent:
+		return &otgRate{Choice: "percentage", Percentage: t.Percent}
+	case *opb.FrameRate_Bps:
+		return &otgRate{Choice: "bps", BPS: t.Bps}
+	case *opb.FrameRate_Fps:
+		return &otgRate{Choice: "pps", PPS: t.Fps}
+	}
+	return nil
+}
+
+func otgDurationOf(tx *opb.Transmission) *otgDuration {
+	if tx.GetPattern() == opb.Transmission_BURST {
+		return &otgDuration{Choice: "fixed_packets", FixedPackets: &otgFixedPackets{
+			Packets: tx.GetPacketsPerBurst(),
+			Gap:     tx.GetMinGapBytes(),
+		}}
+	}
+	return &otgDuration{Choice: "continuous", Continuous: &otgContinuous{Gap: tx.GetMinGapBytes()}}
+}
+
+// flowNames returns the names of the OTG flows.
+func flowNames(flows []*otgFlow) []string {
+	var names []string
+	for _, f := range flows {
+		names = append(names, f.Name)
+	}
+	return names
+}
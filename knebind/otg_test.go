@@ -0,0 +1,265 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knebind
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gnmi/errdiff"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	kpb "github.com/google/kne/proto/topo"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+var otgTestTop = &opb.Topology{
+	Lags: []*opb.Lag{{Name: "lag1", Ports: []string{"eth2", "eth3"}}},
+	Interfaces: []*opb.InterfaceConfig{{
+		Name:     "intf1",
+		Link:     &opb.InterfaceConfig_Port{"eth1"},
+		Ethernet: &opb.EthernetConfig{Mtu: 1500, VlanId: 10},
+		Ipv4:     &opb.IpConfig{AddressCidr: "192.0.2.1/30", DefaultGateway: "192.0.2.2"},
+	}, {
+		Name:       "intf2",
+		Link:       &opb.InterfaceConfig_Lag{"lag1"},
+		EnableLacp: true,
+		Ipv6:       &opb.IpConfig{AddressCidr: "2001:db8::1/126", DefaultGateway: "2001:db8::2"},
+	}},
+}
+
+func TestOTGTopology(t *testing.T) {
+	got, err := otgTopology(otgTestTop)
+	if err != nil {
+		t.Fatalf("otgTopology() got error: %v", err)
+	}
+	want := &otgConfig{
+		Ports: []*otgPort{
+			{Name: "eth2", Location: "eth2"},
+			{Name: "eth3", Location: "eth3"},
+			{Name: "eth1", Location: "eth1"},
+		},
+		Lags: []*otgLag{{
+			Name: "lag1",
+			Ports: []*otgLagPort{
+				{PortName: "eth2", Ethernet: &otgLagPortEther{Name: "lag1.eth2.eth", MAC: "02:00:00:00:00:01"}},
+				{PortName: "eth3", Ethernet: &otgLagPortEther{Name: "lag1.eth3.eth", MAC: "02:00:00:00:00:02"}},
+			},
+			Protocol: &otgLagProtocol{Choice: "lacp", LACP: map[string]struct{}{}},
+		}},
+		Devices: []*otgDevice{{
+			Name: "intf1",
+			Ethernets: []*otgEthernet{{
+				Name:          "intf1.eth",
+				Connection:    &otgConnection{Choice: "port_name", PortName: "eth1"},
+				MAC:           "02:00:00:00:00:03",
+				MTU:           1500,
+				Vlans:         []*otgVlan{{Name: "intf1.vlan", ID: 10}},
+				IPv4Addresses: []*otgIPAddress{{Name: "intf1.ipv4", Address: "192.0.2.1", Gateway: "192.0.2.2", Prefix: 30}},
+			}},
+		}, {
+			Name: "intf2",
+			Ethernets: []*otgEthernet{{
+				Name:          "intf2.eth",
+				Connection:    &otgConnection{Choice: "lag_name", LagName: "lag1"},
+				MAC:           "02:00:00:00:00:04",
+				IPv6Addresses: []*otgIPAddress{{Name: "intf2.ipv6", Address: "2001:db8::1", Gateway: "2001:db8::2", Prefix: 126}},
+			}},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("otgTopology() got unexpected diff (-want,+got): %s", diff)
+	}
+}
+
+func TestOTGTopologyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		top     *opb.Topology
+		wantErr string
+	}{{
+		name:    "bgp",
+		top:     &opb.Topology{Interfaces: []*opb.InterfaceConfig{{Name: "intf1", Bgp: &opb.BgpConfig{}}}},
+		wantErr: "BGP",
+	}, {
+		name: "bad address",
+		top: &opb.Topology{Interfaces: []*opb.InterfaceConfig{{
+			Name: "intf1",
+			Ipv4: &opb.IpConfig{AddressCidr: "192.0.2.1"},
+		}}},
+		wantErr: "invalid address",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := otgTopology(test.top)
+			if diff := errdiff.Substring(err, test.wantErr); diff != "" {
+				t.Errorf("otgTopology() got unexpected error diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestOTGFlows(t *testing.T) {
+	flow := &opb.Flow{
+		Name:         "flow1",
+		SrcEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "intf1"}},
+		DstEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "intf2"}},
+		Headers: []*opb.Header{
+			{Type: &opb.Header_Eth{&opb.EthernetHeader{}}},
+			{Type: &opb.Header_Ipv4{&opb.Ipv4Header{
+				SrcAddr: &opb.AddressRange{Min: "192.0.2.1", Count: 1},
+				DstAddr: &opb.AddressRange{Min: "198.51.100.0", Step: "0.0.0.1", Count: 10},
+				Ttl:     64,
+			}}},
+			{Type: &opb.Header_Udp{&opb.UdpHeader{DstPort: &opb.UIntRange{Min: 1000, Max: 1003}}}},
+		},
+		FrameRate:    &opb.FrameRate{Type: &opb.FrameRate_Fps{1000}},
+		FrameSize:    &opb.FrameSize{Type: &opb.FrameSize_Fixed{128}},
+		Transmission: &opb.Transmission{Pattern: opb.Transmission_BURST, PacketsPerBurst: 100},
+	}
+	got, err := otgFlows([]*opb.Flow{flow})
+	if err != nil {
+		t.Fatalf("otgFlows() got error: %v", err)
+	}
+	want := []*otgFlow{{
+		Name: "flow1",
+		TxRx: &otgTxRx{Choice: "device", Device: &otgDevices{TxNames: []string{"intf1.ipv4"}, RxNames: []string{"intf2.ipv4"}}},
+		Packet: []*otgHeader{
+			{Choice: "ethernet", Ethernet: map[string]*otgPattern{}},
+			{Choice: "ipv4", IPv4: map[string]*otgPattern{
+				"src":          {Choice: "value", Value: "192.0.2.1"},
+				"dst":          {Choice: "increment", Increment: &otgIncrement{Start: "198.51.100.0", Step: "0.0.0.1", Count: 10}},
+				"time_to_live": {Choice: "value", Value: uint32(64)},
+			}},
+			{Choice: "udp", UDP: map[string]*otgPattern{
+				"dst_port": {Choice: "increment", Increment: &otgIncrement{Start: uint32(1000), Step: uint32(1), Count: 4}},
+			}},
+		},
+		Size:     &otgSize{Choice: "fixed", Fixed: 128},
+		Rate:     &otgRate{Choice: "pps", PPS: 1000},
+		Duration: &otgDuration{Choice: "fixed_packets", FixedPackets: &otgFixedPackets{Packets: 100}},
+		Metrics:  &otgFlowMetric{Enable: true},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("otgFlows() got unexpected diff (-want,+got): %s", diff)
+	}
+}
+
+type otgRequest struct {
+	method, path string
+	body         map[string]interface{}
+}
+
+func TestOTGATE(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []*otgRequest
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("ReadAll() got error: %v", err)
+		}
+		req := &otgRequest{method: r.Method, path: r.URL.Path}
+		if err := json.Unmarshal(data, &req.body); err != nil {
+			t.Errorf("Unmarshal() got error: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+		if r.URL.Path == "/control/state" && req.body["choice"] == "port" {
+			http.Error(w, `{"errors": ["link state not supported"]}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("SplitHostPort() got error: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("Atoi() got error: %v", err)
+	}
+
+	b, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ate := &reservation.ATE{&reservation.Dims{Name: "node1"}}
+	b.ate2Node[ate] = &kpb.Node{
+		Name: "node1",
+		Services: map[uint32]*kpb.Service{443: {
+			Name:      "https",
+			OutsideIp: host,
+			Outside:   uint32(port),
+		}},
+	}
+
+	if err := b.StartTraffic(ate, nil); err == nil {
+		t.Errorf("StartTraffic() before PushTopology got no error, want error")
+	}
+	if err := b.PushTopology(ate, otgTestTop); err != nil {
+		t.Fatalf("PushTopology() got error: %v", err)
+	}
+	if err := b.StartProtocols(ate); err != nil {
+		t.Fatalf("StartProtocols() got error: %v", err)
+	}
+	flow := &opb.Flow{
+		Name:         "flow1",
+		SrcEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "intf1"}},
+		DstEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "intf2"}},
+	}
+	if err := b.StartTraffic(ate, []*opb.Flow{flow}); err != nil {
+		t.Fatalf("StartTraffic() got error: %v", err)
+	}
+	if err := b.StopTraffic(ate); err != nil {
+		t.Fatalf("StopTraffic() got error: %v", err)
+	}
+	err = b.SetATEPortState(ate, "eth1", false)
+	if diff := errdiff.Substring(err, "link state not supported"); diff != "" {
+		t.Errorf("SetATEPortState() got unexpected error diff: %s", diff)
+	}
+
+	var got []string
+	for _, r := range reqs {
+		s := r.method + " " + r.path
+		if c, ok := r.body["choice"].(string); ok {
+			s += " " + c
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"POST /config",
+		"POST /control/state protocol",
+		"POST /config",
+		"POST /control/state protocol",
+		"POST /control/state traffic",
+		"POST /control/state traffic",
+		"POST /control/state port",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("OTG requests got unexpected diff (-want,+got): %s", diff)
+	}
+	if flows := reqs[2].body["flows"].([]interface{}); len(flows) != 1 {
+		t.Errorf("StartTraffic() config got %d flows, want 1", len(flows))
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The reserve command reserves a testbed on a KNE topology, prints the reserved
+// nodes and interfaces, and holds the reservation until it is interrupted or
+// the -run_time elapses. It accepts the same -testbed, -run_time, -wait_time
+// and -config flags as an Ondatra test that uses the KNE binding.
+package main
+
+import (
+	"flag"
+
+	log "github.com/golang/glog"
+	"github.com/openconfig/ondatra/internal/reservemain"
+
+	kinit "github.com/openconfig/ondatra/knebind/init"
+)
+
+func main() {
+	flag.Parse()
+	b, err := kinit.Init()
+	if err != nil {
+		log.Exitf("failed to create binding: %v", err)
+	}
+	if err := reservemain.Main(b); err != nil {
+		log.Exit(err)
+	}
+}
@@ -16,10 +16,13 @@ package knebind
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	log "github.com/golang/glog"
 	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/testbed"
 
 	kpb "github.com/google/kne/proto/topo"
 	opb "github.com/openconfig/ondatra/proto"
@@ -29,6 +32,14 @@ var ateTypes = map[kpb.Node_Type]bool{
 	kpb.Node_IXIA_TG: true,
 }
 
+// solve assigns the testbed devices to nodes of the KNE topology, and their
+// ports to node interfaces, such that every testbed link is a topology link.
+//
+// It searches with backtracking: the ports of a device are assigned right
+// after the device itself, and devices are ordered so that each is linked to
+// an earlier one where possible. The interface of a port whose peer port is
+// already assigned is then fixed by the topology link, so an assignment that
+// cannot satisfy a link is pruned as soon as the link is reached.
 func solve(tb *opb.Testbed, topo *kpb.Topology) (*assign, error) {
 	devs := append(append([]*opb.Device{}, tb.GetDuts()...), tb.GetAtes()...)
 	if numDevs, numNodes := len(devs), len(topo.GetNodes()); numDevs > numNodes {
@@ -42,32 +53,47 @@ func solve(tb *opb.Testbed, topo *kpb.Topology) (*assign, error) {
 	s := &solver{
 		testbed:    tb,
 		topology:   topo,
-		id2Dev:     make(map[string]*opb.Device),
-		dev2Ports:  make(map[*opb.Device]map[string]*opb.Port),
-		node2Intfs: make(map[*kpb.Node]map[string]*intf),
+		devs:       devs,
+		id2Port:    make(map[string]*opb.Port),
+		port2Dev:   make(map[*opb.Port]*opb.Device),
+		port2Peer:  make(map[*opb.Port]*opb.Port),
+		node2Intfs: make(map[*kpb.Node][]*intf),
 		intf2Intf:  make(map[*intf]*intf),
+		devNodes:   make(map[*opb.Device][]*kpb.Node),
+		portIntfs:  make(map[*opb.Device]map[*kpb.Node]map[*opb.Port][]*intf),
+		failDepth:  -1,
 	}
 
 	// Cache various info in the solver about the testbed and topology.
 	for _, dev := range devs {
-		s.id2Dev[dev.GetId()] = dev
-		ports := make(map[string]*opb.Port)
 		for _, port := range dev.GetPorts() {
-			ports[port.GetId()] = port
+			s.port2Dev[port] = dev
+			s.id2Port[fmt.Sprintf("%s:%s", dev.GetId(), port.GetId())] = port
+		}
+	}
+	for _, link := range tb.GetLinks() {
+		portA, portB := s.id2Port[link.GetA()], s.id2Port[link.GetB()]
+		if portA == nil || portB == nil {
+			return nil, errors.Errorf("Invalid testbed link %s<->%s", link.GetA(), link.GetB())
 		}
-		s.dev2Ports[dev] = ports
+		s.port2Peer[portA] = portB
+		s.port2Peer[portB] = portA
 	}
 	name2Node := make(map[string]*kpb.Node)
 	for _, node := range s.topology.GetNodes() {
 		name2Node[node.GetName()] = node
-		s.node2Intfs[node] = make(map[string]*intf)
 	}
+	name2Intf := make(map[*kpb.Node]map[string]*intf)
 	getIntf := func(nodeName, intfName string) *intf {
 		node := name2Node[nodeName]
-		i, ok := s.node2Intfs[node][intfName]
+		if name2Intf[node] == nil {
+			name2Intf[node] = make(map[string]*intf)
+		}
+		i, ok := name2Intf[node][intfName]
 		if !ok {
-			i = &intf{name: intfName}
-			s.node2Intfs[node][intfName] = i
+			i = &intf{name: intfName, node: node, speed: intfSpeed(node, intfName)}
+			name2Intf[node][intfName] = i
+			s.node2Intfs[node] = append(s.node2Intfs[node], i)
 		}
 		return i
 	}
@@ -77,6 +103,9 @@ func solve(tb *opb.Testbed, topo *kpb.Topology) (*assign, error) {
 		s.intf2Intf[intfA] = intfZ
 		s.intf2Intf[intfZ] = intfA
 	}
+	for _, intfs := range s.node2Intfs {
+		sort.Slice(intfs, func(i, j int) bool { return intfs[i].name < intfs[j].name })
+	}
 
 	return s.solve()
 }
@@ -87,7 +116,13 @@ type assign struct {
 }
 
 type intf struct {
-	name string
+	name  string
+	node  *kpb.Node
+	speed opb.Port_Speed
+}
+
+func (i *intf) String() string {
+	return fmt.Sprintf("%s:%s", i.node.GetName(), i.name)
 }
 
 func (a *assign) String() string {
@@ -104,99 +139,102 @@ func (a *assign) String() string {
 type solver struct {
 	testbed    *opb.Testbed
 	topology   *kpb.Topology
-	id2Dev     map[string]*opb.Device
-	dev2Ports  map[*opb.Device]map[string]*opb.Port
-	node2Intfs map[*kpb.Node]map[string]*intf
+	devs       []*opb.Device
+	id2Port    map[string]*opb.Port
+	port2Dev   map[*opb.Port]*opb.Device
+	port2Peer  map[*opb.Port]*opb.Port
+	node2Intfs map[*kpb.Node][]*intf
 	intf2Intf  map[*intf]*intf
+
+	// Candidate nodes of every device, and candidate interfaces of every port
+	// of a device on each of those nodes.
+	devNodes  map[*opb.Device][]*kpb.Node
+	portIntfs map[*opb.Device]map[*kpb.Node]map[*opb.Port][]*intf
+
+	// State of the search.
+	order     []*opb.Device
+	a         *assign
+	usedNodes map[*kpb.Node]bool
+	usedIntfs map[*intf]bool
+
+	// The reason the search failed at the greatest depth, which is reported
+	// when no assignment exists.
+	failure   string
+	failDepth int
 }
 
 func (s *solver) solve() (*assign, error) {
-	// Find all the matching device->node assignments, and
-	// for each of those, all the port->intf assignments.
-	dev2Node2Port2Intfs := make(map[*opb.Device]map[*kpb.Node]map[*opb.Port][]*intf)
 	for _, dut := range s.testbed.GetDuts() {
-		node2Port2Intfs, err := s.nodeMatches(dut, false)
-		if err != nil {
+		if err := s.nodeMatches(dut, false); err != nil {
 			return nil, err
 		}
-		dev2Node2Port2Intfs[dut] = node2Port2Intfs
 	}
 	for _, ate := range s.testbed.GetAtes() {
-		node2Port2Intfs, err := s.nodeMatches(ate, true)
-		if err != nil {
+		if err := s.nodeMatches(ate, true); err != nil {
 			return nil, err
 		}
-		dev2Node2Port2Intfs[ate] = node2Port2Intfs
 	}
-
-	// Iterate over each of the possible testbed->topology combinations.
-	dev2Nodes := make(map[interface{}][]interface{})
-	for dev, node2Port2Intfs := range dev2Node2Port2Intfs {
-		for node := range node2Port2Intfs {
-			dev2Nodes[dev] = append(dev2Nodes[dev], node)
-		}
+	// Give a more specific error message for the case when we don't need to
+	// even consider the links to determine that there are no matching topologies.
+	if !s.nodesMatch() {
+		return nil, errors.Errorf("No combination of nodes in the KNE topology matches the testbed, irrespective of links")
 	}
-	dev2NodeChan := genCombos(dev2Nodes)
-	var hasNodeCombo bool
-	for dev2Node := range dev2NodeChan {
-		hasNodeCombo = true
-		port2Intfs := make(map[interface{}][]interface{})
-		for dut, node := range dev2Node {
-			for port, intfs := range dev2Node2Port2Intfs[dut.(*opb.Device)][node.(*kpb.Node)] {
-				for _, i := range intfs {
-					port2Intfs[port] = append(port2Intfs[port], i)
-				}
-			}
-		}
-		port2IntfChan := genCombos(port2Intfs)
-		for port2Intf := range port2IntfChan {
-			if a := newAssign(dev2Node, port2Intf); s.linksMatch(a) {
-				// TODO: When solver is rewritten, signal the gorouting
-				// channel to exit early here and avoid leaving the goroutine hanging.
-				// Not disastrous but ideally the goroutines would terminate here.
-				return a, nil
-			}
+	for _, link := range s.testbed.GetLinks() {
+		if !s.linkMatches(link) {
+			return nil, errors.Errorf("No KNE topology matches the testbed: no pair of nodes has a direct link for %s<->%s", link.GetA(), link.GetB())
 		}
 	}
-	// Give a more specific error message for the case when we didn't need to even
-	// consider the links to determine that there were no matching topologies.
-	if !hasNodeCombo {
-		return nil, errors.Errorf("No combination of nodes in the KNE topology matches the testbed, irrespective of links")
+	s.orderDevs()
+	s.a = &assign{
+		dev2Node:  make(map[*opb.Device]*kpb.Node),
+		port2Intf: make(map[*opb.Port]*intf),
+	}
+	s.usedNodes = make(map[*kpb.Node]bool)
+	s.usedIntfs = make(map[*intf]bool)
+	if !s.assignDev(0) {
+		return nil, errors.Errorf("No KNE topology matches the testbed: %s", s.failure)
 	}
-	return nil, errors.Errorf("No KNE topology matches the testbed")
+	log.V(1).Infof("Found assignment:\n%v", s.a)
+	return s.a, nil
 }
 
-func (s *solver) nodeMatches(dev *opb.Device, isATE bool) (map[*kpb.Node]map[*opb.Port][]*intf, error) {
+func (s *solver) nodeMatches(dev *opb.Device, isATE bool) error {
+	m, err := newDevMatcher(dev)
+	if err != nil {
+		return err
+	}
 	node2Port2Intfs := make(map[*kpb.Node]map[*opb.Port][]*intf)
 	for _, node := range s.topology.GetNodes() {
 		if isATE != ateTypes[node.GetType()] {
 			continue
 		}
-		match, port2Intfs := s.devMatch(dev, node)
+		match, port2Intfs := s.devMatch(dev, node, m)
 		if match {
 			log.V(1).Infof("Found match: testbed device %q -> KNE topology node %q", dev.GetId(), node.GetName())
+			s.devNodes[dev] = append(s.devNodes[dev], node)
 			node2Port2Intfs[node] = port2Intfs
 		}
 	}
 	if len(node2Port2Intfs) == 0 {
-		return nil, errors.Errorf("No node in KNE topology to match testbed device %q", dev.GetId())
+		return errors.Errorf("No node in KNE topology to match testbed device %q", dev.GetId())
 	}
-	return node2Port2Intfs, nil
+	s.portIntfs[dev] = node2Port2Intfs
+	return nil
 }
 
-func (s *solver) devMatch(dev *opb.Device, node *kpb.Node) (bool, map[*opb.Port][]*intf) {
-	if dev.GetHardwareModel() != "" && dev.GetHardwareModel() != hardwareModel(node) {
-		return false, nil
-	}
-	if dev.GetSoftwareVersion() != "" && dev.GetSoftwareVersion() != softwareVersion(node) {
+func (s *solver) devMatch(dev *opb.Device, node *kpb.Node, m *devMatcher) (bool, map[*opb.Port][]*intf) {
+	if !m.model(hardwareModel(node)) || !m.version(softwareVersion(node)) {
 		return false, nil
 	}
 	if v := dev.GetVendor(); v != opb.Device_UNKNOWN && v != type2VendorMap[node.GetType()] {
 		return false, nil
 	}
-	log.V(1).Infof("Found node match: %q", dev.GetId())
+	for key, match := range m.dims {
+		if label, ok := node.GetLabels()[key]; !ok || !match(label) {
+			return false, nil
+		}
+	}
 	intfs := s.node2Intfs[node]
-	log.V(1).Infof("Interfaces: %v", intfs)
 	// If the device needs more ports than the node, this node cannot match.
 	if len(dev.GetPorts()) > len(intfs) {
 		return false, nil
@@ -206,11 +244,10 @@ func (s *solver) devMatch(dev *opb.Device, node *kpb.Node) (bool, map[*opb.Port]
 		var infs []*intf
 		for _, intf := range intfs {
 			if s.portMatch(port, intf) {
-				log.V(1).Infof("Matched Port: %q %q", port.GetId(), intf.name)
 				infs = append(infs, intf)
 			}
 		}
-		if len(intfs) == 0 {
+		if len(infs) == 0 {
 			return false, nil
 		}
 		port2Infs[port] = infs
@@ -219,88 +256,264 @@ func (s *solver) devMatch(dev *opb.Device, node *kpb.Node) (bool, map[*opb.Port]
 }
 
 func (s *solver) portMatch(port *opb.Port, intf *intf) bool {
-	// TODO: When solver is rewritten, support more generic port matching.
-	if port.GetSpeed() != opb.Port_S_UNKNOWN {
-		return false
+	return port.GetSpeed() == opb.Port_S_UNKNOWN || port.GetSpeed() == intf.speed
+}
+
+// devMatcher matches the criteria of a testbed device against node values.
+type devMatcher struct {
+	model, version func(string) bool
+	dims           map[string]func(string) bool
+}
+
+func newDevMatcher(dev *opb.Device) (*devMatcher, error) {
+	m := &devMatcher{dims: make(map[string]func(string) bool)}
+	var err error
+	if m.model, err = testbed.Matcher(dev.GetHardwareModel()); err != nil {
+		return nil, errors.Wrapf(err, "invalid hardware model of testbed device %q", dev.GetId())
 	}
-	return true
+	if m.version, err = testbed.Matcher(dev.GetSoftwareVersion()); err != nil {
+		return nil, errors.Wrapf(err, "invalid software version of testbed device %q", dev.GetId())
+	}
+	for key, val := range dev.GetExtraDimensions() {
+		if m.dims[key], err = testbed.Matcher(val); err != nil {
+			return nil, errors.Wrapf(err, "invalid extra dimension %q of testbed device %q", key, dev.GetId())
+		}
+	}
+	return m, nil
 }
 
-func (s *solver) linksMatch(a *assign) bool {
-	getIntf := func(tbLink string) *intf {
-		parts := strings.Split(tbLink, ":")
-		dut := s.id2Dev[parts[0]]
-		port := s.dev2Ports[dut][parts[1]]
-		return a.port2Intf[port]
+// nodesMatch returns whether every device can be assigned a distinct
+// candidate node, irrespective of links, by finding a maximum matching of
+// devices to nodes with augmenting paths.
+func (s *solver) nodesMatch() bool {
+	node2Dev := make(map[*kpb.Node]*opb.Device)
+	var augment func(dev *opb.Device, seen map[*kpb.Node]bool) bool
+	augment = func(dev *opb.Device, seen map[*kpb.Node]bool) bool {
+		for _, node := range s.devNodes[dev] {
+			if seen[node] {
+				continue
+			}
+			seen[node] = true
+			if other, ok := node2Dev[node]; !ok || augment(other, seen) {
+				node2Dev[node] = dev
+				return true
+			}
+		}
+		return false
 	}
-	for _, link := range s.testbed.GetLinks() {
-		intfA := getIntf(link.GetA())
-		intfB := getIntf(link.GetB())
-		if s.intf2Intf[intfA] != intfB {
+	for _, dev := range s.devs {
+		if !augment(dev, make(map[*kpb.Node]bool)) {
 			return false
 		}
 	}
 	return true
 }
 
-func hardwareModel(node *kpb.Node) string {
-	return kpb.Node_Type_name[int32(node.GetType())]
+// linkMatches returns whether any topology link connects candidate
+// interfaces of the two ports of the testbed link, on candidate nodes.
+func (s *solver) linkMatches(link *opb.Link) bool {
+	portA, portB := s.id2Port[link.GetA()], s.id2Port[link.GetB()]
+	devA, devB := s.port2Dev[portA], s.port2Dev[portB]
+	for _, nodeA := range s.devNodes[devA] {
+		for _, intfA := range s.portIntfs[devA][nodeA][portA] {
+			intfB := s.intf2Intf[intfA]
+			if intfB == nil || (devA == devB) != (intfB.node == nodeA) {
+				continue
+			}
+			if hasIntf(s.portIntfs[devB][intfB.node][portB], intfB) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func softwareVersion(node *kpb.Node) string {
-	return hardwareModel(node)
+// orderDevs orders the devices for the search: starting from the device with
+// the fewest candidate nodes, the devices linked to it follow breadth first.
+func (s *solver) orderDevs() {
+	devs := append([]*opb.Device{}, s.devs...)
+	sort.SliceStable(devs, func(i, j int) bool {
+		return len(s.devNodes[devs[i]]) < len(s.devNodes[devs[j]])
+	})
+	seen := make(map[*opb.Device]bool)
+	for _, first := range devs {
+		if seen[first] {
+			continue
+		}
+		seen[first] = true
+		queue := []*opb.Device{first}
+		for len(queue) > 0 {
+			dev := queue[0]
+			queue = queue[1:]
+			s.order = append(s.order, dev)
+			for _, port := range dev.GetPorts() {
+				if peer, ok := s.port2Peer[port]; ok && !seen[s.port2Dev[peer]] {
+					seen[s.port2Dev[peer]] = true
+					queue = append(queue, s.port2Dev[peer])
+				}
+			}
+		}
+	}
 }
 
-// genCombos yields every key->value mapping, where no two keys map to the same
-// value, given a map of keys to their possible values.
-func genCombos(m map[interface{}][]interface{}) <-chan map[interface{}]interface{} {
-	var keys []interface{}
-	for k := range m {
-		keys = append(keys, k)
-	}
-	ch := make(chan map[interface{}]interface{})
-	go func() {
-		defer close(ch)
-		genRecurse(m, keys, make(map[interface{}]interface{}), make(map[interface{}]bool), ch)
-	}()
-	return ch
+// assignDev assigns the ith device in the search order and all that follow,
+// returning whether it succeeded.
+func (s *solver) assignDev(i int) bool {
+	if i == len(s.order) {
+		return true
+	}
+	dev := s.order[i]
+	var tried bool
+	for _, node := range s.devNodes[dev] {
+		if s.usedNodes[node] {
+			continue
+		}
+		tried = true
+		s.a.dev2Node[dev] = node
+		s.usedNodes[node] = true
+		if s.assignPort(i, 0) {
+			return true
+		}
+		delete(s.a.dev2Node, dev)
+		delete(s.usedNodes, node)
+	}
+	if !tried {
+		s.fail("no free node for testbed device %q", dev.GetId())
+	}
+	return false
 }
 
-func genRecurse(
-	m map[interface{}][]interface{},
-	keys []interface{},
-	res map[interface{}]interface{},
-	used map[interface{}]bool,
-	ch chan<- map[interface{}]interface{}) {
-	if len(keys) == 0 {
-		copy := make(map[interface{}]interface{})
-		for k, v := range res {
-			copy[k] = v
+// assignPort assigns the jth port of the ith device in the search order, then
+// the ports and devices that follow, returning whether it succeeded.
+func (s *solver) assignPort(i, j int) bool {
+	dev := s.order[i]
+	if j == len(dev.GetPorts()) {
+		return s.assignDev(i + 1)
+	}
+	port := dev.GetPorts()[j]
+	node := s.a.dev2Node[dev]
+	var tried bool
+	for _, intf := range s.portIntfs[dev][node][port] {
+		if s.usedIntfs[intf] || !s.peerMatch(port, intf) {
+			continue
 		}
-		ch <- copy
-		return
-	}
-	first := keys[0]
-	for _, i := range m[first] {
-		if !used[i] {
-			res[first] = i
-			used[i] = true
-			genRecurse(m, keys[1:], res, used, ch)
-			delete(used, i)
+		tried = true
+		s.a.port2Intf[port] = intf
+		s.usedIntfs[intf] = true
+		if s.assignPort(i, j+1) {
+			return true
 		}
+		delete(s.a.port2Intf, port)
+		delete(s.usedIntfs, intf)
 	}
+	if tried {
+		return false
+	}
+	peer, ok := s.port2Peer[port]
+	switch {
+	case !ok:
+		s.fail("not enough free interfaces on node %q for %s", node.GetName(), s.portName(port))
+	case s.a.port2Intf[peer] != nil:
+		s.fail("no direct link between nodes %q and %q for %s<->%s",
+			node.GetName(), s.a.port2Intf[peer].node.GetName(), s.portName(port), s.portName(peer))
+	default:
+		s.fail("no free interface of node %q links to a candidate node for %s<->%s",
+			node.GetName(), s.portName(port), s.portName(peer))
+	}
+	return false
 }
 
-func newAssign(dev2Node, port2Intf map[interface{}]interface{}) *assign {
-	a := &assign{
-		dev2Node:  make(map[*opb.Device]*kpb.Node),
-		port2Intf: make(map[*opb.Port]*intf),
+// peerMatch returns whether assigning the interface to the port is consistent
+// with the link to its peer port, if any. If the peer port is already
+// assigned, the interfaces must be linked; otherwise, the interface must link
+// to a free candidate interface of the peer port.
+func (s *solver) peerMatch(port *opb.Port, intf *intf) bool {
+	peer, ok := s.port2Peer[port]
+	if !ok {
+		return true
+	}
+	peerIntf := s.intf2Intf[intf]
+	if peerIntf == nil {
+		return false
+	}
+	if assigned, ok := s.a.port2Intf[peer]; ok {
+		return peerIntf == assigned
+	}
+	if s.usedIntfs[peerIntf] {
+		return false
+	}
+	peerDev := s.port2Dev[peer]
+	if node, ok := s.a.dev2Node[peerDev]; ok {
+		if node != peerIntf.node {
+			return false
+		}
+	} else if s.usedNodes[peerIntf.node] {
+		return false
+	}
+	return hasIntf(s.portIntfs[peerDev][peerIntf.node][peer], peerIntf)
+}
+
+// fail records why the search failed, if it got deeper than any prior failure.
+func (s *solver) fail(format string, args ...interface{}) {
+	if depth := len(s.a.dev2Node) + len(s.a.port2Intf); depth > s.failDepth {
+		s.failDepth = depth
+		s.failure = fmt.Sprintf(format, args...)
+	}
+}
+
+func (s *solver) portName(port *opb.Port) string {
+	return fmt.Sprintf("%s:%s", s.port2Dev[port].GetId(), port.GetId())
+}
+
+func hasIntf(intfs []*intf, i *intf) bool {
+	for _, in := range intfs {
+		if in == i {
+			return true
+		}
+	}
+	return false
+}
+
+// hardwareModel returns the model of the node, or else its type name.
+func hardwareModel(node *kpb.Node) string {
+	if m := node.GetModel(); m != "" {
+		return m
+	}
+	return kpb.Node_Type_name[int32(node.GetType())]
+}
+
+// softwareVersion returns the version of the node, or else the tag of its
+// container image, or else its type name.
+func softwareVersion(node *kpb.Node) string {
+	if v := node.GetVersion(); v != "" {
+		return v
+	}
+	image := node.GetConfig().GetImage()
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		return image[i+1:]
+	}
+	return kpb.Node_Type_name[int32(node.GetType())]
+}
+
+// speedLabel is the key of a node label that declares the speed of all the
+// node interfaces in Gbps, e.g. "speed": "100". A label keyed by the key, a
+// slash and an interface name overrides the speed of that interface, e.g.
+// "speed/eth1": "400".
+const speedLabel = "speed"
+
+// intfSpeed returns the speed of a node interface declared by the node labels.
+func intfSpeed(node *kpb.Node, name string) opb.Port_Speed {
+	label, ok := node.GetLabels()[speedLabel+"/"+name]
+	if !ok {
+		label, ok = node.GetLabels()[speedLabel]
 	}
-	for d, n := range dev2Node {
-		a.dev2Node[d.(*opb.Device)] = n.(*kpb.Node)
+	if !ok {
+		return opb.Port_S_UNKNOWN
 	}
-	for p, i := range port2Intf {
-		a.port2Intf[p.(*opb.Port)] = i.(*intf)
+	gbps, err := strconv.Atoi(label)
+	if _, known := opb.Port_Speed_name[int32(gbps)]; err != nil || !known {
+		log.Warningf("Ignoring invalid speed %q of interface %q of node %q", label, name, node.GetName())
+		return opb.Port_S_UNKNOWN
 	}
-	return a
+	return opb.Port_Speed(gbps)
 }
@@ -0,0 +1,243 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knebind
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openconfig/gnmi/errdiff"
+
+	kpb "github.com/google/kne/proto/topo"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+// ring returns a testbed and a topology of n devices and nodes linked in a
+// ring, where device i is linked to device i+1 via its port2 and port1.
+func ring(n int) (*opb.Testbed, *kpb.Topology) {
+	tb := &opb.Testbed{}
+	topo := &kpb.Topology{}
+	for i := 1; i <= n; i++ {
+		next := i%n + 1
+		tb.Duts = append(tb.Duts, &opb.Device{
+			Id:     fmt.Sprintf("dut%d", i),
+			Vendor: opb.Device_ARISTA,
+			Ports:  []*opb.Port{{Id: "port1"}, {Id: "port2"}},
+		})
+		tb.Links = append(tb.Links, &opb.Link{
+			A: fmt.Sprintf("dut%d:port2", i),
+			B: fmt.Sprintf("dut%d:port1", next),
+		})
+		topo.Nodes = append(topo.Nodes, &kpb.Node{
+			Name: fmt.Sprintf("node%d", i),
+			Type: kpb.Node_ARISTA_CEOS,
+		})
+		topo.Links = append(topo.Links, &kpb.Link{
+			ANode: fmt.Sprintf("node%d", i), AInt: "eth2",
+			ZNode: fmt.Sprintf("node%d", next), ZInt: "eth1",
+		})
+	}
+	return tb, topo
+}
+
+func TestSolveRing(t *testing.T) {
+	tb, topo := ring(12)
+	a, err := solve(tb, topo)
+	if err != nil {
+		t.Fatalf("solve() got error: %v", err)
+	}
+	topoLinks := make(map[string]bool)
+	for _, l := range topo.GetLinks() {
+		topoLinks[fmt.Sprintf("%s:%s<->%s:%s", l.GetANode(), l.GetAInt(), l.GetZNode(), l.GetZInt())] = true
+		topoLinks[fmt.Sprintf("%s:%s<->%s:%s", l.GetZNode(), l.GetZInt(), l.GetANode(), l.GetAInt())] = true
+	}
+	for _, link := range tb.GetLinks() {
+		var intfs []*intf
+		for _, end := range []string{link.GetA(), link.GetB()} {
+			for _, dev := range tb.GetDuts() {
+				for _, port := range dev.GetPorts() {
+					if fmt.Sprintf("%s:%s", dev.GetId(), port.GetId()) == end {
+						intfs = append(intfs, a.port2Intf[port])
+					}
+				}
+			}
+		}
+		if len(intfs) != 2 || intfs[0] == nil || intfs[1] == nil {
+			t.Fatalf("solve() did not assign ports of link %s<->%s", link.GetA(), link.GetB())
+		}
+		if s := fmt.Sprintf("%v<->%v", intfs[0], intfs[1]); !topoLinks[s] {
+			t.Errorf("solve() assigned link %s<->%s to %v<->%v, which are not linked", link.GetA(), link.GetB(), intfs[0], intfs[1])
+		}
+	}
+}
+
+func TestDevMatch(t *testing.T) {
+	node := &kpb.Node{
+		Name:   "node1",
+		Type:   kpb.Node_ARISTA_CEOS,
+		Config: &kpb.Config{Image: "registry:5000/ceos:4.26.1F"},
+		Labels: map[string]string{"role": "spine", "speed": "100", "speed/eth2": "400"},
+	}
+	topo := &kpb.Topology{
+		Nodes: []*kpb.Node{node},
+		Links: []*kpb.Link{{ANode: "node1", AInt: "eth1", ZNode: "node1", ZInt: "eth2"}},
+	}
+	tests := []struct {
+		desc      string
+		dev       *opb.Device
+		wantMatch bool
+	}{{
+		desc:      "no criteria",
+		dev:       &opb.Device{Id: "dut"},
+		wantMatch: true,
+	}, {
+		desc:      "model",
+		dev:       &opb.Device{Id: "dut", HardwareModel: "ARISTA_CEOS"},
+		wantMatch: true,
+	}, {
+		desc: "wrong model",
+		dev:  &opb.Device{Id: "dut", HardwareModel: "ARISTA"},
+	}, {
+		desc:      "model regex",
+		dev:       &opb.Device{Id: "dut", HardwareModel: "regex:ARISTA_.*"},
+		wantMatch: true,
+	}, {
+		desc:      "version from image",
+		dev:       &opb.Device{Id: "dut", SoftwareVersion: "4.26.1F"},
+		wantMatch: true,
+	}, {
+		desc:      "version regex",
+		dev:       &opb.Device{Id: "dut", SoftwareVersion: `regex:4\.26\..*`},
+		wantMatch: true,
+	}, {
+		desc: "version regex not fully matched",
+		dev:  &opb.Device{Id: "dut", SoftwareVersion: `regex:4\.26`},
+	}, {
+		desc:      "extra dimension",
+		dev:       &opb.Device{Id: "dut", ExtraDimensions: map[string]string{"role": "spine"}},
+		wantMatch: true,
+	}, {
+		desc:      "extra dimension regex",
+		dev:       &opb.Device{Id: "dut", ExtraDimensions: map[string]string{"role": "regex:spine|leaf"}},
+		wantMatch: true,
+	}, {
+		desc: "wrong extra dimension",
+		dev:  &opb.Device{Id: "dut", ExtraDimensions: map[string]string{"role": "leaf"}},
+	}, {
+		desc: "missing extra dimension",
+		dev:  &opb.Device{Id: "dut", ExtraDimensions: map[string]string{"rack": "1"}},
+	}, {
+		desc:      "port speeds",
+		dev:       &opb.Device{Id: "dut", Ports: []*opb.Port{{Id: "port1", Speed: opb.Port_S_100GB}, {Id: "port2", Speed: opb.Port_S_400GB}}},
+		wantMatch: true,
+	}, {
+		desc: "wrong port speed",
+		dev:  &opb.Device{Id: "dut", Ports: []*opb.Port{{Id: "port1", Speed: opb.Port_S_10GB}}},
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			a, err := solve(&opb.Testbed{Duts: []*opb.Device{test.dev}}, topo)
+			if gotMatch := err == nil; gotMatch != test.wantMatch {
+				t.Fatalf("solve() got match %v, want %v; error: %v", gotMatch, test.wantMatch, err)
+			}
+			if err != nil {
+				return
+			}
+			for _, p := range test.dev.GetPorts() {
+				if got, want := a.port2Intf[p].speed, p.GetSpeed(); got != want {
+					t.Errorf("solve() assigned port %s an interface of speed %v, want %v", p.GetId(), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	// A ring testbed cannot be matched to a topology without a ring of all the
+	// nodes, although every single link can be.
+	ringTB, ringTopo := ring(12)
+	noRingTopo := &kpb.Topology{Nodes: ringTopo.GetNodes(), Links: ringTopo.GetLinks()[:11]}
+	noRingTopo.Links = append(noRingTopo.Links,
+		&kpb.Link{ANode: "node12", AInt: "eth2", ZNode: "node11", ZInt: "eth3"},
+		&kpb.Link{ANode: "node1", AInt: "eth1", ZNode: "node11", ZInt: "eth4"})
+
+	tests := []struct {
+		name    string
+		tb      *opb.Testbed
+		topo    *kpb.Topology
+		wantErr string
+	}{{
+		name:    "no ring",
+		tb:      ringTB,
+		topo:    noRingTopo,
+		wantErr: "No KNE topology matches the testbed: no",
+	}, {
+		name: "no direct link",
+		tb: &opb.Testbed{
+			Duts: []*opb.Device{{
+				Id:     "dut",
+				Vendor: opb.Device_ARISTA,
+				Ports:  []*opb.Port{{Id: "port1"}},
+			}},
+			Ates: []*opb.Device{{
+				Id:    "ate",
+				Ports: []*opb.Port{{Id: "port1"}},
+			}},
+			Links: []*opb.Link{{A: "dut:port1", B: "ate:port1"}},
+		},
+		topo: &kpb.Topology{
+			Nodes: []*kpb.Node{
+				{Name: "node1", Type: kpb.Node_ARISTA_CEOS},
+				{Name: "node2", Type: kpb.Node_CISCO_CXR},
+				{Name: "node3", Type: kpb.Node_IXIA_TG},
+			},
+			Links: []*kpb.Link{
+				{ANode: "node1", AInt: "eth1", ZNode: "node2", ZInt: "eth2"},
+				{ANode: "node2", AInt: "eth1", ZNode: "node3", ZInt: "eth1"},
+			},
+		},
+		wantErr: "no pair of nodes has a direct link for dut:port1<->ate:port1",
+	}, {
+		name: "port speed",
+		tb: &opb.Testbed{
+			Duts: []*opb.Device{{
+				Id:    "dut",
+				Ports: []*opb.Port{{Id: "port1", Speed: opb.Port_S_100GB}},
+			}},
+		},
+		topo: &kpb.Topology{
+			Nodes: []*kpb.Node{{Name: "node1", Type: kpb.Node_ARISTA_CEOS}},
+			Links: []*kpb.Link{{ANode: "node1", AInt: "eth1", ZNode: "node1", ZInt: "eth2"}},
+		},
+		wantErr: `No node in KNE topology to match testbed device "dut"`,
+	}, {
+		name: "invalid regex",
+		tb: &opb.Testbed{
+			Duts: []*opb.Device{{Id: "dut", SoftwareVersion: "regex:4.(26"}},
+		},
+		topo: &kpb.Topology{
+			Nodes: []*kpb.Node{{Name: "node1", Type: kpb.Node_ARISTA_CEOS}},
+		},
+		wantErr: "invalid software version",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := solve(test.tb, test.topo)
+			if diff := errdiff.Substring(err, test.wantErr); diff != "" {
+				t.Errorf("solve() got unexpected error diff: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knebind
+
+import (
+	"bytes"
+	"golang.org/x/net/context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+)
+
+const (
+	passwordEnv    = "KNEBIND_SSH_PASSWORD"
+	askPassContent = "#!/bin/sh\necho \"$" + passwordEnv + "\"\n"
+)
+
+var execCommand = exec.CommandContext // to be stubbed out by tests
+
+// sshClient is a StreamClient for a KNE node, implemented by running the ssh
+// binary. The password is supplied to ssh by an askpass script, which reads it
+// from the environment of the ssh process.
+type sshClient struct {
+	cfg        *Config
+	host, port string
+	askPassDir string
+
+	session *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	stderr  io.ReadCloser
+}
+
+var _ binding.StreamClient = &sshClient{}
+
+// newSSHClient starts an interactive SSH session to the specified address.
+func newSSHClient(cfg *Config, addr string) (_ *sshClient, rerr error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid SSH address %q", addr)
+	}
+	dir, err := ioutil.TempDir("", "knebind-ssh")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating askpass dir")
+	}
+	defer func() {
+		if rerr != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	if err := ioutil.WriteFile(filepath.Join(dir, "askpass"), []byte(askPassContent), 0700); err != nil {
+		return nil, errors.Wrap(err, "error writing askpass script")
+	}
+	c := &sshClient{cfg: cfg, host: host, port: port, askPassDir: dir}
+	c.session = c.command(context.Background(), true)
+	if c.stdin, err = c.session.StdinPipe(); err != nil {
+		return nil, errors.Wrap(err, "error creating SSH stdin")
+	}
+	if c.stdout, err = c.session.StdoutPipe(); err != nil {
+		return nil, errors.Wrap(err, "error creating SSH stdout")
+	}
+	if c.stderr, err = c.session.StderrPipe(); err != nil {
+		return nil, errors.Wrap(err, "error creating SSH stderr")
+	}
+	if err := c.session.Start(); err != nil {
+		return nil, errors.Wrapf(err, "error executing command %v", c.session)
+	}
+	return c, nil
+}
+
+// command returns an ssh command to the node, which runs the remote command
+// if one is specified and otherwise starts an interactive session.
+func (c *sshClient) command(ctx context.Context, tty bool, remote ...string) *exec.Cmd {
+	args := []string{
+		"-p", c.port,
+		"-l", c.cfg.Username,
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+	}
+	if tty {
+		args = append(args, "-tt")
+	}
+	args = append(append(args, c.host), remote...)
+	cmd := execCommand(ctx, c.cfg.SSHPath, args...)
+	cmd.Env = append(os.Environ(),
+		"SSH_ASKPASS="+filepath.Join(c.askPassDir, "askpass"),
+		"SSH_ASKPASS_REQUIRE=force",
+		"DISPLAY=:0",
+		passwordEnv+"="+c.cfg.Password)
+	return cmd
+}
+
+// SendCommand runs the command in a separate SSH session and returns its output.
+func (c *sshClient) SendCommand(ctx context.Context, command string) (string, error) {
+	cmd := c.command(ctx, false, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "error running command %q on %s: %s", command, c.host, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func (c *sshClient) Stdin() io.WriteCloser {
+	return c.stdin
+}
+
+func (c *sshClient) Stdout() io.ReadCloser {
+	return c.stdout
+}
+
+func (c *sshClient) Stderr() io.ReadCloser {
+	return c.stderr
+}
+
+// Close ends the interactive SSH session.
+func (c *sshClient) Close() error {
+	defer os.RemoveAll(c.askPassDir)
+	c.stdin.Close()
+	if err := c.session.Process.Kill(); err != nil {
+		return errors.Wrap(err, "error closing SSH session")
+	}
+	c.session.Wait()
+	return nil
+}
+
+func (c *sshClient) String() string {
+	return fmt.Sprintf("SSH session %s@%s:%s", c.cfg.Username, c.host, c.port)
+}
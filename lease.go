@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ondatra
+
+import (
+	"testing"
+
+	"github.com/openconfig/ondatra/internal/lease"
+	"github.com/openconfig/ondatra/internal/report"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+)
+
+// Lease marks the test as parallel and leases the testbed devices with the
+// specified IDs exclusively to the test, blocking until they are all free.
+// The devices are returned when the test and all its subtests complete.
+//
+// Tests that lease disjoint sets of devices run concurrently, up to the limit
+// set by the -test.parallel flag. Once a test has leased devices, calls to DUT,
+// ATE, DUTs and ATEs within it and its subtests can only return leased devices,
+// and no other test can act on the leased devices or use the clients that
+// Ondatra caches for them. Subtests share the lease of their test, so they
+// cannot call Lease and should not run in parallel with each other.
+func Lease(t *testing.T, ids ...string) {
+	t.Helper()
+	devs := checkLeasable(t, ids)
+	t.Parallel()
+	lease.Acquire(t.Name(), devs)
+	t.Logf("Leased devices %v", ids)
+	t.Cleanup(func() {
+		lease.Release(t.Name(), devs)
+	})
+}
+
+// checkLeasable fails the test if it cannot lease the devices, and otherwise
+// returns them.
+func checkLeasable(t testing.TB, ids []string) []reservation.Device {
+	t.Helper()
+	if _, ok := lease.Leased(t.Name()); ok {
+		report.Fatalf(t, usererr.New("test %s already shares the lease of its parent test", t.Name()), "Lease(t, %v)", ids)
+	}
+	res := checkRes(t)
+	var devs []reservation.Device
+	for _, id := range ids {
+		if d, err := res.DUT(id); err == nil {
+			devs = append(devs, d)
+			continue
+		}
+		if a, err := res.ATE(id); err == nil {
+			devs = append(devs, a)
+			continue
+		}
+		report.Fatalf(t, usererr.New("no device with ID %q in the testbed", id), "Lease(t, %v)", ids)
+	}
+	return devs
+}
+
+// checkLeased fails the test if it may not use the device with the ID, because
+// it has leased devices but not this one or because another test leased it.
+func checkLeased(t testing.TB, id string, dev reservation.Device) {
+	t.Helper()
+	if err := lease.Check(t.Name(), dev); err != nil {
+		report.Fatalf(t, usererr.Wrap(err), "cannot use device %q", id)
+	}
+}
+
+// leasedFilter returns a func that reports whether a device may be used by the
+// test, which is any device not leased by another test if the test has not
+// leased devices.
+func leasedFilter(t testing.TB) func(dev reservation.Device) bool {
+	name := t.Name()
+	return func(dev reservation.Device) bool {
+		return lease.Check(name, dev) == nil
+	}
+}
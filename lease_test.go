@@ -0,0 +1,143 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ondatra
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openconfig/ondatra/internal/lease"
+	"github.com/openconfig/ondatra/internal/report"
+	"github.com/openconfig/ondatra/negtest"
+)
+
+func TestLease(t *testing.T) {
+	initFakeBinding(t)
+	reserveFakeTestbed(t)
+	defer release()
+
+	var (
+		mu      sync.Mutex
+		holders = make(map[string]int)
+		maxHeld = make(map[string]int)
+	)
+	hold := func(id string) {
+		mu.Lock()
+		holders[id]++
+		if holders[id] > maxHeld[id] {
+			maxHeld[id] = holders[id]
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		holders[id]--
+		mu.Unlock()
+	}
+
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"a", "b", "c"} {
+			t.Run(name, func(t *testing.T) {
+				Lease(t, "dut", "ate")
+				hold("dut")
+			})
+		}
+		t.Run("cisco", func(t *testing.T) {
+			Lease(t, "dut_cisco")
+			hold("dut_cisco")
+			if got := DUTs(t); len(got) != 1 || got["dut_cisco"] == nil {
+				t.Errorf("DUTs() got %v, want only dut_cisco", got)
+			}
+			if got := ATEs(t); len(got) != 0 {
+				t.Errorf("ATEs() got %v, want none", got)
+			}
+			msg := negtest.ExpectFatal(t, func(t testing.TB) {
+				DUT(t, "dut")
+			})
+			if !strings.Contains(msg, "not leased") {
+				t.Errorf("DUT() of unleased device failed with message %q, want %q", msg, "not leased")
+			}
+		})
+		t.Run("nested", func(t *testing.T) {
+			Lease(t, "dut_juniper", "ate")
+			t.Run("sub", func(t *testing.T) {
+				if got := DUTs(t); len(got) != 1 || got["dut_juniper"] == nil {
+					t.Errorf("DUTs() in subtest got %v, want only dut_juniper", got)
+				}
+				msg := negtest.ExpectFatal(t, func(t testing.TB) {
+					checkLeasable(t, []string{"dut_juniper"})
+				})
+				if !strings.Contains(msg, "shares the lease") {
+					t.Errorf("Lease() in subtest failed with message %q, want %q", msg, "shares the lease")
+				}
+			})
+		})
+	})
+
+	if got := maxHeld["dut"]; got != 1 {
+		t.Errorf("dut was held by %d tests at once, want 1", got)
+	}
+	if n := lease.Held(); n != 0 {
+		t.Errorf("%d leases still held after tests completed", n)
+	}
+}
+
+func TestLeaseUnknownDevice(t *testing.T) {
+	initFakeBinding(t)
+	reserveFakeTestbed(t)
+	defer release()
+	msg := negtest.ExpectFatal(t, func(t testing.TB) {
+		checkLeasable(t, []string{"gaga"})
+	})
+	if !strings.Contains(msg, "gaga") {
+		t.Errorf("Lease() of unknown device failed with message %q, want %q", msg, "gaga")
+	}
+}
+
+func TestLeasedByOtherTest(t *testing.T) {
+	initFakeBinding(t)
+	reserveFakeTestbed(t)
+	defer release()
+	dut := DUT(t, "dut")
+	devs := checkLeasable(t, []string{"dut"})
+	lease.Acquire("TestOther", devs)
+	defer lease.Release("TestOther", devs)
+
+	msg := negtest.ExpectFatal(t, func(t testing.TB) {
+		DUT(t, "dut")
+	})
+	if want := "leased by test TestOther"; !strings.Contains(msg, want) {
+		t.Errorf("DUT() of device leased by another test failed with message %q, want %q", msg, want)
+	}
+	if got := DUTs(t); got["dut"] != nil {
+		t.Errorf("DUTs() got device leased by another test")
+	}
+	msg = negtest.ExpectFatal(t, func(t testing.TB) {
+		dut.RawAPIs().GNMI(t)
+	})
+	if want := "leased by test TestOther"; !strings.Contains(msg, want) {
+		t.Errorf("GNMI() on device leased by another test failed with message %q, want %q", msg, want)
+	}
+	if strings.Contains(msg, report.InfraFailPrefix) {
+		t.Errorf("GNMI() on device leased by another test failed with message %q, want a user error", msg)
+	}
+	msg = negtest.ExpectFatal(t, func(t testing.TB) {
+		dut.Components(t)
+	})
+	if want := "leased by test TestOther"; !strings.Contains(msg, want) {
+		t.Errorf("Components() on device leased by another test failed with message %q, want %q", msg, want)
+	}
+}
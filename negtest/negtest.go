@@ -125,3 +125,8 @@ func (ft *fakeT) Error(args ...interface{}) {
 
 // Helper implements the testing.TB Helper method as a noop.
 func (*fakeT) Helper() {}
+
+// Name implements the testing.TB Name method by delegating to the real *testing.T.
+func (ft *fakeT) Name() string {
+	return ft.realT.Name()
+}
@@ -16,11 +16,11 @@
 package ondatra
 
 import (
+	"golang.org/x/net/context"
 	"fmt"
 	"os"
 	"os/signal"
 	"reflect"
-	"runtime"
 	"runtime/debug"
 	"sync"
 	"syscall"
@@ -32,14 +32,22 @@ import (
 	log "github.com/golang/glog"
 	"github.com/openconfig/ondatra/internal/closer"
 	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/keepalive"
+	"github.com/openconfig/ondatra/internal/lease"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 	"github.com/openconfig/ondatra/internal/reservemain"
+	"github.com/openconfig/ondatra/internal/usererr"
 )
 
 var (
 	sigc       = make(chan os.Signal, 1)
 	reserveFn  = reserve
+	fetchFn    = fetch
 	releaseFn  = release
+	captureFn  = captureBaseline
+	diagnoseFn = collectDiagnostics
+	exitFn     = os.Exit
 	runTestsFn = (*fixture).runTests
 )
 
@@ -48,6 +56,20 @@ type Binder func() (binding.Binding, error)
 
 // RunTests acquires the testbed of devices and runs the tests. Every device is
 // initialized with a baseline configuration that allows it to be managed.
+//
+// If the -reservation_id flag is set, the tests attach to that existing
+// reservation, which must match the testbed, instead of reserving the testbed,
+// and the reservation is not released when the tests exit.
+//
+// If the -restore_config flag is set, the running config of every DUT is
+// snapshotted once the testbed is reserved, and the DUTs are restored to it
+// after every test. If the test has leased devices, only the leased DUTs are
+// restored.
+//
+// On an interrupt or termination signal, the remaining tests are skipped and
+// the current test is given up to the -signal_grace_period to finish. Then
+// diagnostics of the DUTs are collected into the -diagnostics_dir and the
+// testbed is released.
 func RunTests(m *testing.M, binders ...Binder) {
 	// Careful to only exit at the very end, because exiting skips all pending defers.
 	res, err := doRun(m, binders...)
@@ -74,47 +96,149 @@ func doRun(m *testing.M, binders ...Binder) (res int, rerr error) {
 	if !binding.IsSet() {
 		log.Warning("Binding is not set, this will likely cause a panic during test.")
 	}
-	fmt.Println(actionMsg("Reserving the testbed"))
-	if err := reserveFn(*reservemain.TestbedPath, *reservemain.RunTime, *reservemain.WaitTime); err != nil {
-		return 0, err
+	defer closer.Close(&rerr, writeReports, "error writing test reports")
+	if id := *reservemain.ReservationID; id != "" {
+		logRunAction(fmt.Sprintf("Attaching to reservation %s", id))
+		if err := fetchFn(*reservemain.TestbedPath, id); err != nil {
+			return 0, err
+		}
+	} else {
+		logRunAction("Reserving the testbed")
+		if err := reserveFn(*reservemain.TestbedPath, *reservemain.RunTime, *reservemain.WaitTime); err != nil {
+			return 0, err
+		}
+	}
+	// The testbed is released either when the run is over or by the signal
+	// handler, whichever comes first.
+	var (
+		releaseOnce sync.Once
+		releaseErr  error
+	)
+	releaseTestbed := func() error {
+		releaseOnce.Do(func() {
+			if *reservemain.ReservationID != "" {
+				logRunAction("Detaching from the testbed")
+			} else {
+				logRunAction("Releasing the testbed")
+			}
+			releaseErr = releaseFn()
+		})
+		return releaseErr
+	}
+	f := &fixture{restoreConfig: *reservemain.RestoreConfig}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		interruptOnSignal(f, done, releaseTestbed, syscall.SIGINT, syscall.SIGTERM)
+	}()
+	// Stop waiting for signals once the tests are over, so that a later run in
+	// the same process is not interrupted by this one.
+	var finishOnce sync.Once
+	finish := func() {
+		finishOnce.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+	defer finish()
+	defer closer.Close(&rerr, releaseTestbed, "error releasing testbed")
+	var b binding.Binding
+	if binding.IsSet() {
+		b = binding.Get()
+	}
+	runTime, cfg := *reservemain.RunTime, reservemain.KeepaliveConfig()
+	if *reservemain.ReservationID != "" {
+		// An attached reservation is owned by whoever reserved it, so its expiry
+		// is not now plus the run time and it is not ours to extend. Only track
+		// the expiry that the binding reports in heartbeats.
+		runTime, cfg.MaxExtension = 0, 0
+	}
+	f.keeper = keepalive.New(b, runTime, cfg, logRunWarning)
+	f.keeper.Start(context.Background())
+	defer f.keeper.Stop()
+	if *reservemain.RestoreConfig {
+		logRunAction("Capturing the baseline config")
+		if err := captureFn(); err != nil {
+			return 0, err
+		}
+	}
+	res = runTestsFn(f, m)
+	finish()
+	if f.interrupted() != nil {
+		f.diagnose()
 	}
-	go fnAfterSignal(releaseFn, syscall.SIGINT, syscall.SIGTERM)
-	defer closer.Close(&rerr, func() error {
-		fmt.Println(actionMsg("Releasing the testbed"))
-		return releaseFn()
-	}, "error releasing testbed")
-	return runTestsFn(new(fixture), m, *reservemain.RunTime), nil
+	return res, nil
 }
 
-// fnAfterSignal waits for one of `signals` then calls `fn`.
-func fnAfterSignal(fn func() error, signals ...os.Signal) {
+// interruptOnSignal waits for one of `signals`, unless `done` is closed first
+// because the tests are over. On a signal, it skips the remaining tests and
+// waits up to the grace period for the current test to finish, leaving doRun
+// to collect diagnostics and release the testbed. If the current test does not
+// finish in time, or a second signal arrives, it collects diagnostics, releases
+// the testbed and exits itself.
+func interruptOnSignal(f *fixture, done <-chan struct{}, releaseTestbed func() error, signals ...os.Signal) {
 	signal.Notify(sigc, signals...)
-	s := <-sigc
-	fnName := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
-	log.Infof("FnAfterSignal caught %s, calling %v\n", s, fnName)
-	if err := fn(); err != nil {
-		log.Errorf("error calling %s: %v", fnName, err)
+	defer signal.Stop(sigc)
+	var s os.Signal
+	select {
+	case s = <-sigc:
+	case <-done:
+		return
+	}
+	grace := *reservemain.SignalGracePeriod
+	logRunWarning("Caught %v: skipping the remaining tests and waiting up to %v for the current test to finish", s, grace)
+	f.interrupt(s)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+		logRunWarning("The current test did not finish within %v", grace)
+	case s = <-sigc:
+		logRunWarning("Caught %v again: not waiting for the current test to finish", s)
+	}
+	f.diagnose()
+	if err := releaseTestbed(); err != nil {
+		log.Errorf("error releasing testbed: %v", err)
 	}
+	if err := writeReports(); err != nil {
+		log.Errorf("error writing test reports: %v", err)
+	}
+	log.Flush()
+	exitFn(1)
 }
 
 type fixture struct {
-	mu        sync.Mutex
-	earlyFail bool
-	fatalFn   func(args ...interface{})
+	mu            sync.Mutex
+	earlyFail     bool
+	restoreConfig bool
+	// keeper tracks the expiry of the reservation; it may be nil.
+	keeper *keepalive.Keeper
+	// signal is the signal that interrupted the run, if any.
+	signal       os.Signal
+	diagnoseOnce sync.Once
 }
 
-func (f *fixture) runTests(m *testing.M, timeout time.Duration) int {
+func (f *fixture) runTests(m *testing.M) int {
 	tests := reflect.ValueOf(m).Elem().FieldByName("tests")
 	for i := 0; i < tests.Len(); i++ {
 		fnVal := tests.Index(i).FieldByName("F")
 		fnPtr := (*func(*testing.T))(unsafe.Pointer(fnVal.UnsafeAddr()))
 		fn := *fnPtr
 		*fnPtr = func(t *testing.T) {
-			f.testStarted(t, timeout)
+			report.TestStarted(t.Name())
+			t.Cleanup(func() {
+				report.TestFinished(t.Name(), outcome(t))
+			})
+			f.testStarted(t)
 			binding.Get().SetTestMetadata(&binding.TestMetadata{TestName: t.Name()})
+			if f.restoreConfig {
+				defer restoreBaseline(t)
+			}
 			defer func() {
 				if r := recover(); r != nil {
-					f.failEarly(fmt.Sprintf("Ondatra test panicked: %v, stack :%s", r, debug.Stack()))
+					f.failEarly(t, fmt.Sprintf("Ondatra test panicked: %v, stack :%s", r, debug.Stack()))
 				}
 			}()
 			fn(t)
@@ -123,31 +247,113 @@ func (f *fixture) runTests(m *testing.M, timeout time.Duration) int {
 	return m.Run()
 }
 
-func (f *fixture) testStarted(t *testing.T, timeout time.Duration) {
+// testStarted skips the test if an earlier test failed early, if the run was
+// interrupted by a signal, or if the reservation has expired, rather than
+// letting the test fail midway; otherwise it warns in the test log if the
+// reservation expires soon.
+func (f *fixture) testStarted(t *testing.T) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	if f.earlyFail {
 		t.SkipNow()
 	}
-	// Wait until the first testcase to start the timer, so fatalFn is definitely set.
-	if timeout > 0 && f.fatalFn == nil {
-		go func() {
-			time.Sleep(timeout)
-			f.failEarly(fmt.Sprintf("Ondatra test timed out after %v", timeout))
-		}()
+	if f.signal != nil {
+		t.Skipf("Skipping test because the run was interrupted by %v", f.signal)
+	}
+	if f.keeper != nil {
+		if f.keeper.Expired() {
+			t.Skip("Skipping test because the reservation has expired; increase -run_time or allow extending it with -max_run_time_extension")
+		}
+		if w := f.keeper.ExpiryWarning(); w != "" {
+			t.Logf("WARNING: %s", w)
+		}
 	}
-	f.fatalFn = t.Fatal
 }
 
-func (f *fixture) failEarly(msg string) {
+// failEarly fails the test, which must be running on the calling goroutine,
+// and skips the remaining tests.
+func (f *fixture) failEarly(t testing.TB, msg string) {
 	f.mu.Lock()
-	defer f.mu.Unlock()
 	f.earlyFail = true
-	f.fatalFn(msg)
+	f.mu.Unlock()
+	t.Fatal(msg)
+}
+
+// interrupt records that the run was interrupted by the signal, so that the
+// remaining tests are skipped.
+func (f *fixture) interrupt(s os.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.signal = s
+}
+
+// interrupted returns the signal that interrupted the run, or nil if none did.
+func (f *fixture) interrupted() os.Signal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.signal
+}
+
+// diagnose collects diagnostics of the DUTs, at most once per run.
+func (f *fixture) diagnose() {
+	f.diagnoseOnce.Do(func() {
+		if err := diagnoseFn(); err != nil {
+			logRunWarning("Failed to collect diagnostics: %v", err)
+		}
+	})
+}
+
+// outcome returns the outcome of a finished test.
+func outcome(t *testing.T) report.Outcome {
+	switch {
+	case t.Failed():
+		return report.Failed
+	case t.Skipped():
+		return report.Skipped
+	}
+	return report.Passed
+}
+
+// writeReports writes the test reports requested by flags.
+func writeReports() error {
+	if *reservemain.JUnitReport != "" {
+		if err := report.WriteJUnit(*reservemain.JUnitReport); err != nil {
+			return err
+		}
+	}
+	if *reservemain.JSONReport != "" {
+		if err := report.WriteJSON(*reservemain.JSONReport); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logAction logs an action of the test on the device. The format is passed the
+// name of the device, followed by any other args. It fails the test if the
+// device is leased by another test, as every action on a device is logged.
+func logAction(t testing.TB, format string, dev reservation.Device, args ...interface{}) {
+	t.Helper()
+	name := dev.Dimensions().Name
+	if err := lease.Check(t.Name(), dev); err != nil {
+		report.Fatalf(t, usererr.Wrap(err), "cannot use device %s", name)
+	}
+	msg := fmt.Sprintf(format, append([]interface{}{name}, args...)...)
+	report.RecordAction(t.Name(), name, msg)
+	t.Log(actionMsg(msg))
+}
+
+// logRunAction logs an action performed outside of any test.
+func logRunAction(msg string) {
+	report.RecordAction("", "", msg)
+	fmt.Println(actionMsg(msg))
 }
 
-func logAction(t testing.TB, format string, dev reservation.Device) {
-	t.Log(actionMsg(fmt.Sprintf(format, dev.Dimensions().Name)))
+// logRunWarning logs a warning outside of any test.
+func logRunWarning(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Warning(msg)
+	fmt.Println(actionMsg("WARNING: " + msg))
 }
 
 func actionMsg(msg string) string {
@@ -15,27 +15,41 @@
 package ondatra
 
 import (
+	"golang.org/x/net/context"
 	"os"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/baseline"
+	"github.com/openconfig/ondatra/internal/keepalive"
+	"github.com/openconfig/ondatra/internal/lease"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/reservemain"
 )
 
 func TestReserveOnRun(t *testing.T) {
 	origRunTests := runTestsFn
+	origGrace := *reservemain.SignalGracePeriod
 	defer func() {
 		reserveFn = reserve
 		releaseFn = release
+		diagnoseFn = collectDiagnostics
+		exitFn = os.Exit
 		runTestsFn = origRunTests
+		*reservemain.SignalGracePeriod = origGrace
 	}()
 	tests := []struct {
 		desc                  string
 		sig                   os.Signal
+		finishOnSignal        bool
 		reservErr, releaseErr error
 		wantReleased          bool
+		wantDiagnosed         bool
+		wantExit              bool
 		wantErr               string
 	}{{
 		desc:      "error on reserve",
@@ -47,9 +61,17 @@ func TestReserveOnRun(t *testing.T) {
 		wantReleased: true,
 		wantErr:      "error releasing testbed",
 	}, {
-		desc:         "release on signal",
-		sig:          os.Interrupt,
-		wantReleased: true,
+		desc:          "release on signal",
+		sig:           os.Interrupt,
+		wantReleased:  true,
+		wantDiagnosed: true,
+		wantExit:      true,
+	}, {
+		desc:           "finish test on signal",
+		sig:            os.Interrupt,
+		finishOnSignal: true,
+		wantReleased:   true,
+		wantDiagnosed:  true,
 	}, {
 		desc:         "release on test completion",
 		wantReleased: true,
@@ -73,10 +95,28 @@ func TestReserveOnRun(t *testing.T) {
 				return test.releaseErr
 			}
 
-			runTestsFn = func(*fixture, *testing.M, time.Duration) int { return 0 }
-			if test.sig != nil {
+			diagnosed, exited := false, false
+			diagnoseFn = func() error {
+				diagnosed = true
+				return nil
+			}
+			exitFn = func(int) { exited = true }
+
+			runTestsFn = func(*fixture, *testing.M) int { return 0 }
+			switch {
+			case test.finishOnSignal:
+				// Finish the current test once interrupted, within the grace period.
+				*reservemain.SignalGracePeriod = time.Minute
+				runTestsFn = func(f *fixture, _ *testing.M) int {
+					for f.interrupted() == nil {
+						time.Sleep(time.Millisecond)
+					}
+					return 0
+				}
+			case test.sig != nil:
 				// Run indefinitely if we should close on a signal.
-				runTestsFn = func(*fixture, *testing.M, time.Duration) int {
+				*reservemain.SignalGracePeriod = 0
+				runTestsFn = func(*fixture, *testing.M) int {
 					<-releaseCh
 					return 0
 				}
@@ -98,6 +138,103 @@ func TestReserveOnRun(t *testing.T) {
 			if tbReleased != test.wantReleased {
 				t.Errorf("doRun: testbed released? %t, wanted testbed released? %t", tbReleased, test.wantReleased)
 			}
+			if diagnosed != test.wantDiagnosed {
+				t.Errorf("doRun: diagnostics collected? %t, wanted diagnostics collected? %t", diagnosed, test.wantDiagnosed)
+			}
+			if exited != test.wantExit {
+				t.Errorf("doRun: exited? %t, wanted exited? %t", exited, test.wantExit)
+			}
 		})
 	}
 }
+
+func TestAttachOnRun(t *testing.T) {
+	origRunTests := runTestsFn
+	defer func() {
+		reserveFn = reserve
+		fetchFn = fetch
+		releaseFn = release
+		runTestsFn = origRunTests
+		*reservemain.ReservationID = ""
+		*reservemain.RunTime = 0
+	}()
+	*reservemain.ReservationID = "res1"
+	*reservemain.RunTime = time.Hour
+	reserveFn = func(string, time.Duration, time.Duration) error {
+		t.Errorf("doRun reserved the testbed, want it to attach")
+		return nil
+	}
+	var gotID string
+	fetchFn = func(_, id string) error {
+		gotID = id
+		return nil
+	}
+	releaseFn = func() error { return nil }
+	var expires bool
+	runTestsFn = func(f *fixture, _ *testing.M) int {
+		_, expires = f.keeper.Remaining()
+		return 0
+	}
+	if _, err := doRun(nil); err != nil {
+		t.Fatalf("doRun failed: %v", err)
+	}
+	if want := "res1"; gotID != want {
+		t.Errorf("doRun attached to reservation %q, want %q", gotID, want)
+	}
+	if expires {
+		t.Errorf("doRun tracked an expiry of run time %v for an attached reservation, want none", *reservemain.RunTime)
+	}
+}
+
+func TestTestStartedExpired(t *testing.T) {
+	f := &fixture{keeper: keepalive.New(nil, time.Nanosecond, keepalive.Config{}, nil)}
+	time.Sleep(time.Millisecond)
+	ran := false
+	t.Run("expired", func(t *testing.T) {
+		f.testStarted(t)
+		ran = true
+	})
+	if ran {
+		t.Errorf("testStarted() after the reservation expired did not skip the test")
+	}
+}
+
+func TestTestStartedInterrupted(t *testing.T) {
+	f := &fixture{}
+	f.interrupt(os.Interrupt)
+	ran := false
+	t.Run("interrupted", func(t *testing.T) {
+		f.testStarted(t)
+		ran = true
+	})
+	if ran {
+		t.Errorf("testStarted() after the run was interrupted did not skip the test")
+	}
+}
+
+func TestRestoreBaseline(t *testing.T) {
+	initFakeBinding(t)
+	reserveFakeTestbed(t)
+	defer func() { restoreFn = baseline.Restore }()
+	var restored []string
+	restoreFn = func(_ context.Context, dut *reservation.DUT) error {
+		restored = append(restored, dut.Name)
+		return nil
+	}
+
+	restoreBaseline(t)
+	if diff := cmp.Diff([]string{"pf01.xxx01", "pf02.xxx01", "pf03.xxx01"}, restored); diff != "" {
+		t.Errorf("restoreBaseline() restored diff (-want +got):\n%s", diff)
+	}
+
+	t.Run("leased", func(t *testing.T) {
+		restored = nil
+		devs := checkLeasable(t, []string{"dut_cisco", "ate"})
+		lease.Acquire(t.Name(), devs)
+		defer lease.Release(t.Name(), devs)
+		restoreBaseline(t)
+		if diff := cmp.Diff([]string{"pf02.xxx01"}, restored); diff != "" {
+			t.Errorf("restoreBaseline() restored diff (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/openconfig/ondatra/internal/closer"
 	"github.com/openconfig/ondatra/internal/operations"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 )
 
@@ -103,7 +104,7 @@ func (i *InstallOp) Operate(t testing.TB) {
 	t.Helper()
 	logAction(t, "Installing package on %s", i.dev)
 	if err := operations.Install(context.Background(), i.dev, i.version, i.standby, i.reader); err != nil {
-		t.Fatalf("Operate(t) on %s: %v", i, err)
+		report.Fatalf(t, err, "Operate(t) on %s", i)
 	}
 }
 
@@ -133,7 +134,7 @@ func (p *PingOp) Operate(t testing.TB) {
 	t.Helper()
 	logAction(t, "Pinging from %s", p.dev)
 	if err := operations.Ping(context.Background(), p.dev, p.dest); err != nil {
-		t.Fatalf("Operate(t) on %s: %v", p, err)
+		report.Fatalf(t, err, "Operate(t) on %s", p)
 	}
 }
 
@@ -178,7 +179,7 @@ func (s *SetInterfaceStateOp) Operate(t testing.TB) {
 	t.Helper()
 	logAction(t, "Setting interface state on %s", s.dev)
 	if err := operations.SetInterfaceState(context.Background(), s.dev, s.intf, s.enabled); err != nil {
-		t.Fatalf("Operate(t) on %s: %v", s, err)
+		report.Fatalf(t, err, "Operate(t) on %s", s)
 	}
 }
 
@@ -189,8 +190,9 @@ func (o *Operations) NewReboot() *RebootOp {
 
 // RebootOp is a reboot operation.
 type RebootOp struct {
-	dev     reservation.Device
-	timeout time.Duration
+	dev       reservation.Device
+	component string
+	timeout   time.Duration
 }
 
 func (r *RebootOp) String() string {
@@ -203,12 +205,66 @@ func (r *RebootOp) WithTimeout(timeout time.Duration) *RebootOp {
 	return r
 }
 
+// WithComponent specifies to reboot only the named component, e.g. a line
+// card, rather than the whole device. The operation then also waits for the
+// component to become active again.
+func (r *RebootOp) WithComponent(component string) *RebootOp {
+	r.component = component
+	return r
+}
+
 // Operate performs the Reboot operation.
 func (r *RebootOp) Operate(t testing.TB) {
 	t.Helper()
-	logAction(t, "Rebooting %s", r.dev)
-	if err := operations.Reboot(context.Background(), r.dev, r.timeout); err != nil {
-		t.Fatalf("Operate(t) on %s: %v", r, err)
+	if r.component != "" {
+		logAction(t, "Rebooting %s component %s", r.dev, r.component)
+	} else {
+		logAction(t, "Rebooting %s", r.dev)
+	}
+	if err := operations.Reboot(context.Background(), r.dev, r.component, r.timeout); err != nil {
+		report.Fatalf(t, err, "Operate(t) on %s", r)
+	}
+}
+
+// NewSwitchControlProcessor creates a new operation that switches over the
+// supervisor of the device.
+func (o *Operations) NewSwitchControlProcessor() *SwitchControlProcessorOp {
+	return &SwitchControlProcessorOp{dev: o.dev}
+}
+
+// SwitchControlProcessorOp is an operation that makes a standby control
+// processor, i.e. supervisor, the active one, using the gNOI
+// System.SwitchControlProcessor RPC.
+type SwitchControlProcessorOp struct {
+	dev     reservation.Device
+	target  string
+	timeout time.Duration
+}
+
+func (s *SwitchControlProcessorOp) String() string {
+	return fmt.Sprintf("SwitchControlProcessorOp%+v", *s)
+}
+
+// WithTarget specifies the name of the control processor component to make
+// active, e.g. one of the DUT components of type ComponentControllerCard.
+func (s *SwitchControlProcessorOp) WithTarget(target string) *SwitchControlProcessorOp {
+	s.target = target
+	return s
+}
+
+// WithTimeout specifies how long to wait for the target to become primary.
+func (s *SwitchControlProcessorOp) WithTimeout(timeout time.Duration) *SwitchControlProcessorOp {
+	s.timeout = timeout
+	return s
+}
+
+// Operate performs the SwitchControlProcessor operation and waits until the
+// device reports the target as the primary control processor.
+func (s *SwitchControlProcessorOp) Operate(t testing.TB) {
+	t.Helper()
+	logAction(t, "Switching control processor of %s to %s", s.dev, s.target)
+	if err := operations.SwitchControlProcessor(context.Background(), s.dev, s.target, s.timeout); err != nil {
+		report.Fatalf(t, err, "Operate(t) on %s", s)
 	}
 }
 
@@ -231,6 +287,6 @@ func (r *RestartRoutingOp) Operate(t testing.TB) {
 	t.Helper()
 	logAction(t, "Restarting routing on %s", r.dev)
 	if err := operations.RestartRouting(context.Background(), r.dev); err != nil {
-		t.Fatalf("Operate(t) on %s: %v", r, err)
+		report.Fatalf(t, err, "Operate(t) on %s", r)
 	}
 }
@@ -17,23 +17,29 @@ package ondatra
 import (
 	"bytes"
 	"golang.org/x/net/context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/testing/protocmp"
 	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/operations"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/reservemain"
 	"github.com/openconfig/ondatra/negtest"
 
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
 	ospb "github.com/openconfig/gnoi/os"
 	spb "github.com/openconfig/gnoi/system"
+	tpb "github.com/openconfig/gnoi/types"
 	opb "github.com/openconfig/ondatra/proto"
 )
 
@@ -51,6 +57,68 @@ func initOperationFakes(t *testing.T) {
 	fakeBind.GNOIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (binding.GNOIClients, error) {
 		return fakeGNOI, nil
 	}
+	fakeBind.GNMIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (gpb.GNMIClient, error) {
+		return fakeComponentsGNMI, nil
+	}
+	wait := operations.StatusWait
+	operations.StatusWait = time.Millisecond
+	t.Cleanup(func() { operations.StatusWait = wait })
+}
+
+var fakeComponentsGNMI = &fakeGNMIClient{}
+
+// fakeGNMIClient answers Get requests with the components it holds and
+// records the last Set request. If responses are queued, every Get first
+// advances to the next one.
+type fakeGNMIClient struct {
+	gpb.GNMIClient
+	getResp   *gpb.GetResponse
+	nextResps []*gpb.GetResponse
+	setReq    *gpb.SetRequest
+}
+
+func (fg *fakeGNMIClient) Get(context.Context, *gpb.GetRequest, ...grpc.CallOption) (*gpb.GetResponse, error) {
+	if len(fg.nextResps) > 0 {
+		fg.getResp, fg.nextResps = fg.nextResps[0], fg.nextResps[1:]
+	}
+	return fg.getResp, nil
+}
+
+func (fg *fakeGNMIClient) Set(_ context.Context, req *gpb.SetRequest, _ ...grpc.CallOption) (*gpb.SetResponse, error) {
+	fg.setReq = req
+	return &gpb.SetResponse{}, nil
+}
+
+// componentsResp returns a Get response that reports the state of a line
+// card and two supervisors in differently shaped updates.
+func componentsResp(lcStatus, sup1Role, sup2Role string) *gpb.GetResponse {
+	jsonVal := func(js string) *gpb.TypedValue {
+		return &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(js)}}
+	}
+	compElem := func(name string) *gpb.PathElem {
+		return &gpb.PathElem{Name: "component", Key: map[string]string{"name": name}}
+	}
+	return &gpb.GetResponse{Notification: []*gpb.Notification{{
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "components"}}},
+			Val: jsonVal(`{"openconfig-platform:component": [
+				{"name": "Linecard1", "state": {"type": "openconfig-platform-types:LINECARD", "parent": "Chassis"}},
+				{"name": "Chassis", "state": {"type": "openconfig-platform-types:CHASSIS"}}
+			]}`),
+		}, {
+			Path: &gpb.Path{Elem: []*gpb.PathElem{{Name: "components"}, compElem("Linecard1"), {Name: "state"}, {Name: "oper-status"}}},
+			Val:  jsonVal(`"openconfig-platform-types:` + lcStatus + `"`),
+		}},
+	}, {
+		Prefix: &gpb.Path{Elem: []*gpb.PathElem{{Name: "components"}}},
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Elem: []*gpb.PathElem{compElem("Supervisor1")}},
+			Val:  jsonVal(`{"state": {"type": "CONTROLLER_CARD", "redundant-role": "` + sup1Role + `"}}`),
+		}, {
+			Path: &gpb.Path{Elem: []*gpb.PathElem{compElem("Supervisor2")}},
+			Val:  jsonVal(`{"state": {"type": "CONTROLLER_CARD", "redundant-role": "` + sup2Role + `"}}`),
+		}},
+	}}}
 }
 
 type fakeGNOIClient struct {
@@ -60,6 +128,7 @@ type fakeGNOIClient struct {
 	Pinger         func(context.Context, *spb.PingRequest, ...grpc.CallOption) (spb.System_PingClient, error)
 	Rebooter       func(context.Context, *spb.RebootRequest, ...grpc.CallOption) (*spb.RebootResponse, error)
 	RebootStatuser func(context.Context, *spb.RebootStatusRequest, ...grpc.CallOption) (*spb.RebootStatusResponse, error)
+	Switcher       func(context.Context, *spb.SwitchControlProcessorRequest, ...grpc.CallOption) (*spb.SwitchControlProcessorResponse, error)
 	Installer      func(context.Context, ...grpc.CallOption) (ospb.OS_InstallClient, error)
 }
 
@@ -83,6 +152,10 @@ func (fg *fakeGNOIClient) RebootStatus(ctx context.Context, req *spb.RebootStatu
 	return fg.RebootStatuser(ctx, req, opts...)
 }
 
+func (fg *fakeGNOIClient) SwitchControlProcessor(ctx context.Context, req *spb.SwitchControlProcessorRequest, opts ...grpc.CallOption) (*spb.SwitchControlProcessorResponse, error) {
+	return fg.Switcher(ctx, req, opts...)
+}
+
 func (fg *fakeGNOIClient) Install(ctx context.Context, opts ...grpc.CallOption) (ospb.OS_InstallClient, error) {
 	return fg.Installer(ctx, opts...)
 }
@@ -295,6 +368,31 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestPingRetry(t *testing.T) {
+	initOperationFakes(t)
+	defer func(backoff time.Duration) { *reservemain.RetryBackoff = backoff }(*reservemain.RetryBackoff)
+	*reservemain.RetryBackoff = 0
+	var dials int
+	fakeBind.GNOIDialer = func(context.Context, *reservation.DUT, ...grpc.DialOption) (binding.GNOIClients, error) {
+		dials++
+		return fakeGNOI, nil
+	}
+	var pings int
+	fakeGNOI.Pinger = func(context.Context, *spb.PingRequest, ...grpc.CallOption) (spb.System_PingClient, error) {
+		if pings++; pings == 1 {
+			return nil, status.Error(codes.Unavailable, "connection dropped")
+		}
+		return &fakePingClient{resp: &spb.PingResponse{}}, nil
+	}
+	DUT(t, "dut").Operations().NewPing().WithDestination("1.2.3.4").Operate(t)
+	if pings != 2 {
+		t.Errorf("Operate(t) pinged %d times, want 2", pings)
+	}
+	if dials == 0 {
+		t.Errorf("Operate(t) did not re-dial gNOI after a transient error")
+	}
+}
+
 func TestPingErrors(t *testing.T) {
 	initOperationFakes(t)
 	tests := []struct {
@@ -334,11 +432,6 @@ func TestPingErrors(t *testing.T) {
 
 func TestSetInterfaceState(t *testing.T) {
 	initOperationFakes(t)
-	var gotConfig string
-	fakeBind.ConfigPusher = func(_ context.Context, _ *reservation.DUT, config string, _ *binding.ConfigOptions) error {
-		gotConfig = config
-		return nil
-	}
 	dut := DUT(t, "dut")
 	port := dut.Port(t, "port1")
 
@@ -393,13 +486,34 @@ func TestSetInterfaceState(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
-			gotConfig = ""
+			fakeComponentsGNMI.setReq = nil
 			tt.op.Operate(t)
-			if !strings.Contains(gotConfig, tt.wantIntf) {
-				t.Errorf("Operate() got config %q, want interface %q", gotConfig, tt.wantIntf)
+			req := fakeComponentsGNMI.setReq
+			if len(req.GetUpdate()) != 1 || len(req.GetReplace()) != 0 {
+				t.Fatalf("Operate() got set request %v, want a single update", req)
+			}
+			upd := req.GetUpdate()[0]
+			wantPath := &gpb.Path{Origin: "openconfig", Elem: []*gpb.PathElem{
+				{Name: "interfaces"},
+				{Name: "interface", Key: map[string]string{"name": tt.wantIntf}},
+			}}
+			if diff := cmp.Diff(wantPath, upd.GetPath(), protocmp.Transform()); diff != "" {
+				t.Errorf("Operate() got update path diff (-want +got):\n%s", diff)
+			}
+			var got interface{}
+			if err := json.Unmarshal(upd.GetVal().GetJsonIetfVal(), &got); err != nil {
+				t.Fatalf("Operate() got invalid JSON %s: %v", upd.GetVal().GetJsonIetfVal(), err)
+			}
+			want := map[string]interface{}{
+				"openconfig-interfaces:name": tt.wantIntf,
+				"openconfig-interfaces:config": map[string]interface{}{
+					"name":    tt.wantIntf,
+					"enabled": tt.wantEnabled,
+					"type":    "iana-if-type:ethernetCsmacd",
+				},
 			}
-			if wantEnabled := strconv.FormatBool(tt.wantEnabled); !strings.Contains(gotConfig, wantEnabled) {
-				t.Errorf("Operate() got config %q, want enabled %q", gotConfig, wantEnabled)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Operate() got config diff (-want +got):\n%s", diff)
 			}
 		})
 	}
@@ -520,6 +634,147 @@ func TestRebootErrors(t *testing.T) {
 	}
 }
 
+func TestComponents(t *testing.T) {
+	initOperationFakes(t)
+	fakeComponentsGNMI.getResp = componentsResp("ACTIVE", "PRIMARY", "SECONDARY")
+	dut := DUT(t, "dut")
+
+	type comp struct{ name, typ, parent, status, role string }
+	var got []comp
+	for _, c := range dut.Components(t) {
+		got = append(got, comp{c.Name(), c.Type(), c.Parent(), c.OperStatus(), c.RedundantRole()})
+	}
+	want := []comp{
+		{name: "Chassis", typ: ComponentChassis},
+		{name: "Linecard1", typ: ComponentLineCard, parent: "Chassis", status: "ACTIVE"},
+		{name: "Supervisor1", typ: ComponentControllerCard, role: "PRIMARY"},
+		{name: "Supervisor2", typ: ComponentControllerCard, role: "SECONDARY"},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(comp{})); diff != "" {
+		t.Errorf("Components(t) got unexpected diff (-want,+got):\n%s", diff)
+	}
+	var sups []string
+	for _, c := range dut.ComponentsOfType(t, ComponentControllerCard) {
+		sups = append(sups, c.Name())
+	}
+	if diff := cmp.Diff([]string{"Supervisor1", "Supervisor2"}, sups); diff != "" {
+		t.Errorf("ComponentsOfType(t, %q) got unexpected diff (-want,+got):\n%s", ComponentControllerCard, diff)
+	}
+}
+
+func TestRebootComponent(t *testing.T) {
+	initOperationFakes(t)
+	// The line card is still active when the reboot status is first polled.
+	fakeComponentsGNMI.nextResps = []*gpb.GetResponse{
+		componentsResp("ACTIVE", "PRIMARY", "SECONDARY"),
+		componentsResp("INACTIVE", "PRIMARY", "SECONDARY"),
+		componentsResp("ACTIVE", "PRIMARY", "SECONDARY"),
+	}
+	var gotReq *spb.RebootRequest
+	fakeGNOI.Rebooter = func(_ context.Context, req *spb.RebootRequest, _ ...grpc.CallOption) (*spb.RebootResponse, error) {
+		gotReq = req
+		return &spb.RebootResponse{}, nil
+	}
+	var gotStatusReq *spb.RebootStatusRequest
+	fakeGNOI.RebootStatuser = func(_ context.Context, req *spb.RebootStatusRequest, _ ...grpc.CallOption) (*spb.RebootStatusResponse, error) {
+		gotStatusReq = req
+		return &spb.RebootStatusResponse{}, nil
+	}
+	DUT(t, "dut").Operations().NewReboot().WithComponent("Linecard1").Operate(t)
+	wantPath := []*tpb.Path{{
+		Origin: "openconfig",
+		Elem: []*tpb.PathElem{
+			{Name: "components"},
+			{Name: "component", Key: map[string]string{"name": "Linecard1"}},
+		},
+	}}
+	if diff := cmp.Diff(wantPath, gotReq.GetSubcomponents(), protocmp.Transform()); diff != "" {
+		t.Errorf("Reboot() got unexpected subcomponents diff (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantPath, gotStatusReq.GetSubcomponents(), protocmp.Transform()); diff != "" {
+		t.Errorf("RebootStatus() got unexpected subcomponents diff (-want,+got):\n%s", diff)
+	}
+	if n := len(fakeComponentsGNMI.nextResps); n > 0 {
+		t.Errorf("Reboot() returned with %d component states left unpolled, want it to wait for the line card to go down and return", n)
+	}
+}
+
+func TestRebootComponentTimeout(t *testing.T) {
+	initOperationFakes(t)
+	fakeComponentsGNMI.getResp = componentsResp("INACTIVE", "PRIMARY", "SECONDARY")
+	fakeGNOI.Rebooter = func(context.Context, *spb.RebootRequest, ...grpc.CallOption) (*spb.RebootResponse, error) {
+		return &spb.RebootResponse{}, nil
+	}
+	fakeGNOI.RebootStatuser = func(context.Context, *spb.RebootStatusRequest, ...grpc.CallOption) (*spb.RebootStatusResponse, error) {
+		return &spb.RebootStatusResponse{}, nil
+	}
+	reboot := DUT(t, "dut").Operations().NewReboot().WithComponent("Linecard1").WithTimeout(time.Millisecond)
+	gotErr := negtest.ExpectFatal(t, func(t testing.TB) {
+		reboot.Operate(t)
+	})
+	if want := "timed out"; !strings.Contains(gotErr, want) {
+		t.Errorf("Operate(t) on reboot got %q, want %q", gotErr, want)
+	}
+}
+
+func TestSwitchControlProcessor(t *testing.T) {
+	initOperationFakes(t)
+	fakeComponentsGNMI.getResp = componentsResp("ACTIVE", "SECONDARY", "PRIMARY")
+	var gotReq *spb.SwitchControlProcessorRequest
+	fakeGNOI.Switcher = func(_ context.Context, req *spb.SwitchControlProcessorRequest, _ ...grpc.CallOption) (*spb.SwitchControlProcessorResponse, error) {
+		gotReq = req
+		return &spb.SwitchControlProcessorResponse{}, nil
+	}
+	DUT(t, "dut").Operations().NewSwitchControlProcessor().WithTarget("Supervisor2").Operate(t)
+	if got, want := gotReq.GetControlProcessor().GetElem()[1].GetKey()["name"], "Supervisor2"; got != want {
+		t.Errorf("SwitchControlProcessor() got target %q, want %q", got, want)
+	}
+}
+
+func TestSwitchControlProcessorErrors(t *testing.T) {
+	initOperationFakes(t)
+	fakeComponentsGNMI.getResp = componentsResp("ACTIVE", "PRIMARY", "SECONDARY")
+	tests := []struct {
+		desc      string
+		target    string
+		timeout   time.Duration
+		switchErr error
+		wantErr   string
+	}{{
+		desc:    "no target",
+		wantErr: "no target",
+	}, {
+		desc:    "negative timeout",
+		target:  "Supervisor2",
+		timeout: -1,
+		wantErr: "positive duration",
+	}, {
+		desc:      "switch error",
+		target:    "Supervisor2",
+		switchErr: errors.New("switch error"),
+		wantErr:   "switch control processor",
+	}, {
+		desc:    "never primary",
+		target:  "Supervisor2",
+		timeout: time.Millisecond,
+		wantErr: "timed out",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			fakeGNOI.Switcher = func(context.Context, *spb.SwitchControlProcessorRequest, ...grpc.CallOption) (*spb.SwitchControlProcessorResponse, error) {
+				return &spb.SwitchControlProcessorResponse{}, tt.switchErr
+			}
+			op := DUT(t, "dut").Operations().NewSwitchControlProcessor().WithTarget(tt.target).WithTimeout(tt.timeout)
+			gotErr := negtest.ExpectFatal(t, func(t testing.TB) {
+				op.Operate(t)
+			})
+			if !strings.Contains(gotErr, tt.wantErr) {
+				t.Errorf("Operate(t) on %v got %q, want %q", op, gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestRestartRouting(t *testing.T) {
 	initOperationFakes(t)
 	var restarted bool
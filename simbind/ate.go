@@ -0,0 +1,152 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+const (
+	defaultFrameSize   = 64
+	defaultRatePercent = 10
+	defaultLineRate    = 10e9
+	imixAvgFrameSize   = 353
+	// Ethernet preamble and inter-frame gap, counted against the line rate.
+	frameOverhead = 20
+)
+
+// ateState is the simulated state of an ATE.
+type ateState struct {
+	dev              *device
+	top              *opb.Topology
+	protocolsRunning bool
+	flows            map[string]*flowState
+	disabledPorts    map[string]bool
+}
+
+func newATEState(dev *device) *ateState {
+	return &ateState{
+		dev:           dev,
+		flows:         make(map[string]*flowState),
+		disabledPorts: make(map[string]bool),
+	}
+}
+
+// flowState tracks the packets of a flow, which advance with time while the
+// flow is running.
+type flowState struct {
+	pb        *opb.Flow
+	fps       float64
+	frameSize float64
+	srcPorts  []string
+	dstPorts  []string
+	running   bool
+	last      time.Time
+	sent      float64
+	recv      float64
+}
+
+// ports returns the ATE ports of the named interface, resolving LAGs.
+func (a *ateState) ports(intfName string) ([]string, error) {
+	for _, i := range a.top.GetInterfaces() {
+		if i.GetName() != intfName {
+			continue
+		}
+		if i.GetPort() != "" {
+			return []string{i.GetPort()}, nil
+		}
+		for _, l := range a.top.GetLags() {
+			if l.GetName() == i.GetLag() {
+				return l.GetPorts(), nil
+			}
+		}
+		return nil, errors.Errorf("interface %q references unknown LAG %q", intfName, i.GetLag())
+	}
+	return nil, errors.Errorf("flow endpoint references unknown interface %q", intfName)
+}
+
+func (a *ateState) newFlow(f *opb.Flow, lineRate func(port string) float64) (*flowState, error) {
+	fs := &flowState{pb: f, frameSize: frameSize(f.GetFrameSize())}
+	for _, ep := range f.GetSrcEndpoints() {
+		ps, err := a.ports(ep.GetInterfaceName())
+		if err != nil {
+			return nil, err
+		}
+		fs.srcPorts = append(fs.srcPorts, ps...)
+	}
+	for _, ep := range f.GetDstEndpoints() {
+		ps, err := a.ports(ep.GetInterfaceName())
+		if err != nil {
+			return nil, err
+		}
+		fs.dstPorts = append(fs.dstPorts, ps...)
+	}
+	rate := f.GetFrameRate()
+	switch {
+	case rate.GetFps() > 0:
+		fs.fps = float64(rate.GetFps())
+	case rate.GetBps() > 0:
+		fs.fps = float64(rate.GetBps()) / (8 * fs.frameSize)
+	default:
+		pct := rate.GetPercent()
+		if pct == 0 {
+			pct = defaultRatePercent
+		}
+		fs.fps = pct / 100 * lineRate(fs.srcPorts[0]) / (8 * (fs.frameSize + frameOverhead))
+	}
+	return fs, nil
+}
+
+func frameSize(fs *opb.FrameSize) float64 {
+	switch {
+	case fs.GetFixed() > 0:
+		return float64(fs.GetFixed())
+	case fs.GetRandom() != nil:
+		return float64(fs.GetRandom().GetMin()+fs.GetRandom().GetMax()) / 2
+	case fs.GetImixPreset() != opb.FrameSize_IMIX_UNKNOWN:
+		return imixAvgFrameSize
+	}
+	return defaultFrameSize
+}
+
+// advance accumulates the packets sent since the last advance. Packets are
+// only received while every port on the path of the flow is up.
+func (f *flowState) advance(now time.Time, up bool) (sent, recv float64) {
+	if !f.running {
+		return 0, 0
+	}
+	sent = f.fps * now.Sub(f.last).Seconds()
+	f.last = now
+	if tx := f.pb.GetTransmission(); tx.GetPattern() == opb.Transmission_BURST && tx.GetPacketsPerBurst() > 0 {
+		if left := float64(tx.GetPacketsPerBurst()) - f.sent; sent > left {
+			sent = left
+		}
+	}
+	f.sent += sent
+	if up {
+		recv = sent
+		f.recv += recv
+	}
+	return sent, recv
+}
+
+func flowPath(name, leaf string) string {
+	return fmt.Sprintf("/flows/flow[name=%s]/state/%s", name, leaf)
+}
@@ -0,0 +1,524 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"github.com/openconfig/gnmi/value"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// leaf is a single value stored in the datastore.
+type leaf struct {
+	path *gpb.Path
+	val  *gpb.TypedValue
+	ts   int64
+	// sim is whether the leaf is state generated by the simulator itself,
+	// which client deletes and replaces leave untouched.
+	sim bool
+}
+
+// change is a batch of leaf updates and deletes made by a single write.
+type change struct {
+	updates []*leaf
+	deletes []*gpb.Path
+	ts      int64
+}
+
+// datastore is an in-memory, schemaless OpenConfig tree stored at the leaves.
+// Any leaf written under a "config" container is mirrored to the sibling
+// "state" container, as a device would report its applied configuration.
+type datastore struct {
+	mu       sync.RWMutex
+	leaves   map[string]*leaf
+	watchers map[*watcher]bool
+	// onRead, if set, is called before every read so that derived state
+	// (e.g. traffic counters) can be refreshed.
+	onRead func()
+	// onWrite, if set, is called after every successful client write.
+	onWrite func()
+}
+
+func newDatastore() *datastore {
+	return &datastore{
+		leaves:   make(map[string]*leaf),
+		watchers: make(map[*watcher]bool),
+	}
+}
+
+// watcher receives every change to leaves matching its paths.
+type watcher struct {
+	paths  []*gpb.Path
+	mu     sync.Mutex
+	queue  []*change
+	signal chan struct{}
+}
+
+func (w *watcher) push(c *change) {
+	w.mu.Lock()
+	w.queue = append(w.queue, c)
+	w.mu.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) pop() []*change {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	q := w.queue
+	w.queue = nil
+	return q
+}
+
+func (d *datastore) watch(paths []*gpb.Path) *watcher {
+	w := &watcher{paths: paths, signal: make(chan struct{}, 1)}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watchers[w] = true
+	return w
+}
+
+func (d *datastore) unwatch(w *watcher) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.watchers, w)
+}
+
+// get returns all the leaves at or below the specified path, sorted by path.
+// The path may contain "*" wildcards in names and key values, and a trailing
+// "..." element; missing keys are treated as wildcards.
+func (d *datastore) get(path *gpb.Path) []*leaf {
+	if d.onRead != nil {
+		d.onRead()
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var keys []string
+	for k, l := range d.leaves {
+		if matchPrefix(path.GetElem(), l.path.GetElem()) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var ls []*leaf
+	for _, k := range keys {
+		ls = append(ls, d.leaves[k])
+	}
+	return ls
+}
+
+// getJSON returns the subtree at the specified path as RFC7951 JSON.
+func (d *datastore) getJSON(path *gpb.Path) ([]byte, error) {
+	ls := d.get(path)
+	if len(ls) == 1 && len(ls[0].path.GetElem()) == len(path.GetElem()) {
+		v, err := value.ToScalar(ls[0].val)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+	root := make(map[string]interface{})
+	for _, l := range ls {
+		v, err := value.ToScalar(l.val)
+		if err != nil {
+			return nil, err
+		}
+		insertJSON(root, l.path.GetElem()[len(path.GetElem()):], v)
+	}
+	return json.MarshalIndent(listify(root), "", "  ")
+}
+
+// set applies a gNMI SetRequest from a client atomically.
+func (d *datastore) set(req *gpb.SetRequest) (*gpb.SetResponse, error) {
+	resp, err := d.apply(req, false)
+	if err == nil && d.onWrite != nil {
+		d.onWrite()
+	}
+	return resp, err
+}
+
+func (d *datastore) apply(req *gpb.SetRequest, sim bool) (*gpb.SetResponse, error) {
+	c := &change{ts: time.Now().UnixNano()}
+	var results []*gpb.UpdateResult
+	// Build the new state on a copy so a bad request leaves the tree untouched.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	next := make(map[string]*leaf, len(d.leaves))
+	for k, l := range d.leaves {
+		next[k] = l
+	}
+	for _, p := range req.GetDelete() {
+		p = joinPath(req.GetPrefix(), p)
+		c.deletes = append(c.deletes, deleteLeaves(next, p, sim)...)
+		results = append(results, &gpb.UpdateResult{Path: p, Op: gpb.UpdateResult_DELETE})
+	}
+	for _, u := range req.GetReplace() {
+		p := joinPath(req.GetPrefix(), u.GetPath())
+		c.deletes = append(c.deletes, deleteLeaves(next, p, sim)...)
+		ls, err := valueLeaves(p, u.GetVal(), c.ts, sim)
+		if err != nil {
+			return nil, err
+		}
+		c.updates = append(c.updates, putLeaves(next, ls)...)
+		results = append(results, &gpb.UpdateResult{Path: p, Op: gpb.UpdateResult_REPLACE})
+	}
+	for _, u := range req.GetUpdate() {
+		p := joinPath(req.GetPrefix(), u.GetPath())
+		ls, err := valueLeaves(p, u.GetVal(), c.ts, sim)
+		if err != nil {
+			return nil, err
+		}
+		c.updates = append(c.updates, putLeaves(next, ls)...)
+		results = append(results, &gpb.UpdateResult{Path: p, Op: gpb.UpdateResult_UPDATE})
+	}
+	d.leaves = next
+	d.notifyLocked(c)
+	return &gpb.SetResponse{Prefix: req.GetPrefix(), Response: results, Timestamp: c.ts}, nil
+}
+
+// setJSON replaces or merges RFC7951 JSON at the specified path.
+func (d *datastore) setJSON(path *gpb.Path, data []byte, replace bool) error {
+	u := &gpb.Update{Path: path, Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: data}}}
+	req := &gpb.SetRequest{Update: []*gpb.Update{u}}
+	if replace {
+		req = &gpb.SetRequest{Replace: []*gpb.Update{u}}
+	}
+	_, err := d.set(req)
+	return err
+}
+
+// setLeaf stores a single scalar value generated by the simulator.
+// Writing a value equal to the current one is a noop, so it is not streamed.
+func (d *datastore) setLeaf(path *gpb.Path, v interface{}) {
+	tv, err := value.FromScalar(v)
+	if err != nil {
+		panic(fmt.Sprintf("invalid simulated value %v: %v", v, err))
+	}
+	if proto.Equal(d.leafValue(path), tv) {
+		return
+	}
+	if _, err := d.apply(&gpb.SetRequest{Update: []*gpb.Update{{Path: path, Val: tv}}}, true); err != nil {
+		panic(fmt.Sprintf("failed to set simulated value %v: %v", v, err))
+	}
+}
+
+// leafValue returns the value stored at the exact path, or nil if none.
+func (d *datastore) leafValue(path *gpb.Path) *gpb.TypedValue {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if l, ok := d.leaves[pathKey(path)]; ok {
+		return l.val
+	}
+	return nil
+}
+
+func (d *datastore) notifyLocked(c *change) {
+	if len(c.updates) == 0 && len(c.deletes) == 0 {
+		return
+	}
+	for w := range d.watchers {
+		wc := &change{ts: c.ts}
+		for _, l := range c.updates {
+			if matchAny(w.paths, l.path) {
+				wc.updates = append(wc.updates, l)
+			}
+		}
+		for _, p := range c.deletes {
+			if matchAny(w.paths, p) {
+				wc.deletes = append(wc.deletes, p)
+			}
+		}
+		if len(wc.updates) > 0 || len(wc.deletes) > 0 {
+			w.push(wc)
+		}
+	}
+}
+
+func matchAny(queries []*gpb.Path, p *gpb.Path) bool {
+	for _, q := range queries {
+		if matchPrefix(q.GetElem(), p.GetElem()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the query elements match a prefix of the path.
+func matchPrefix(query, path []*gpb.PathElem) bool {
+	for i, q := range query {
+		if q.GetName() == "..." {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		e := path[i]
+		if q.GetName() != "*" && q.GetName() != e.GetName() {
+			return false
+		}
+		for k, v := range q.GetKey() {
+			if v != "*" && e.GetKey()[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func deleteLeaves(m map[string]*leaf, p *gpb.Path, sim bool) []*gpb.Path {
+	var deleted []*gpb.Path
+	for _, dp := range mirrorPaths(p) {
+		for k, l := range m {
+			if l.sim == sim && matchPrefix(dp.GetElem(), l.path.GetElem()) {
+				delete(m, k)
+				deleted = append(deleted, l.path)
+			}
+		}
+	}
+	return deleted
+}
+
+func putLeaves(m map[string]*leaf, ls []*leaf) []*leaf {
+	var put []*leaf
+	for _, l := range ls {
+		for _, p := range mirrorPaths(l.path) {
+			ml := &leaf{path: p, val: l.val, ts: l.ts, sim: l.sim}
+			m[pathKey(p)] = ml
+			put = append(put, ml)
+		}
+	}
+	return put
+}
+
+// mirrorPaths returns the path and, if the path is within a "config"
+// container, the equivalent path within the sibling "state" container.
+func mirrorPaths(p *gpb.Path) []*gpb.Path {
+	paths := []*gpb.Path{p}
+	for i, e := range p.GetElem() {
+		if e.GetName() == "config" && len(e.GetKey()) == 0 {
+			elems := append([]*gpb.PathElem{}, p.GetElem()...)
+			elems[i] = &gpb.PathElem{Name: "state"}
+			paths = append(paths, &gpb.Path{Elem: elems})
+			break
+		}
+	}
+	return paths
+}
+
+// valueLeaves converts a typed value at a path into the leaves to store.
+func valueLeaves(p *gpb.Path, tv *gpb.TypedValue, ts int64, sim bool) ([]*leaf, error) {
+	var data []byte
+	switch v := tv.GetValue().(type) {
+	case *gpb.TypedValue_JsonIetfVal:
+		data = v.JsonIetfVal
+	case *gpb.TypedValue_JsonVal:
+		data = v.JsonVal
+	case nil:
+		return nil, errors.Errorf("no value for path %v", p)
+	default:
+		return []*leaf{{path: p, val: tv, ts: ts, sim: sim}}, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var j interface{}
+	if err := dec.Decode(&j); err != nil {
+		return nil, errors.Wrapf(err, "invalid JSON value for path %v", p)
+	}
+	var ls []*leaf
+	if err := flattenJSON(p.GetElem(), j, ts, &ls); err != nil {
+		return nil, err
+	}
+	for _, l := range ls {
+		l.sim = sim
+	}
+	return ls, nil
+}
+
+// flattenJSON walks decoded RFC7951 JSON and appends a leaf for every scalar.
+// A JSON array of objects is treated as a YANG list, keyed by the scalar
+// members of each entry, per the OpenConfig style of keying list entries.
+func flattenJSON(elems []*gpb.PathElem, j interface{}, ts int64, ls *[]*leaf) error {
+	switch v := j.(type) {
+	case map[string]interface{}:
+		for name, child := range v {
+			name = stripModule(name)
+			if entries, ok := child.([]interface{}); ok && len(entries) > 0 {
+				if _, isObj := entries[0].(map[string]interface{}); isObj {
+					for _, entry := range entries {
+						obj, ok := entry.(map[string]interface{})
+						if !ok {
+							return errors.Errorf("mixed list entries in %q", name)
+						}
+						elem := &gpb.PathElem{Name: name, Key: listKeys(obj)}
+						if err := flattenJSON(appendElem(elems, elem), obj, ts, ls); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+			}
+			if err := flattenJSON(appendElem(elems, &gpb.PathElem{Name: name}), child, ts, ls); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	default:
+		tv, err := jsonScalar(v)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value at %v", elems)
+		}
+		*ls = append(*ls, &leaf{path: &gpb.Path{Elem: elems}, val: tv, ts: ts})
+		return nil
+	}
+}
+
+func jsonScalar(j interface{}) (*gpb.TypedValue, error) {
+	switch v := j.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return value.FromScalar(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &gpb.TypedValue{Value: &gpb.TypedValue_FloatVal{FloatVal: float32(f)}}, nil
+	case []interface{}:
+		sa := &gpb.ScalarArray{}
+		for _, e := range v {
+			tv, err := jsonScalar(e)
+			if err != nil {
+				return nil, err
+			}
+			sa.Element = append(sa.Element, tv)
+		}
+		return &gpb.TypedValue{Value: &gpb.TypedValue_LeaflistVal{LeaflistVal: sa}}, nil
+	default:
+		return value.FromScalar(v)
+	}
+}
+
+func listKeys(entry map[string]interface{}) map[string]string {
+	keys := make(map[string]string)
+	for k, v := range entry {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, nil:
+		default:
+			keys[stripModule(k)] = fmt.Sprint(v)
+		}
+	}
+	return keys
+}
+
+func stripModule(name string) string {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// insertJSON inserts a value into a nested JSON object, using a
+// "name[k=v]" key for list entries until listify converts them to arrays.
+func insertJSON(root map[string]interface{}, elems []*gpb.PathElem, v interface{}) {
+	m := root
+	for i, e := range elems {
+		if i == len(elems)-1 {
+			m[e.GetName()] = v
+			return
+		}
+		name := e.GetName()
+		if len(e.GetKey()) > 0 {
+			s, _ := ygot.PathToString(&gpb.Path{Elem: []*gpb.PathElem{e}})
+			name = listMarker + s
+		}
+		child, ok := m[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[name] = child
+		}
+		m = child
+	}
+}
+
+const listMarker = "\x00"
+
+// listify converts the list entry markers of insertJSON into JSON arrays.
+func listify(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	lists := make(map[string][]string)
+	for k, v := range m {
+		if strings.HasPrefix(k, listMarker) {
+			name := strings.TrimPrefix(k, listMarker+"/")
+			if i := strings.Index(name, "["); i >= 0 {
+				name = name[:i]
+			}
+			lists[name] = append(lists[name], k)
+			continue
+		}
+		if child, ok := v.(map[string]interface{}); ok {
+			v = listify(child)
+		}
+		out[k] = v
+	}
+	for name, ks := range lists {
+		sort.Strings(ks)
+		var entries []interface{}
+		for _, k := range ks {
+			entries = append(entries, listify(m[k].(map[string]interface{})))
+		}
+		out[name] = entries
+	}
+	return out
+}
+
+func appendElem(elems []*gpb.PathElem, e *gpb.PathElem) []*gpb.PathElem {
+	return append(append([]*gpb.PathElem{}, elems...), e)
+}
+
+func joinPath(prefix, p *gpb.Path) *gpb.Path {
+	return &gpb.Path{Elem: append(append([]*gpb.PathElem{}, prefix.GetElem()...), p.GetElem()...)}
+}
+
+func pathKey(p *gpb.Path) string {
+	s, err := ygot.PathToString(&gpb.Path{Elem: p.GetElem()})
+	if err != nil {
+		return fmt.Sprint(p.GetElem())
+	}
+	return s
+}
+
+// mustPath parses a path string, panicking on error; for static paths only.
+func mustPath(s string) *gpb.Path {
+	p, err := ygot.StringToStructuredPath(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid path %q: %v", s, err))
+	}
+	return p
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gnmi/value"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const intfJSON = `{
+  "openconfig-interfaces:interfaces": {
+    "interface": [{
+      "name": "eth1",
+      "config": {"name": "eth1", "description": "uplink", "mtu": 9000}
+    }, {
+      "name": "eth2",
+      "config": {"name": "eth2", "enabled": false}
+    }]
+  }
+}`
+
+func leafMap(t *testing.T, ls []*leaf) map[string]interface{} {
+	t.Helper()
+	m := make(map[string]interface{})
+	for _, l := range ls {
+		v, err := value.ToScalar(l.val)
+		if err != nil {
+			t.Fatalf("ToScalar(%v) failed: %v", l.val, err)
+		}
+		m[pathKey(l.path)] = v
+	}
+	return m
+}
+
+func TestSetJSON(t *testing.T) {
+	ds := newDatastore()
+	if err := ds.setJSON(&gpb.Path{}, []byte(intfJSON), true); err != nil {
+		t.Fatalf("setJSON() failed: %v", err)
+	}
+	got := leafMap(t, ds.get(mustPath("/interfaces/interface[name=eth1]/state")))
+	want := map[string]interface{}{
+		"/interfaces/interface[name=eth1]/state/name":        "eth1",
+		"/interfaces/interface[name=eth1]/state/description": "uplink",
+		"/interfaces/interface[name=eth1]/state/mtu":         int64(9000),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("get() of mirrored state returned diff (-want +got):\n%s", diff)
+	}
+
+	got = leafMap(t, ds.get(mustPath("/interfaces/interface[name=*]/config/enabled")))
+	want = map[string]interface{}{
+		"/interfaces/interface[name=eth2]/config/enabled": false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("get() with wildcard returned diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReplacePreservesSimulatedState(t *testing.T) {
+	ds := newDatastore()
+	ds.setLeaf(mustPath("/interfaces/interface[name=eth1]/state/oper-status"), "UP")
+	if err := ds.setJSON(&gpb.Path{}, []byte(intfJSON), true); err != nil {
+		t.Fatalf("setJSON() failed: %v", err)
+	}
+	if err := ds.setJSON(&gpb.Path{}, []byte(`{}`), true); err != nil {
+		t.Fatalf("setJSON() failed: %v", err)
+	}
+	got := leafMap(t, ds.get(mustPath("/interfaces")))
+	want := map[string]interface{}{
+		"/interfaces/interface[name=eth1]/state/oper-status": "UP",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("get() after replace returned diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	ds := newDatastore()
+	if err := ds.setJSON(&gpb.Path{}, []byte(intfJSON), true); err != nil {
+		t.Fatalf("setJSON() failed: %v", err)
+	}
+	data, err := ds.getJSON(mustPath("/interfaces/interface[name=eth1]/config"))
+	if err != nil {
+		t.Fatalf("getJSON() failed: %v", err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("getJSON() returned invalid JSON: %v", err)
+	}
+	want := map[string]interface{}{"name": "eth1", "description": "uplink", "mtu": float64(9000)}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("getJSON() returned diff (-want +got):\n%s", diff)
+	}
+
+	data, err = ds.getJSON(mustPath("/interfaces/interface[name=eth2]/config/enabled"))
+	if err != nil {
+		t.Fatalf("getJSON() failed: %v", err)
+	}
+	if got, want := string(data), "false"; got != want {
+		t.Errorf("getJSON() of leaf got %q, want %q", got, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ds := newDatastore()
+	w := ds.watch([]*gpb.Path{mustPath("/interfaces/interface[name=eth1]/state/oper-status")})
+	defer ds.unwatch(w)
+	ds.setLeaf(mustPath("/interfaces/interface[name=eth2]/state/oper-status"), "UP")
+	ds.setLeaf(mustPath("/interfaces/interface[name=eth1]/state/oper-status"), "UP")
+	// Setting an unchanged value should not notify the watcher.
+	ds.setLeaf(mustPath("/interfaces/interface[name=eth1]/state/oper-status"), "UP")
+	<-w.signal
+	cs := w.pop()
+	if len(cs) != 1 || len(cs[0].updates) != 1 {
+		t.Fatalf("watcher got changes %v, want exactly one update", cs)
+	}
+	if got, want := pathKey(cs[0].updates[0].path), "/interfaces/interface[name=eth1]/state/oper-status"; got != want {
+		t.Errorf("watcher got update at %q, want %q", got, want)
+	}
+}
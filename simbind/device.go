@@ -0,0 +1,170 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/local"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	spb "github.com/openconfig/gnoi/system"
+)
+
+// rebootStatusMethod is exempt from the reboot outage, so that callers can
+// learn when the reboot completes.
+const rebootStatusMethod = "/gnoi.system.System/RebootStatus"
+
+// device is a simulated DUT or ATE, serving gRPC APIs on a local listener.
+type device struct {
+	dims        *reservation.Dims
+	ds          *datastore
+	lis         net.Listener
+	srv         *grpc.Server
+	rebootDelay time.Duration
+
+	mu           sync.Mutex
+	rebootStart  time.Time
+	rebootUntil  time.Time
+	rebootReason string
+	// vendorConfig is the last vendor-native config text pushed to the device.
+	vendorConfig string
+}
+
+func startDevice(dims *reservation.Dims, rebootDelay time.Duration) (*device, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen for device %s", dims.Name)
+	}
+	d := &device{
+		dims:        dims,
+		ds:          newDatastore(),
+		lis:         lis,
+		rebootDelay: rebootDelay,
+	}
+	d.srv = grpc.NewServer(
+		grpc.Creds(local.NewCredentials()),
+		grpc.UnaryInterceptor(d.unaryInterceptor),
+		grpc.StreamInterceptor(d.streamInterceptor))
+	gpb.RegisterGNMIServer(d.srv, &gnmiServer{ds: d.ds})
+	spb.RegisterSystemServer(d.srv, &systemServer{dev: d})
+	go d.srv.Serve(lis)
+
+	now := time.Now().UnixNano()
+	d.ds.setLeaf(mustPath("/system/state/hostname"), dims.Name)
+	d.ds.setLeaf(mustPath("/system/state/boot-time"), uint64(now))
+	chassis := "/components/component[name=chassis]/state/"
+	d.ds.setLeaf(mustPath(chassis+"name"), "chassis")
+	d.ds.setLeaf(mustPath(chassis+"type"), "openconfig-platform-types:CHASSIS")
+	d.ds.setLeaf(mustPath(chassis+"mfg-name"), dims.Vendor.String())
+	d.ds.setLeaf(mustPath(chassis+"part-no"), dims.HardwareModel)
+	d.ds.setLeaf(mustPath(chassis+"software-version"), dims.SoftwareVersion)
+	d.ds.setLeaf(mustPath(chassis+"serial-no"), fmt.Sprintf("SIM%08X", now&0xFFFFFFFF))
+	for _, p := range dims.Ports {
+		intf := fmt.Sprintf("/interfaces/interface[name=%s]/", p.Name)
+		d.ds.setLeaf(mustPath(intf+"name"), p.Name)
+		d.ds.setLeaf(mustPath(intf+"state/name"), p.Name)
+		d.ds.setLeaf(mustPath(intf+"state/type"), "iana-if-type:ethernetCsmacd")
+	}
+	return d, nil
+}
+
+func (d *device) String() string {
+	return fmt.Sprintf("simulated device %s@%s", d.dims.Name, d.lis.Addr())
+}
+
+func (d *device) dial(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	addr := d.lis.Addr().String()
+	opts = append(opts, grpc.WithTransportCredentials(local.NewCredentials()))
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "DialContext(ctx, %s, %v)", addr, opts)
+	}
+	return conn, nil
+}
+
+func (d *device) stop() {
+	d.srv.Stop()
+}
+
+// reboot starts a reboot, during which all RPCs but RebootStatus fail.
+func (d *device) reboot(reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rebootStart = time.Now()
+	d.rebootUntil = d.rebootStart.Add(d.rebootDelay)
+	d.rebootReason = reason
+	d.ds.setLeaf(mustPath("/system/state/boot-time"), uint64(d.rebootUntil.UnixNano()))
+}
+
+func (d *device) rebooting() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return time.Now().Before(d.rebootUntil)
+}
+
+func (d *device) rebootStatus() *spb.RebootStatusResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resp := &spb.RebootStatusResponse{
+		When:   uint64(d.rebootStart.UnixNano()),
+		Reason: d.rebootReason,
+	}
+	if left := time.Until(d.rebootUntil); left > 0 {
+		resp.Active = true
+		resp.Wait = uint64(left.Nanoseconds())
+	}
+	return resp
+}
+
+func (d *device) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != rebootStatusMethod && d.rebooting() {
+		return nil, status.Errorf(codes.Unavailable, "%s is rebooting", d.dims.Name)
+	}
+	return handler(ctx, req)
+}
+
+func (d *device) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if d.rebooting() {
+		return status.Errorf(codes.Unavailable, "%s is rebooting", d.dims.Name)
+	}
+	return handler(srv, ss)
+}
+
+// pushVendorConfig replaces or appends to the vendor-native config text.
+func (d *device) pushVendorConfig(config string, append bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if append && d.vendorConfig != "" {
+		config = strings.TrimSuffix(d.vendorConfig, "\n") + "\n" + config
+	}
+	d.vendorConfig = config
+}
+
+func (d *device) runningVendorConfig() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.vendorConfig
+}
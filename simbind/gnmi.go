@@ -0,0 +1,164 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"golang.org/x/net/context"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// gnmiServer serves gNMI from a simulated device's datastore.
+type gnmiServer struct {
+	gpb.UnimplementedGNMIServer
+	ds *datastore
+}
+
+func (s *gnmiServer) Capabilities(context.Context, *gpb.CapabilityRequest) (*gpb.CapabilityResponse, error) {
+	return &gpb.CapabilityResponse{
+		SupportedEncodings: []gpb.Encoding{gpb.Encoding_JSON_IETF, gpb.Encoding_PROTO},
+		GNMIVersion:        "0.7.0",
+	}, nil
+}
+
+func (s *gnmiServer) Get(_ context.Context, req *gpb.GetRequest) (*gpb.GetResponse, error) {
+	resp := &gpb.GetResponse{}
+	for _, p := range req.GetPath() {
+		p = joinPath(req.GetPrefix(), p)
+		n := &gpb.Notification{Timestamp: time.Now().UnixNano()}
+		switch req.GetEncoding() {
+		case gpb.Encoding_JSON_IETF, gpb.Encoding_JSON:
+			if len(s.ds.get(p)) == 0 {
+				return nil, status.Errorf(codes.NotFound, "no data at path %v", p)
+			}
+			data, err := s.ds.getJSON(p)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to encode path %v: %v", p, err)
+			}
+			n.Update = []*gpb.Update{{
+				Path: p,
+				Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: data}},
+			}}
+		default:
+			ls := s.ds.get(p)
+			if len(ls) == 0 {
+				return nil, status.Errorf(codes.NotFound, "no data at path %v", p)
+			}
+			for _, l := range ls {
+				n.Update = append(n.Update, &gpb.Update{Path: l.path, Val: l.val})
+			}
+		}
+		resp.Notification = append(resp.Notification, n)
+	}
+	return resp, nil
+}
+
+func (s *gnmiServer) Set(_ context.Context, req *gpb.SetRequest) (*gpb.SetResponse, error) {
+	resp, err := s.ds.set(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, nil
+}
+
+func (s *gnmiServer) Subscribe(stream gpb.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	sl := req.GetSubscribe()
+	if sl == nil {
+		return status.Errorf(codes.InvalidArgument, "first request must be a subscription list: %v", req)
+	}
+	var paths []*gpb.Path
+	for _, sub := range sl.GetSubscription() {
+		paths = append(paths, joinPath(sl.GetPrefix(), sub.GetPath()))
+	}
+	prefix := &gpb.Path{Origin: sl.GetPrefix().GetOrigin(), Target: sl.GetPrefix().GetTarget()}
+
+	switch sl.GetMode() {
+	case gpb.SubscriptionList_ONCE:
+		return s.sendAll(stream, prefix, paths)
+	case gpb.SubscriptionList_POLL:
+		for {
+			if err := s.sendAll(stream, prefix, paths); err != nil {
+				return err
+			}
+			req, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Errorf(codes.InvalidArgument, "expected poll request, got %v", req)
+			}
+		}
+	}
+
+	// Register the watcher before the initial sync, so no change is missed.
+	w := s.ds.watch(paths)
+	defer s.ds.unwatch(w)
+	if !sl.GetUpdatesOnly() {
+		if err := s.sendAll(stream, prefix, paths); err != nil {
+			return err
+		}
+	} else if err := sendSync(stream); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-w.signal:
+		}
+		for _, c := range w.pop() {
+			n := &gpb.Notification{Prefix: prefix, Timestamp: c.ts, Delete: c.deletes}
+			for _, l := range c.updates {
+				n.Update = append(n.Update, &gpb.Update{Path: l.path, Val: l.val})
+			}
+			if err := stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: n}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendAll sends the current value of every leaf matching the paths, then a sync.
+func (s *gnmiServer) sendAll(stream gpb.GNMI_SubscribeServer, prefix *gpb.Path, paths []*gpb.Path) error {
+	for _, p := range paths {
+		for _, l := range s.ds.get(p) {
+			n := &gpb.Notification{
+				Prefix:    prefix,
+				Timestamp: l.ts,
+				Update:    []*gpb.Update{{Path: l.path, Val: l.val}},
+			}
+			if err := stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: n}}); err != nil {
+				return err
+			}
+		}
+	}
+	return sendSync(stream)
+}
+
+func sendSync(stream gpb.GNMI_SubscribeServer) error {
+	return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"golang.org/x/net/context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	spb "github.com/openconfig/gnoi/system"
+)
+
+const defaultPingCount = 5
+
+// systemServer serves the gNOI System service of a simulated device.
+type systemServer struct {
+	spb.UnimplementedSystemServer
+	dev *device
+}
+
+func (s *systemServer) Ping(req *spb.PingRequest, stream spb.System_PingServer) error {
+	if req.GetDestination() == "" {
+		return status.Error(codes.InvalidArgument, "no destination in ping request")
+	}
+	count := req.GetCount()
+	if count <= 0 {
+		count = defaultPingCount
+	}
+	for i := int32(1); i <= count; i++ {
+		if err := stream.Send(&spb.PingResponse{
+			Source:   req.GetDestination(),
+			Time:     int64(time.Millisecond),
+			Bytes:    req.GetSize(),
+			Sequence: i,
+			Ttl:      64,
+		}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&spb.PingResponse{
+		Source:   req.GetDestination(),
+		Sent:     count,
+		Received: count,
+		MinTime:  int64(time.Millisecond),
+		AvgTime:  int64(time.Millisecond),
+		MaxTime:  int64(time.Millisecond),
+	})
+}
+
+func (s *systemServer) Reboot(_ context.Context, req *spb.RebootRequest) (*spb.RebootResponse, error) {
+	switch req.GetMethod() {
+	case spb.RebootMethod_COLD, spb.RebootMethod_WARM, spb.RebootMethod_UNKNOWN:
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "reboot method %v not supported", req.GetMethod())
+	}
+	s.dev.reboot(req.GetMessage())
+	return &spb.RebootResponse{}, nil
+}
+
+func (s *systemServer) RebootStatus(context.Context, *spb.RebootStatusRequest) (*spb.RebootStatusResponse, error) {
+	return s.dev.rebootStatus(), nil
+}
+
+func (s *systemServer) Time(context.Context, *spb.TimeRequest) (*spb.TimeResponse, error) {
+	return &spb.TimeResponse{Time: uint64(time.Now().UnixNano())}, nil
+}
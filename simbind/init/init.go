@@ -0,0 +1,35 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package init installs the Ondatra binding for testing with simulated
+// devices. It also installs a --sim_reboot_delay flag, which sets how long a
+// simulated device is unavailable after a reboot.
+package init
+
+import (
+	"flag"
+
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/simbind"
+)
+
+var (
+	rebootDelay = flag.Duration("sim_reboot_delay", 0, "how long a simulated device is unavailable after a reboot")
+)
+
+// Init provides a generator for a simulation bind instance which uses the
+// configuration set by flags. To be used with ondatra.RunTests.
+func Init() (binding.Binding, error) {
+	return simbind.New(&simbind.Config{RebootDelay: *rebootDelay})
+}
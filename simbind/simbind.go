@@ -0,0 +1,735 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simbind provides an Ondatra binding that simulates the testbed
+// in memory, so that Ondatra tests can run hermetically without a lab.
+package simbind
+
+import (
+	"golang.org/x/net/context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"github.com/pborman/uuid"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/usererr"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	opb "github.com/openconfig/ondatra/proto"
+	p4pb "github.com/p4lang/p4runtime/go/p4/v1"
+)
+
+const (
+	defaultDUTVendor = opb.Device_ARISTA
+	defaultATEVendor = opb.Device_IXIA
+	defaultModel     = "SIM"
+	defaultVersion   = "SIM"
+	defaultDimension = "SIM"
+)
+
+// Config contains parameters to configure the simulation binding.
+type Config struct {
+	// RebootDelay is how long a simulated device is unavailable after a reboot.
+	RebootDelay time.Duration
+}
+
+// Bind implements the Ondatra Binding interface with simulated devices.
+type Bind struct {
+	cfg *Config
+
+	mu   sync.Mutex
+	res  *reservation.Reservation
+	devs map[reservation.Device]*device
+	ates map[*reservation.ATE]*ateState
+	// peers maps each linked port to the port on the other end of the link.
+	peers    map[portKey]portKey
+	speeds   map[portKey]opb.Port_Speed
+	counters map[portKey]*portCounters
+	testMD   *binding.TestMetadata
+	// stopRefresh stops the periodic refresh of streamed state.
+	stopRefresh chan struct{}
+}
+
+type portKey struct {
+	dev  *device
+	port string
+}
+
+type portCounters struct {
+	in, out float64
+}
+
+// New returns a new simulation bind instance.
+func New(cfg *Config) (*Bind, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	return &Bind{cfg: cfg}, nil
+}
+
+// Reserve starts a simulated device for every device in the testbed and
+// connects their ports as specified by the testbed links.
+func (b *Bind) Reserve(ctx context.Context, tb *opb.Testbed, runTime, waitTime time.Duration) (_ *reservation.Reservation, rerr error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.res = &reservation.Reservation{
+		ID:   uuid.New(),
+		DUTs: make(map[string]*reservation.DUT),
+		ATEs: make(map[string]*reservation.ATE),
+	}
+	b.devs = make(map[reservation.Device]*device)
+	b.ates = make(map[*reservation.ATE]*ateState)
+	b.peers = make(map[portKey]portKey)
+	b.speeds = make(map[portKey]opb.Port_Speed)
+	b.counters = make(map[portKey]*portCounters)
+	defer func() {
+		if rerr != nil {
+			b.stopAllLocked()
+		}
+	}()
+
+	id2Dev := make(map[string]*device)
+	for i, d := range tb.GetDuts() {
+		dims, err := simDims(d, defaultDUTVendor, fmt.Sprintf("sim-dut%d", i+1), "Ethernet%d")
+		if err != nil {
+			return nil, err
+		}
+		dut := &reservation.DUT{Dims: dims}
+		dev, err := b.startDeviceLocked(dut, dims, d)
+		if err != nil {
+			return nil, err
+		}
+		dev.ds.onRead = b.refresh
+		dev.ds.onWrite = b.refreshLinks
+		b.res.DUTs[d.GetId()] = dut
+		id2Dev[d.GetId()] = dev
+	}
+	for i, a := range tb.GetAtes() {
+		dims, err := simDims(a, defaultATEVendor, fmt.Sprintf("sim-ate%d", i+1), "1/%d")
+		if err != nil {
+			return nil, err
+		}
+		ate := &reservation.ATE{Dims: dims}
+		dev, err := b.startDeviceLocked(ate, dims, a)
+		if err != nil {
+			return nil, err
+		}
+		dev.ds.onRead = b.refresh
+		b.ates[ate] = newATEState(dev)
+		b.res.ATEs[a.GetId()] = ate
+		id2Dev[a.GetId()] = dev
+	}
+	for _, l := range tb.GetLinks() {
+		ka, err := linkEnd(l.GetA(), id2Dev)
+		if err != nil {
+			return nil, err
+		}
+		kb, err := linkEnd(l.GetB(), id2Dev)
+		if err != nil {
+			return nil, err
+		}
+		b.peers[ka] = kb
+		b.peers[kb] = ka
+		for _, k := range []portKey{ka, kb} {
+			rp := reservedPort(k)
+			if rp.Speed < l.GetMinSpeed() {
+				rp.Speed = l.GetMinSpeed()
+				b.speeds[k] = rp.Speed
+			}
+			rp.LAGMember = rp.LAGMember || l.GetLagMember()
+		}
+	}
+	b.refreshLinksLocked()
+	b.stopRefresh = make(chan struct{})
+	go b.refreshPeriodically(b.stopRefresh)
+	return b.res, nil
+}
+
+// refreshInterval is how often time-dependent state is refreshed, so that
+// streaming subscriptions observe changing counters.
+const refreshInterval = time.Second
+
+func (b *Bind) refreshPeriodically(stop <-chan struct{}) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.refresh()
+		}
+	}
+}
+
+func (b *Bind) startDeviceLocked(rd reservation.Device, dims *reservation.Dims, d *opb.Device) (*device, error) {
+	dev, err := startDevice(dims, b.cfg.RebootDelay)
+	if err != nil {
+		return nil, err
+	}
+	b.devs[rd] = dev
+	for _, p := range d.GetPorts() {
+		k := portKey{dev, dims.Ports[p.GetId()].Name}
+		b.speeds[k] = dims.Ports[p.GetId()].Speed
+		b.counters[k] = &portCounters{}
+	}
+	return dev, nil
+}
+
+// linkEnd resolves a "<device-id>:<port-id>" link end to a simulated port.
+func linkEnd(end string, id2Dev map[string]*device) (portKey, error) {
+	parts := strings.SplitN(end, ":", 2)
+	if len(parts) != 2 {
+		return portKey{}, usererr.New("invalid link end %q", end)
+	}
+	dev, ok := id2Dev[parts[0]]
+	if !ok {
+		return portKey{}, usererr.New("link end %q references unknown device", end)
+	}
+	p, err := dev.dims.Port(parts[1])
+	if err != nil {
+		return portKey{}, usererr.Wrap(err)
+	}
+	return portKey{dev, p.Name}, nil
+}
+
+// reservedPort returns the reserved port of a simulated port.
+func reservedPort(k portKey) *reservation.Port {
+	for _, p := range k.dev.dims.Ports {
+		if p.Name == k.port {
+			return p
+		}
+	}
+	return nil
+}
+
+// simDims returns the simulated dimensions for a testbed device.
+func simDims(d *opb.Device, defaultVendor opb.Device_Vendor, name, portFormat string) (*reservation.Dims, error) {
+	vendor := d.GetVendor()
+	if vendor == opb.Device_UNKNOWN {
+		vendor = defaultVendor
+	}
+	model, err := simValue(d.GetHardwareModel(), defaultModel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot simulate hardware model of device %q", d.GetId())
+	}
+	version, err := simValue(d.GetSoftwareVersion(), defaultVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot simulate software version of device %q", d.GetId())
+	}
+	dims := &reservation.Dims{
+		Name:            name,
+		Vendor:          vendor,
+		HardwareModel:   model,
+		SoftwareVersion: version,
+		Ports:           make(map[string]*reservation.Port),
+	}
+	for k, v := range d.GetExtraDimensions() {
+		if dims.ExtraDimensions == nil {
+			dims.ExtraDimensions = make(map[string]string)
+		}
+		if dims.ExtraDimensions[k], err = simValue(v, defaultDimension); err != nil {
+			return nil, errors.Wrapf(err, "cannot simulate extra dimension %q of device %q", k, d.GetId())
+		}
+	}
+	// The ports of a breakout group are simulated as breakout channels of the
+	// physical port named after the first port in the group.
+	breakouts := make(map[string]string)
+	for i, p := range d.GetPorts() {
+		rp := &reservation.Port{
+			Name:  fmt.Sprintf(portFormat, i+1),
+			Speed: p.GetSpeed(),
+			PMD:   p.GetPmd(),
+		}
+		if g := p.GetGroup(); g != "" {
+			if _, ok := breakouts[g]; !ok {
+				breakouts[g] = rp.Name
+			}
+			rp.Breakout = breakouts[g]
+		}
+		dims.Ports[p.GetId()] = rp
+	}
+	return dims, nil
+}
+
+const regexPrefix = "regex:"
+
+// simValue returns a value satisfying a testbed criterion; for a regex
+// criterion, it synthesizes a string that matches the regex.
+func simValue(criterion, defaultValue string) (string, error) {
+	if criterion == "" {
+		return defaultValue, nil
+	}
+	if !strings.HasPrefix(criterion, regexPrefix) {
+		return criterion, nil
+	}
+	expr := strings.TrimPrefix(criterion, regexPrefix)
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return "", usererr.Wrapf(err, "invalid regex %q", expr)
+	}
+	if re.MatchString(defaultValue) {
+		return defaultValue, nil
+	}
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", usererr.Wrapf(err, "invalid regex %q", expr)
+	}
+	var sb strings.Builder
+	sampleRegex(parsed.Simplify(), &sb)
+	if s := sb.String(); re.MatchString(s) {
+		return s, nil
+	}
+	return "", errors.Errorf("no simulated value matches regex %q", expr)
+}
+
+// sampleRegex writes a short string matched by the regex.
+func sampleRegex(re *syntax.Regexp, sb *strings.Builder) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) > 0 {
+			sb.WriteRune(re.Rune[0])
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteRune('x')
+	case syntax.OpCapture, syntax.OpPlus:
+		sampleRegex(re.Sub[0], sb)
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			sampleRegex(re.Sub[0], sb)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			sampleRegex(sub, sb)
+		}
+	case syntax.OpAlternate:
+		sampleRegex(re.Sub[0], sb)
+	}
+}
+
+// Release stops all the simulated devices.
+func (b *Bind) Release(context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopAllLocked()
+	b.res = nil
+	return nil
+}
+
+func (b *Bind) stopAllLocked() {
+	if b.stopRefresh != nil {
+		close(b.stopRefresh)
+		b.stopRefresh = nil
+	}
+	for _, dev := range b.devs {
+		dev.stop()
+	}
+	b.devs = nil
+	b.ates = nil
+}
+
+func (b *Bind) device(rd reservation.Device) (*device, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, ok := b.devs[rd]
+	if !ok {
+		return nil, errors.Errorf("device %v is not reserved", rd)
+	}
+	return dev, nil
+}
+
+func (b *Bind) ate(ate *reservation.ATE) (*ateState, error) {
+	a, ok := b.ates[ate]
+	if !ok {
+		return nil, errors.Errorf("ATE %v is not reserved", ate)
+	}
+	return a, nil
+}
+
+// PushConfig applies OpenConfig JSON to the device's datastore, or records
+// vendor config text as the device's running config.
+func (b *Bind) PushConfig(ctx context.Context, dut *reservation.DUT, config string, opts *binding.ConfigOptions) error {
+	dev, err := b.device(dut)
+	if err != nil {
+		return err
+	}
+	if dev.rebooting() {
+		return errors.Errorf("cannot push config to %s while it is rebooting", dut.Name)
+	}
+	if !opts.OpenConfig {
+		dev.pushVendorConfig(config, opts.Append)
+		return nil
+	}
+	if err := dev.ds.setJSON(&gpb.Path{}, []byte(config), !opts.Append); err != nil {
+		return usererr.Wrapf(err, "invalid OpenConfig pushed to %s", dut.Name)
+	}
+	return nil
+}
+
+// DialGNMI creates a client connection to the simulated DUT's gNMI server.
+func (b *Bind) DialGNMI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (gpb.GNMIClient, error) {
+	conn, err := b.dial(ctx, dut, opts)
+	if err != nil {
+		return nil, err
+	}
+	return gpb.NewGNMIClient(conn), nil
+}
+
+// DialGNOI creates a client connection to the simulated DUT's gNOI server.
+// Only the System service is implemented.
+func (b *Bind) DialGNOI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.GNOIClients, error) {
+	conn, err := b.dial(ctx, dut, opts)
+	if err != nil {
+		return nil, err
+	}
+	return binding.NewGNOIClients(conn), nil
+}
+
+// DialP4RT creates a client connection to the simulated DUT.
+// P4RT is not simulated, so all its RPCs return an Unimplemented error.
+func (b *Bind) DialP4RT(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (p4pb.P4RuntimeClient, error) {
+	conn, err := b.dial(ctx, dut, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p4pb.NewP4RuntimeClient(conn), nil
+}
+
+// DialATEGNMI creates a client connection to the simulated ATE's gNMI server.
+func (b *Bind) DialATEGNMI(ctx context.Context, ate *reservation.ATE, opts ...grpc.DialOption) (gpb.GNMIClient, error) {
+	conn, err := b.dial(ctx, ate, opts)
+	if err != nil {
+		return nil, err
+	}
+	return gpb.NewGNMIClient(conn), nil
+}
+
+func (b *Bind) dial(ctx context.Context, rd reservation.Device, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	dev, err := b.device(rd)
+	if err != nil {
+		return nil, err
+	}
+	return dev.dial(ctx, opts...)
+}
+
+// DialConsole returns a simulated console client for the DUT.
+func (b *Bind) DialConsole(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.StreamClient, error) {
+	return b.DialCLI(ctx, dut, opts...)
+}
+
+// DialCLI returns a simulated CLI client for the DUT.
+func (b *Bind) DialCLI(ctx context.Context, dut *reservation.DUT, opts ...grpc.DialOption) (binding.StreamClient, error) {
+	dev, err := b.device(dut)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamClient(dev), nil
+}
+
+// PushTopology replaces the topology of the ATE, stopping protocols.
+func (b *Bind) PushTopology(ate *reservation.ATE, top *opb.Topology) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	a.top = top
+	a.protocolsRunning = false
+	return nil
+}
+
+// UpdateTopology replaces the topology of the ATE, leaving protocols running.
+func (b *Bind) UpdateTopology(ate *reservation.ATE, top *opb.Topology) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	if a.top == nil {
+		return usererr.New("no topology pushed to %s", ate.Name)
+	}
+	a.top = top
+	return nil
+}
+
+// UpdateBGPPeerStates updates the BGP config of the ATE interfaces.
+// TODO: Remove this method once new Ixia config binding is used.
+func (b *Bind) UpdateBGPPeerStates(ate *reservation.ATE, interfaces []*opb.InterfaceConfig) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	for _, i := range interfaces {
+		for _, ti := range a.top.GetInterfaces() {
+			if ti.GetName() == i.GetName() {
+				ti.Bgp = i.GetBgp()
+			}
+		}
+	}
+	return nil
+}
+
+// StartProtocols starts the simulated control plane protocols on the ATE.
+func (b *Bind) StartProtocols(ate *reservation.ATE) error {
+	return b.setProtocols(ate, true)
+}
+
+// StopProtocols stops the simulated control plane protocols on the ATE.
+func (b *Bind) StopProtocols(ate *reservation.ATE) error {
+	return b.setProtocols(ate, false)
+}
+
+func (b *Bind) setProtocols(ate *reservation.ATE, running bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	if a.top == nil {
+		return usererr.New("no topology pushed to %s", ate.Name)
+	}
+	a.protocolsRunning = running
+	return nil
+}
+
+// StartTraffic starts simulated traffic flows on the ATE.
+func (b *Bind) StartTraffic(ate *reservation.ATE, flows []*opb.Flow) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	if a.top == nil {
+		return usererr.New("no topology pushed to %s", ate.Name)
+	}
+	now := time.Now()
+	b.advanceLocked(now)
+	started := make(map[string]*flowState)
+	for _, f := range flows {
+		fs, err := a.newFlow(f, b.lineRateFunc(a.dev))
+		if err != nil {
+			return usererr.Wrap(err)
+		}
+		fs.running = true
+		fs.last = now
+		started[f.GetName()] = fs
+	}
+	a.flows = started
+	b.writeCountersLocked()
+	return nil
+}
+
+// UpdateTraffic updates the rate and size of running traffic flows on the ATE.
+func (b *Bind) UpdateTraffic(ate *reservation.ATE, flows []*opb.Flow) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	b.advanceLocked(now)
+	for _, f := range flows {
+		old, ok := a.flows[f.GetName()]
+		if !ok || !old.running {
+			return usererr.New("flow %q has not been started on %s", f.GetName(), ate.Name)
+		}
+		fs, err := a.newFlow(f, b.lineRateFunc(a.dev))
+		if err != nil {
+			return usererr.Wrap(err)
+		}
+		fs.running, fs.last, fs.sent, fs.recv = true, now, old.sent, old.recv
+		a.flows[f.GetName()] = fs
+	}
+	return nil
+}
+
+// StopTraffic stops all simulated traffic flows on the ATE.
+func (b *Bind) StopTraffic(ate *reservation.ATE) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	b.advanceLocked(time.Now())
+	for _, fs := range a.flows {
+		fs.running = false
+	}
+	b.writeCountersLocked()
+	return nil
+}
+
+// HandleInfraFail logs the error and returns it unchanged.
+func (b *Bind) HandleInfraFail(err error) error {
+	log.Errorf("Infrastructure failure: %v", err)
+	return err
+}
+
+// SetATEPortState sets the enabled state of a simulated ATE port, which also
+// brings down the link to the port on the other end.
+func (b *Bind) SetATEPortState(ate *reservation.ATE, port string, enabled bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, err := b.ate(ate)
+	if err != nil {
+		return err
+	}
+	b.advanceLocked(time.Now())
+	a.disabledPorts[port] = !enabled
+	b.refreshLinksLocked()
+	return nil
+}
+
+// SetTestMetadata records the metadata of the running test.
+func (b *Bind) SetTestMetadata(md *binding.TestMetadata) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.testMD = md
+	return nil
+}
+
+// RestartRouting simulates a routing restart, which always succeeds.
+func (b *Bind) RestartRouting(dut *reservation.DUT) error {
+	dev, err := b.device(dut)
+	if err != nil {
+		return err
+	}
+	if dev.rebooting() {
+		return errors.Errorf("cannot restart routing on %s while it is rebooting", dut.Name)
+	}
+	return nil
+}
+
+func (b *Bind) lineRateFunc(dev *device) func(string) float64 {
+	return func(port string) float64 {
+		if s := b.speeds[portKey{dev, port}]; s != opb.Port_S_UNKNOWN {
+			return float64(s) * 1e9
+		}
+		return defaultLineRate
+	}
+}
+
+// enabledLocked returns whether a port is administratively enabled.
+func (b *Bind) enabledLocked(k portKey) bool {
+	for _, a := range b.ates {
+		if a.dev == k.dev {
+			return !a.disabledPorts[k.port]
+		}
+	}
+	v := k.dev.ds.leafValue(mustPath(fmt.Sprintf("/interfaces/interface[name=%s]/config/enabled", k.port)))
+	return v == nil || v.GetBoolVal()
+}
+
+// upLocked returns whether a port is operationally up, which requires it and
+// its peer to be enabled.
+func (b *Bind) upLocked(k portKey) bool {
+	peer, ok := b.peers[k]
+	return ok && b.enabledLocked(k) && b.enabledLocked(peer) && !k.dev.rebooting() && !peer.dev.rebooting()
+}
+
+func (b *Bind) refreshLinks() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refreshLinksLocked()
+}
+
+// refreshLinksLocked updates the admin and oper status of every port.
+func (b *Bind) refreshLinksLocked() {
+	for k := range b.counters {
+		intf := fmt.Sprintf("/interfaces/interface[name=%s]/state/", k.port)
+		admin, oper := "UP", "UP"
+		if !b.enabledLocked(k) {
+			admin = "DOWN"
+		}
+		if !b.upLocked(k) {
+			oper = "DOWN"
+		}
+		k.dev.ds.setLeaf(mustPath(intf+"admin-status"), admin)
+		k.dev.ds.setLeaf(mustPath(intf+"oper-status"), oper)
+	}
+}
+
+// refresh brings the time-dependent state of all devices up to date.
+func (b *Bind) refresh() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked(time.Now())
+	b.refreshLinksLocked()
+	b.writeCountersLocked()
+}
+
+// advanceLocked accumulates the packets of all running flows up to now.
+func (b *Bind) advanceLocked(now time.Time) {
+	for _, a := range b.ates {
+		for _, fs := range a.flows {
+			up := true
+			for _, p := range append(append([]string{}, fs.srcPorts...), fs.dstPorts...) {
+				up = up && b.upLocked(portKey{a.dev, p})
+			}
+			sent, recv := fs.advance(now, up)
+			if sent == 0 {
+				continue
+			}
+			for _, p := range fs.srcPorts {
+				k := portKey{a.dev, p}
+				b.counters[k].out += sent / float64(len(fs.srcPorts))
+				if peer, ok := b.peers[k]; ok && up {
+					b.counters[peer].in += sent / float64(len(fs.srcPorts))
+				}
+			}
+			for _, p := range fs.dstPorts {
+				k := portKey{a.dev, p}
+				b.counters[k].in += recv / float64(len(fs.dstPorts))
+				if peer, ok := b.peers[k]; ok {
+					b.counters[peer].out += recv / float64(len(fs.dstPorts))
+				}
+			}
+		}
+	}
+}
+
+// writeCountersLocked writes the flow and port counters to the datastores.
+func (b *Bind) writeCountersLocked() {
+	for _, a := range b.ates {
+		for name, fs := range a.flows {
+			a.dev.ds.setLeaf(mustPath(flowPath(name, "name")), name)
+			a.dev.ds.setLeaf(mustPath(flowPath(name, "counters/out-pkts")), uint64(fs.sent))
+			a.dev.ds.setLeaf(mustPath(flowPath(name, "counters/in-pkts")), uint64(fs.recv))
+			a.dev.ds.setLeaf(mustPath(flowPath(name, "counters/out-octets")), uint64(fs.sent*fs.frameSize))
+			a.dev.ds.setLeaf(mustPath(flowPath(name, "counters/in-octets")), uint64(fs.recv*fs.frameSize))
+		}
+	}
+	for k, c := range b.counters {
+		intf := fmt.Sprintf("/interfaces/interface[name=%s]/state/counters/", k.port)
+		k.dev.ds.setLeaf(mustPath(intf+"in-pkts"), uint64(c.in))
+		k.dev.ds.setLeaf(mustPath(intf+"out-pkts"), uint64(c.out))
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"golang.org/x/net/context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gnmi/errdiff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"github.com/openconfig/ondatra/internal/binding"
+	"github.com/openconfig/ondatra/internal/reservation"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	spb "github.com/openconfig/gnoi/system"
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+var testbed = &opb.Testbed{
+	Duts: []*opb.Device{{
+		Id:              "dut",
+		Vendor:          opb.Device_CISCO,
+		HardwareModel:   "regex:8[0-9]{3}",
+		SoftwareVersion: "7.4.1",
+		Ports:           []*opb.Port{{Id: "port1"}, {Id: "port2", Speed: opb.Port_S_100GB}},
+	}},
+	Ates: []*opb.Device{{
+		Id:    "ate",
+		Ports: []*opb.Port{{Id: "port1"}, {Id: "port2"}},
+	}},
+	Links: []*opb.Link{
+		{A: "dut:port1", B: "ate:port1"},
+		{A: "dut:port2", B: "ate:port2"},
+	},
+}
+
+func reserve(t *testing.T, cfg *Config) (*Bind, *reservation.Reservation) {
+	t.Helper()
+	b, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	res, err := b.Reserve(context.Background(), testbed, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve() failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := b.Release(context.Background()); err != nil {
+			t.Errorf("Release() failed: %v", err)
+		}
+	})
+	return b, res
+}
+
+func getLeaf(t *testing.T, c gpb.GNMIClient, path string) *gpb.TypedValue {
+	t.Helper()
+	resp, err := c.Get(context.Background(), &gpb.GetRequest{
+		Path:     []*gpb.Path{mustPath(path)},
+		Encoding: gpb.Encoding_PROTO,
+	})
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", path, err)
+	}
+	return resp.GetNotification()[0].GetUpdate()[0].GetVal()
+}
+
+func TestReserve(t *testing.T) {
+	_, res := reserve(t, &Config{})
+	wantDUT := &reservation.DUT{Dims: &reservation.Dims{
+		Name:            "sim-dut1",
+		Vendor:          opb.Device_CISCO,
+		HardwareModel:   "8000",
+		SoftwareVersion: "7.4.1",
+		Ports: map[string]*reservation.Port{
+			"port1": {Name: "Ethernet1"},
+			"port2": {Name: "Ethernet2", Speed: opb.Port_S_100GB},
+		},
+	}}
+	if diff := cmp.Diff(wantDUT, res.DUTs["dut"]); diff != "" {
+		t.Errorf("Reserve() returned DUT diff (-want +got):\n%s", diff)
+	}
+	wantATE := &reservation.ATE{Dims: &reservation.Dims{
+		Name:            "sim-ate1",
+		Vendor:          opb.Device_IXIA,
+		HardwareModel:   "SIM",
+		SoftwareVersion: "SIM",
+		Ports: map[string]*reservation.Port{
+			"port1": {Name: "1/1"},
+			"port2": {Name: "1/2"},
+		},
+	}}
+	if diff := cmp.Diff(wantATE, res.ATEs["ate"]); diff != "" {
+		t.Errorf("Reserve() returned ATE diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReserveAttributes(t *testing.T) {
+	tb := &opb.Testbed{
+		Duts: []*opb.Device{{
+			Id:              "dut",
+			ExtraDimensions: map[string]string{"role": "spine", "rack": ""},
+			Ports: []*opb.Port{
+				{Id: "port1", Pmd: "ETH_100GBASE_LR4", Group: "g"},
+				{Id: "port2", Group: "g"},
+				{Id: "port3", Speed: opb.Port_S_400GB},
+			},
+		}},
+		Ates: []*opb.Device{{
+			Id:    "ate",
+			Ports: []*opb.Port{{Id: "port1"}, {Id: "port2"}},
+		}},
+		Links: []*opb.Link{
+			{A: "dut:port1", B: "ate:port1", MinSpeed: opb.Port_S_100GB},
+			{A: "dut:port2", B: "ate:port2", LagMember: true},
+		},
+	}
+	b, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	res, err := b.Reserve(context.Background(), tb, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve() failed: %v", err)
+	}
+	defer b.Release(context.Background())
+	if diff := cmp.Diff(map[string]string{"role": "spine", "rack": "SIM"}, res.DUTs["dut"].ExtraDimensions); diff != "" {
+		t.Errorf("Reserve() returned DUT extra dimensions diff (-want +got):\n%s", diff)
+	}
+	wantDUTPorts := map[string]*reservation.Port{
+		"port1": {Name: "Ethernet1", Speed: opb.Port_S_100GB, PMD: "ETH_100GBASE_LR4", Breakout: "Ethernet1"},
+		"port2": {Name: "Ethernet2", Breakout: "Ethernet1", LAGMember: true},
+		"port3": {Name: "Ethernet3", Speed: opb.Port_S_400GB},
+	}
+	if diff := cmp.Diff(wantDUTPorts, res.DUTs["dut"].Ports); diff != "" {
+		t.Errorf("Reserve() returned DUT ports diff (-want +got):\n%s", diff)
+	}
+	wantATEPorts := map[string]*reservation.Port{
+		"port1": {Name: "1/1", Speed: opb.Port_S_100GB},
+		"port2": {Name: "1/2", LAGMember: true},
+	}
+	if diff := cmp.Diff(wantATEPorts, res.ATEs["ate"].Ports); diff != "" {
+		t.Errorf("Reserve() returned ATE ports diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReserveErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		tb      *opb.Testbed
+		wantErr string
+	}{{
+		desc:    "bad regex",
+		tb:      &opb.Testbed{Duts: []*opb.Device{{Id: "dut", HardwareModel: "regex:a(b"}}},
+		wantErr: "invalid regex",
+	}, {
+		desc: "bad link",
+		tb: &opb.Testbed{
+			Duts:  []*opb.Device{{Id: "dut", Ports: []*opb.Port{{Id: "port1"}}}},
+			Links: []*opb.Link{{A: "dut:port1", B: "ate:port1"}},
+		},
+		wantErr: "unknown device",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			b, err := New(&Config{})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			_, err = b.Reserve(context.Background(), tt.tb, time.Minute, time.Minute)
+			if diff := errdiff.Substring(err, tt.wantErr); diff != "" {
+				t.Errorf("Reserve() got unexpected error: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSimValue(t *testing.T) {
+	tests := []struct {
+		criterion, want string
+	}{
+		{"", "SIM"},
+		{"MX480", "MX480"},
+		{"regex:.*", "SIM"},
+		{"regex:DCS-7[0-9]+", "DCS-70"},
+		{"regex:(MX|PTX)[0-9]{3}", "MX000"},
+		{"regex:[a-c]x?", "a"},
+	}
+	for _, tt := range tests {
+		got, err := simValue(tt.criterion, "SIM")
+		if err != nil {
+			t.Errorf("simValue(%q) failed: %v", tt.criterion, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("simValue(%q) got %q, want %q", tt.criterion, got, tt.want)
+		}
+	}
+}
+
+func TestGNMI(t *testing.T) {
+	b, res := reserve(t, &Config{})
+	ctx := context.Background()
+	c, err := b.DialGNMI(ctx, res.DUTs["dut"])
+	if err != nil {
+		t.Fatalf("DialGNMI() failed: %v", err)
+	}
+	descPath := "/interfaces/interface[name=Ethernet1]/config/description"
+	if _, err := c.Set(ctx, &gpb.SetRequest{Update: []*gpb.Update{{
+		Path: mustPath(descPath),
+		Val:  &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: "to ate"}},
+	}}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if got, want := getLeaf(t, c, "/interfaces/interface[name=Ethernet1]/state/description").GetStringVal(), "to ate"; got != want {
+		t.Errorf("Get() of description got %q, want %q", got, want)
+	}
+	if got, want := getLeaf(t, c, "/interfaces/interface[name=Ethernet1]/state/oper-status").GetStringVal(), "UP"; got != want {
+		t.Errorf("Get() of oper-status got %q, want %q", got, want)
+	}
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	if err := sub.Send(&gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{
+		Subscribe: &gpb.SubscriptionList{
+			Mode:         gpb.SubscriptionList_STREAM,
+			Subscription: []*gpb.Subscription{{Path: mustPath("/interfaces/interface[name=Ethernet1]/state/oper-status")}},
+		},
+	}}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	var got []string
+	for len(got) < 2 {
+		resp, err := sub.Recv()
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+		for _, u := range resp.GetUpdate().GetUpdate() {
+			got = append(got, u.GetVal().GetStringVal())
+		}
+		if resp.GetSyncResponse() {
+			if err := b.PushConfig(ctx, res.DUTs["dut"], `{"interfaces": {"interface": [{"name": "Ethernet1", "config": {"enabled": false}}]}}`,
+				&binding.ConfigOptions{OpenConfig: true, Append: true}); err != nil {
+				t.Fatalf("PushConfig() failed: %v", err)
+			}
+		}
+	}
+	if diff := cmp.Diff([]string{"UP", "DOWN"}, got); diff != "" {
+		t.Errorf("Subscribe() got oper-status diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReboot(t *testing.T) {
+	b, res := reserve(t, &Config{RebootDelay: time.Hour})
+	ctx := context.Background()
+	dut := res.DUTs["dut"]
+	gnoi, err := b.DialGNOI(ctx, dut)
+	if err != nil {
+		t.Fatalf("DialGNOI() failed: %v", err)
+	}
+	if _, err := gnoi.System().Reboot(ctx, &spb.RebootRequest{Method: spb.RebootMethod_COLD, Message: "test"}); err != nil {
+		t.Fatalf("Reboot() failed: %v", err)
+	}
+	status1, err := gnoi.System().RebootStatus(ctx, &spb.RebootStatusRequest{})
+	if err != nil {
+		t.Fatalf("RebootStatus() failed: %v", err)
+	}
+	if !status1.GetActive() || status1.GetReason() != "test" {
+		t.Errorf("RebootStatus() got %v, want active reboot with reason %q", status1, "test")
+	}
+	c, err := b.DialGNMI(ctx, dut)
+	if err != nil {
+		t.Fatalf("DialGNMI() failed: %v", err)
+	}
+	_, err = c.Get(ctx, &gpb.GetRequest{Path: []*gpb.Path{mustPath("/system")}})
+	if got, want := status.Code(err), codes.Unavailable; got != want {
+		t.Errorf("Get() during reboot got code %v, want %v", got, want)
+	}
+	if err := b.PushConfig(ctx, dut, "hostname x", &binding.ConfigOptions{}); err == nil {
+		t.Errorf("PushConfig() during reboot succeeded, want error")
+	}
+}
+
+func TestTraffic(t *testing.T) {
+	b, res := reserve(t, &Config{})
+	ctx := context.Background()
+	ate := res.ATEs["ate"]
+	top := &opb.Topology{Interfaces: []*opb.InterfaceConfig{
+		{Name: "src", Link: &opb.InterfaceConfig_Port{Port: "1/1"}},
+		{Name: "dst", Link: &opb.InterfaceConfig_Port{Port: "1/2"}},
+	}}
+	if err := b.PushTopology(ate, top); err != nil {
+		t.Fatalf("PushTopology() failed: %v", err)
+	}
+	flow := &opb.Flow{
+		Name:         "flow",
+		SrcEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "src"}},
+		DstEndpoints: []*opb.Flow_Endpoint{{InterfaceName: "dst"}},
+		FrameRate:    &opb.FrameRate{Type: &opb.FrameRate_Fps{Fps: 10000}},
+	}
+	if err := b.StartTraffic(ate, []*opb.Flow{flow}); err != nil {
+		t.Fatalf("StartTraffic() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := b.StopTraffic(ate); err != nil {
+		t.Fatalf("StopTraffic() failed: %v", err)
+	}
+
+	c, err := b.DialATEGNMI(ctx, ate)
+	if err != nil {
+		t.Fatalf("DialATEGNMI() failed: %v", err)
+	}
+	out := getLeaf(t, c, flowPath("flow", "counters/out-pkts")).GetUintVal()
+	in := getLeaf(t, c, flowPath("flow", "counters/in-pkts")).GetUintVal()
+	if out == 0 || in != out {
+		t.Errorf("flow counters got out-pkts %d and in-pkts %d, want equal and nonzero", out, in)
+	}
+	dc, err := b.DialGNMI(ctx, res.DUTs["dut"])
+	if err != nil {
+		t.Fatalf("DialGNMI() failed: %v", err)
+	}
+	if got := getLeaf(t, dc, "/interfaces/interface[name=Ethernet1]/state/counters/in-pkts").GetUintVal(); got == 0 {
+		t.Errorf("DUT in-pkts got 0, want nonzero")
+	}
+
+	// Traffic is lost while a port on its path is down.
+	if err := b.SetATEPortState(ate, "1/2", false); err != nil {
+		t.Fatalf("SetATEPortState() failed: %v", err)
+	}
+	if err := b.StartTraffic(ate, []*opb.Flow{flow}); err != nil {
+		t.Fatalf("StartTraffic() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := b.StopTraffic(ate); err != nil {
+		t.Fatalf("StopTraffic() failed: %v", err)
+	}
+	if got := getLeaf(t, c, flowPath("flow", "counters/in-pkts")).GetUintVal(); got != 0 {
+		t.Errorf("in-pkts with port down got %d, want 0", got)
+	}
+}
+
+func TestCLI(t *testing.T) {
+	b, res := reserve(t, &Config{})
+	ctx := context.Background()
+	dut := res.DUTs["dut"]
+	const config = "hostname sim\ninterface Ethernet1\n  shutdown\n"
+	if err := b.PushConfig(ctx, dut, config, &binding.ConfigOptions{}); err != nil {
+		t.Fatalf("PushConfig() failed: %v", err)
+	}
+	cli, err := b.DialCLI(ctx, dut)
+	if err != nil {
+		t.Fatalf("DialCLI() failed: %v", err)
+	}
+	defer cli.Close()
+	got, err := cli.SendCommand(ctx, "show  running-config")
+	if err != nil {
+		t.Fatalf("SendCommand() failed: %v", err)
+	}
+	if got != config {
+		t.Errorf("SendCommand() got %q, want %q", got, config)
+	}
+	got, err = cli.SendCommand(ctx, "show version")
+	if err != nil {
+		t.Fatalf("SendCommand() failed: %v", err)
+	}
+	if !strings.Contains(got, "7.4.1") {
+		t.Errorf("SendCommand() got %q, want it to contain the software version", got)
+	}
+	if _, err := cli.SendCommand(ctx, "reload"); err == nil {
+		t.Errorf("SendCommand() of unsupported command succeeded, want error")
+	}
+}
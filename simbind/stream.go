@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simbind
+
+import (
+	"bufio"
+	"golang.org/x/net/context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/openconfig/ondatra/internal/binding"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// streamClient is a simulated CLI that answers a few read-only commands.
+// Commands written to stdin are answered on stdout, one per line.
+type streamClient struct {
+	dev     *device
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+	stderrR *io.PipeReader
+	stderrW *io.PipeWriter
+}
+
+var _ binding.StreamClient = &streamClient{}
+
+func newStreamClient(dev *device) *streamClient {
+	c := &streamClient{dev: dev}
+	c.stdinR, c.stdinW = io.Pipe()
+	c.stdoutR, c.stdoutW = io.Pipe()
+	c.stderrR, c.stderrW = io.Pipe()
+	go c.serve()
+	return c
+}
+
+func (c *streamClient) serve() {
+	scanner := bufio.NewScanner(c.stdinR)
+	for scanner.Scan() {
+		out, err := c.SendCommand(context.Background(), scanner.Text())
+		w := c.stdoutW
+		if err != nil {
+			w, out = c.stderrW, err.Error()
+		}
+		if _, err := fmt.Fprintln(w, out); err != nil {
+			return
+		}
+	}
+}
+
+// SendCommand returns the output of a simulated CLI command.
+func (c *streamClient) SendCommand(ctx context.Context, cmd string) (string, error) {
+	if c.dev.rebooting() {
+		return "", errors.Errorf("%s is rebooting", c.dev.dims.Name)
+	}
+	switch strings.Join(strings.Fields(cmd), " ") {
+	case "":
+		return "", nil
+	case "show running-config", "show run", "show configuration":
+		return c.dev.runningVendorConfig(), nil
+	case "show version":
+		return fmt.Sprintf("Vendor: %s\nModel: %s\nSoftware version: %s\n",
+			c.dev.dims.Vendor, c.dev.dims.HardwareModel, c.dev.dims.SoftwareVersion), nil
+	case "show openconfig":
+		data, err := c.dev.ds.getJSON(&gpb.Path{})
+		return string(data), err
+	}
+	return "", errors.Errorf("unsupported command on simulated device: %q", cmd)
+}
+
+func (c *streamClient) Stdin() io.WriteCloser {
+	return c.stdinW
+}
+
+func (c *streamClient) Stdout() io.ReadCloser {
+	return c.stdoutR
+}
+
+func (c *streamClient) Stderr() io.ReadCloser {
+	return c.stderrR
+}
+
+func (c *streamClient) Close() error {
+	c.stdinW.Close()
+	c.stdoutW.Close()
+	c.stderrW.Close()
+	return nil
+}
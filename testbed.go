@@ -16,26 +16,110 @@ package ondatra
 
 import (
 	"golang.org/x/net/context"
+	"fmt"
+	"io/ioutil"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/openconfig/ondatra/internal/baseline"
+	"github.com/openconfig/ondatra/internal/diagnostics"
+	"github.com/openconfig/ondatra/internal/gnmiclient"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
+	"github.com/openconfig/ondatra/internal/reservemain"
 	"github.com/openconfig/ondatra/internal/testbed"
+	"github.com/openconfig/ondatra/internal/usererr"
 )
 
 func reserve(testbedPath string, runTime, waitTime time.Duration) error {
-	return testbed.Reserve(context.Background(), testbedPath, runTime, waitTime)
+	if err := testbed.Reserve(context.Background(), testbedPath, runTime, waitTime); err != nil {
+		return err
+	}
+	return reserved()
+}
+
+func fetch(testbedPath, id string) error {
+	if err := testbed.Fetch(context.Background(), testbedPath, id); err != nil {
+		return err
+	}
+	return reserved()
+}
+
+func reserved() error {
+	res, err := testbed.Reservation()
+	if err != nil {
+		return err
+	}
+	report.SetReservation(res)
+	return nil
 }
 
 func release() error {
+	baseline.Reset()
+	gnmiclient.Reset()
 	return testbed.Release(context.Background())
 }
 
+func captureBaseline() error {
+	res, err := testbed.Reservation()
+	if err != nil {
+		return err
+	}
+	return baseline.Capture(context.Background(), res)
+}
+
+// diagnosticsTimeout bounds the collection of diagnostics, so that an
+// unresponsive DUT does not hold up the release of the testbed.
+const diagnosticsTimeout = 5 * time.Minute
+
+// collectDiagnostics collects diagnostics of the DUTs into the directory set by
+// the -diagnostics_dir flag, or into a new temporary directory.
+func collectDiagnostics() error {
+	res, err := testbed.Reservation()
+	if err != nil {
+		return err
+	}
+	dir := *reservemain.DiagnosticsDir
+	if dir == "" {
+		if dir, err = ioutil.TempDir("", "ondatra-diagnostics-"); err != nil {
+			return err
+		}
+	}
+	logRunAction(fmt.Sprintf("Collecting diagnostics of the DUTs into %s", dir))
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+	return diagnostics.Collect(ctx, res, dir)
+}
+
+var restoreFn = baseline.Restore
+
+// restoreBaseline restores the DUTs usable by the test to their baseline config.
+func restoreBaseline(t *testing.T) {
+	t.Helper()
+	res := checkRes(t)
+	isLeased := leasedFilter(t)
+	var ids []string
+	for id, dut := range res.DUTs {
+		if isLeased(dut) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		dut := res.DUTs[id]
+		logAction(t, "Restoring the baseline config of %s", dut)
+		if err := restoreFn(context.Background(), dut); err != nil {
+			t.Errorf("Failed to restore the baseline config of DUT %q: %v", id, report.Error(t.Name(), err))
+		}
+	}
+}
+
 func checkRes(t testing.TB) *reservation.Reservation {
 	t.Helper()
 	res, err := testbed.Reservation()
 	if err != nil {
-		t.Fatal(err)
+		report.Fatalf(t, usererr.Wrap(err), "cannot use the testbed")
 	}
 	return res
 }
@@ -45,18 +129,24 @@ func DUT(t testing.TB, id string) *DUTDevice {
 	t.Helper()
 	rd, err := checkRes(t).DUT(id)
 	if err != nil {
-		t.Fatalf("DUT(t, %s): %v", id, err)
+		report.Fatalf(t, usererr.Wrap(err), "DUT(t, %s)", id)
 	}
+	checkLeased(t, id, rd)
 	return newDUT(id, rd)
 }
 
 // DUTs returns a map of DUT id to DUT in the testbed.
+// If the test has leased devices, only the leased DUTs are returned, and DUTs
+// leased by other tests are never returned.
 func DUTs(t testing.TB) map[string]*DUTDevice {
 	t.Helper()
 	rm := checkRes(t).DUTs
+	isLeased := leasedFilter(t)
 	m := make(map[string]*DUTDevice)
 	for id, rd := range rm {
-		m[id] = newDUT(id, rd)
+		if isLeased(rd) {
+			m[id] = newDUT(id, rd)
+		}
 	}
 	return m
 }
@@ -70,18 +160,24 @@ func ATE(t testing.TB, id string) *ATEDevice {
 	t.Helper()
 	ra, err := checkRes(t).ATE(id)
 	if err != nil {
-		t.Fatalf("ATE(t, %s): %v", id, err)
+		report.Fatalf(t, usererr.Wrap(err), "ATE(t, %s)", id)
 	}
+	checkLeased(t, id, ra)
 	return newATE(id, ra)
 }
 
 // ATEs returns a map of ATE id to ATE in the testbed.
+// If the test has leased devices, only the leased ATEs are returned, and ATEs
+// leased by other tests are never returned.
 func ATEs(t testing.TB) map[string]*ATEDevice {
 	t.Helper()
 	rm := checkRes(t).ATEs
+	isLeased := leasedFilter(t)
 	m := make(map[string]*ATEDevice)
 	for id, ra := range rm {
-		m[id] = newATE(id, ra)
+		if isLeased(ra) {
+			m[id] = newATE(id, ra)
+		}
 	}
 	return m
 }
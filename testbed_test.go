@@ -21,6 +21,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 	"github.com/openconfig/ondatra/negtest"
 
@@ -29,6 +31,16 @@ import (
 
 func TestReserveErrors(t *testing.T) {
 	initFakeBinding(t)
+	portRes := func(dutPorts, atePorts map[string]*reservation.Port) *reservation.Reservation {
+		return &reservation.Reservation{
+			DUTs: map[string]*reservation.DUT{"dut": &reservation.DUT{&reservation.Dims{
+				Name: "d1", Vendor: opb.Device_ARISTA, HardwareModel: "m", SoftwareVersion: "v", Ports: dutPorts,
+			}}},
+			ATEs: map[string]*reservation.ATE{"ate1": &reservation.ATE{&reservation.Dims{
+				Name: "a1", Vendor: opb.Device_IXIA, HardwareModel: "m", SoftwareVersion: "v", Ports: atePorts,
+			}}},
+		}
+	}
 
 	for _, tt := range []struct {
 		name, tbProto string
@@ -124,6 +136,77 @@ func TestReserveErrors(t *testing.T) {
 			Name: "d1", Vendor: opb.Device_ARISTA, HardwareModel: "m"},
 		}}},
 		wantErr: "no software version",
+	}, {
+		name:    "Invalid model regex",
+		tbProto: `duts{id:"dut" hardware_model:"regex:a(b"}`,
+		wantErr: "invalid hardware model",
+	}, {
+		name:    "Invalid extra dimension regex",
+		tbProto: `duts{id:"dut" extra_dimensions{key:"role" value:"regex:a(b"}}`,
+		wantErr: `invalid extra dimension "role"`,
+	}, {
+		name:    "Wrong vendor",
+		tbProto: `duts{id:"dut" vendor:CISCO}`,
+		res:     portRes(nil, nil),
+		wantErr: "vendor ARISTA, want CISCO",
+	}, {
+		name:    "Wrong hardware model",
+		tbProto: `duts{id:"dut" hardware_model:"regex:8[0-9]+"}`,
+		res:     portRes(nil, nil),
+		wantErr: `hardware model "m", which does not match "regex:8[0-9]+"`,
+	}, {
+		name:    "Wrong software version",
+		tbProto: `duts{id:"dut" software_version:"v2"}`,
+		res:     portRes(nil, nil),
+		wantErr: `software version "v", which does not match "v2"`,
+	}, {
+		name:    "Missing extra dimension",
+		tbProto: `duts{id:"dut" extra_dimensions{key:"role" value:"spine"}}`,
+		res:     portRes(nil, nil),
+		wantErr: `no extra dimension "role"`,
+	}, {
+		name:    "Port slower than link minimum speed",
+		tbProto: `duts{id:"dut" ports{id:"port1" speed:S_10GB}} ates{vendor:IXIA, id:"ate1" ports:{id:"port1"}} links{a:"dut:port1", b:"ate1:port1", min_speed:S_100GB}`,
+		wantErr: "less than the minimum speed",
+	}, {
+		name:    "Wrong port speed",
+		tbProto: `duts{id:"dut" ports{id:"port1" speed:S_100GB}}`,
+		res:     portRes(map[string]*reservation.Port{"port1": {Name: "p1", Speed: opb.Port_S_10GB}}, nil),
+		wantErr: "speed S_10GB, want S_100GB",
+	}, {
+		name:    "Wrong port PMD",
+		tbProto: `duts{id:"dut" ports{id:"port1" pmd:"ETH_100GBASE_LR4"}}`,
+		res:     portRes(map[string]*reservation.Port{"port1": {Name: "p1", PMD: "ETH_100GBASE_SR4"}}, nil),
+		wantErr: `PMD "ETH_100GBASE_SR4", want "ETH_100GBASE_LR4"`,
+	}, {
+		name:    "Grouped port not a breakout",
+		tbProto: `duts{id:"dut" ports{id:"port1" group:"g"}}`,
+		res:     portRes(map[string]*reservation.Port{"port1": {Name: "p1"}}, nil),
+		wantErr: "not a breakout channel",
+	}, {
+		name:    "Grouped ports broken out of different ports",
+		tbProto: `duts{id:"dut" ports{id:"port1" group:"g"} ports{id:"port2" group:"g"}}`,
+		res: portRes(map[string]*reservation.Port{
+			"port1": {Name: "p1/1", Breakout: "p1"},
+			"port2": {Name: "p2/1", Breakout: "p2"},
+		}, nil),
+		wantErr: "different ports",
+	}, {
+		name:    "Linked port slower than minimum speed",
+		tbProto: `duts{id:"dut" ports{id:"port1"}} ates{vendor:IXIA, id:"ate1" ports:{id:"port1"}} links{a:"dut:port1", b:"ate1:port1", min_speed:S_100GB}`,
+		res: portRes(
+			map[string]*reservation.Port{"port1": {Name: "p1", Speed: opb.Port_S_400GB}},
+			map[string]*reservation.Port{"port1": {Name: "1/1", Speed: opb.Port_S_10GB}},
+		),
+		wantErr: "ate1:port1 has speed S_10GB, want at least S_100GB",
+	}, {
+		name:    "Linked port not a LAG member",
+		tbProto: `duts{id:"dut" ports{id:"port1"}} ates{vendor:IXIA, id:"ate1" ports:{id:"port1"}} links{a:"dut:port1", b:"ate1:port1", lag_member:true}`,
+		res: portRes(
+			map[string]*reservation.Port{"port1": {Name: "p1", LAGMember: true}},
+			map[string]*reservation.Port{"port1": {Name: "1/1"}},
+		),
+		wantErr: "ate1:port1 is not a LAG member",
 	}} {
 		t.Run(tt.name, func(t *testing.T) {
 			fakeBind.Reservation = tt.res
@@ -140,6 +223,33 @@ func TestReserveErrors(t *testing.T) {
 	}
 }
 
+func TestFetch(t *testing.T) {
+	initFakeBinding(t)
+	res := *fakeRes
+	res.ID = "res1"
+	fakeBind.Reservation = &res
+
+	if err := fetch(fakeTBPath, "gaga"); err == nil {
+		release()
+		t.Fatalf("fetch() of unknown reservation succeeded, must fail")
+	}
+	if err := fetch(fakeTBPath, "res1"); err != nil {
+		t.Fatalf("fetch() failed: %v", err)
+	}
+	if got, want := checkRes(t).ID, "res1"; got != want {
+		t.Errorf("fetch() got reservation ID %q, want %q", got, want)
+	}
+	if got, want := DUT(t, "dut").Name(), "pf01.xxx01"; got != want {
+		t.Errorf("DUT name = %q, want %q", got, want)
+	}
+	if err := release(); err != nil {
+		t.Errorf("release() failed: %v", err)
+	}
+	if err := reserve(fakeTBPath, time.Hour, 0); err != nil {
+		t.Errorf("reserve() after detaching failed: %v", err)
+	}
+}
+
 func TestDoubleReserveFails(t *testing.T) {
 	initFakeBinding(t)
 	if err := reserve(fakeTBPath, time.Hour, 0); err != nil {
@@ -179,6 +289,33 @@ func TestReserve(t *testing.T) {
 		}
 	})
 
+	t.Run("Get DUT metadata", func(t *testing.T) {
+		d := DUT(t, "dut")
+		if got, want := d.ManagementAddress(), "192.0.2.1"; got != want {
+			t.Errorf("DUT management address = %q, want %q", got, want)
+		}
+		if got, want := d.SerialNumber(), "SN1234"; got != want {
+			t.Errorf("DUT serial number = %q, want %q", got, want)
+		}
+		if got, want := d.ChassisType(), "modular"; got != want {
+			t.Errorf("DUT chassis type = %q, want %q", got, want)
+		}
+		if diff := cmp.Diff([]string{"Linecard1", "Linecard2"}, d.LineCards()); diff != "" {
+			t.Errorf("DUT line cards got unexpected diff (-want,+got):\n%s", diff)
+		}
+		labels := d.Labels()
+		if diff := cmp.Diff(map[string]string{"rack": "r1"}, labels); diff != "" {
+			t.Errorf("DUT labels got unexpected diff (-want,+got):\n%s", diff)
+		}
+		labels["rack"] = "r2"
+		if got, want := d.Labels()["rack"], "r1"; got != want {
+			t.Errorf("DUT label after modifying the returned labels = %q, want %q", got, want)
+		}
+		if got := DUT(t, "dut_cisco").ManagementAddress(); got != "" {
+			t.Errorf("DUT management address = %q, want empty", got)
+		}
+	})
+
 	t.Run("Get DUTs", func(t *testing.T) {
 		id := "dut"
 		duts := DUTs(t)
@@ -199,6 +336,9 @@ func TestReserve(t *testing.T) {
 		if !strings.Contains(got, id) {
 			t.Errorf("DUT(%q) failed with message %q, want %q", id, got, id)
 		}
+		if strings.Contains(got, report.InfraFailPrefix) {
+			t.Errorf("DUT(%q) failed with message %q, want a user error", id, got)
+		}
 	})
 
 	t.Run("Get ATE", func(t *testing.T) {
@@ -232,6 +372,9 @@ func TestReserve(t *testing.T) {
 		if !strings.Contains(got, id) {
 			t.Errorf("ATE(%q) failed with message %q, want %q", id, got, id)
 		}
+		if strings.Contains(got, report.InfraFailPrefix) {
+			t.Errorf("ATE(%q) failed with message %q, want a user error", id, got)
+		}
 	})
 
 	t.Run("Get Port", func(t *testing.T) {
@@ -243,10 +386,9 @@ func TestReserve(t *testing.T) {
 		if got, want := p.Device().ID(), did; got != want {
 			t.Errorf("port device id = %q, want %q", got, want)
 		}
-		// TODO: Restore when speed is consistently propagated back.
-		// if got, want := p.Speed(), Speed10Gb; got != want {
-		//	t.Errorf("port speed = %d, want %d", got, want)
-		// }
+		if got, want := p.Speed(), Speed10Gb; got != want {
+			t.Errorf("port speed = %d, want %d", got, want)
+		}
 	})
 
 	t.Run("Get Port failure", func(t *testing.T) {
@@ -258,6 +400,9 @@ func TestReserve(t *testing.T) {
 		if !strings.Contains(got, pid) {
 			t.Errorf("Port(%q) failed with message %q, want %q", pid, got, pid)
 		}
+		if strings.Contains(got, report.InfraFailPrefix) {
+			t.Errorf("Port(%q) failed with message %q, want a user error", pid, got)
+		}
 	})
 }
 
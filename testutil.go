@@ -41,9 +41,16 @@ var (
 				HardwareModel:   "aristaModel",
 				SoftwareVersion: "aristaVersion",
 				Ports: map[string]*reservation.Port{
-					"port1": &reservation.Port{Name: "Et1/2/3"},
+					"port1": &reservation.Port{Name: "Et1/2/3", Speed: opb.Port_S_10GB},
 					"port2": &reservation.Port{Name: "Et4/5/6"},
 				},
+				Metadata: reservation.Metadata{
+					ManagementAddress: "192.0.2.1",
+					SerialNumber:      "SN1234",
+					ChassisType:       "modular",
+					LineCards:         []string{"Linecard1", "Linecard2"},
+					Labels:            map[string]string{"rack": "r1"},
+				},
 			}},
 			"dut_cisco": &reservation.DUT{&reservation.Dims{
 				Name:            "pf02.xxx01",
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/openconfig/ondatra/internal/ate"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 
 	opb "github.com/openconfig/ondatra/proto"
@@ -91,7 +92,7 @@ func (at *ATETopology) Push(t testing.TB) *ATETopology {
 	t.Helper()
 	logAction(t, "Pushing topology to %s", at.ate)
 	if err := ate.PushTopology(context.Background(), at.ate, at.top); err != nil {
-		t.Fatalf("Push(t) on %s: %v", at, err)
+		report.Fatalf(t, err, "Push(t) on %s", at)
 	}
 	return at
 }
@@ -102,7 +103,7 @@ func (at *ATETopology) Update(t testing.TB) {
 	t.Helper()
 	logAction(t, "Updating topology to %s", at.ate)
 	if err := ate.UpdateTopology(context.Background(), at.ate, at.top, false); err != nil {
-		t.Fatalf("Update(t) on %s: %v", at, err)
+		report.Fatalf(t, err, "Update(t) on %s", at)
 	}
 }
 
@@ -112,7 +113,7 @@ func (at *ATETopology) Update(t testing.TB) {
 func (at *ATETopology) UpdateBGPPeerStates(t testing.TB) {
 	t.Helper()
 	if err := ate.UpdateTopology(context.Background(), at.ate, at.top, true); err != nil {
-		t.Fatalf("UpdateBGPPeerState(t) on %s: %v", at, err)
+		report.Fatalf(t, err, "UpdateBGPPeerState(t) on %s", at)
 	}
 }
 
@@ -121,7 +122,7 @@ func (at *ATETopology) StartProtocols(t testing.TB) *ATETopology {
 	t.Helper()
 	logAction(t, "Starting protocols on %s", at.ate)
 	if err := ate.StartProtocols(context.Background(), at.ate); err != nil {
-		t.Fatalf("StartProtocols(t) on %s: %v", at, err)
+		report.Fatalf(t, err, "StartProtocols(t) on %s", at)
 	}
 	return at
 }
@@ -131,7 +132,7 @@ func (at *ATETopology) StopProtocols(t testing.TB) *ATETopology {
 	t.Helper()
 	logAction(t, "Stopping protocols to %s", at.ate)
 	if err := ate.StopProtocols(context.Background(), at.ate); err != nil {
-		t.Fatalf("StopProtocols(t) on %s: %v", at, err)
+		report.Fatalf(t, err, "StopProtocols(t) on %s", at)
 	}
 	return at
 }
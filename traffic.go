@@ -22,6 +22,7 @@ import (
 	log "github.com/golang/glog"
 
 	"github.com/openconfig/ondatra/internal/ate"
+	"github.com/openconfig/ondatra/internal/report"
 	"github.com/openconfig/ondatra/internal/reservation"
 
 	opb "github.com/openconfig/ondatra/proto"
@@ -49,7 +50,7 @@ func (tr *Traffic) Start(t testing.TB, flows ...*Flow) {
 	t.Helper()
 	logAction(t, "Starting traffic on %s", tr.ate)
 	if err := tr.start(flows); err != nil {
-		t.Fatalf("Start(t) on %s: %v", tr, err)
+		report.Fatalf(t, err, "Start(t) on %s", tr)
 	}
 }
 
@@ -68,7 +69,7 @@ func (tr *Traffic) Update(t testing.TB, flows ...*Flow) {
 	t.Helper()
 	logAction(t, "Updating traffic on %s", tr.ate)
 	if err := tr.update(flows); err != nil {
-		t.Fatalf("Update(t) on %s: %v", tr, err)
+		report.Fatalf(t, err, "Update(t) on %s", tr)
 	}
 }
 
@@ -85,7 +86,7 @@ func (tr *Traffic) Stop(t testing.TB) {
 	t.Helper()
 	logAction(t, "Stopping traffic on %s", tr.ate)
 	if err := ate.StopTraffic(context.Background(), tr.ate); err != nil {
-		t.Fatalf("Stop(t) on %s: %v", tr, err)
+		report.Fatalf(t, err, "Stop(t) on %s", tr)
 	}
 }
 